@@ -147,7 +147,10 @@ func (n *NodeBalance) refresh(rawNodes []string) error {
 func hashNodeList(nodes []string) uint64 {
 	var hash uint64 = 1469598103934665603
 	for _, node := range nodes {
-		hash = fnv1aHash(node)
+		for i := 0; i < len(node); i++ {
+			hash ^= uint64(node[i])
+			hash *= 1099511628211
+		}
 		hash ^= uint64('|')
 		hash *= 1099511628211
 	}
@@ -0,0 +1,58 @@
+package balance
+
+import (
+	"context"
+	"fmt"
+	"testing"
+)
+
+type listProvider struct {
+	nodes []string
+}
+
+func (p *listProvider) GetPinnedNode(ctx context.Context, id string) (string, bool, error) {
+	return "", false, nil
+}
+
+func (p *listProvider) GetNodeList(ctx context.Context) ([]string, error) {
+	return p.nodes, nil
+}
+
+func TestHashNodeList(t *testing.T) {
+	cases := [][2][]string{
+		{{"a", "c"}, {"b", "c"}},   // 只有最后一个节点相同
+		{{"ab", "c"}, {"a", "bc"}}, // 拼接后内容相同
+		{{"a"}, {"a", ""}},
+	}
+	for _, c := range cases {
+		if hashNodeList(c[0]) == hashNodeList(c[1]) {
+			t.Fatalf("hashNodeList(%q) == hashNodeList(%q)", c[0], c[1])
+		}
+	}
+}
+
+func TestNodeBalanceRefresh(t *testing.T) {
+	ctx := context.Background()
+	provider := &listProvider{nodes: []string{"a", "c"}}
+	nb, err := NewNodeBalance(provider)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100; i++ {
+		if _, err := nb.Locate(ctx, fmt.Sprintf("id:%d", i)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 节点列表变化后重建哈希环，已下线的节点不再被选中
+	provider.nodes = []string{"b", "c"}
+	for i := 0; i < 100; i++ {
+		node, err := nb.Locate(ctx, fmt.Sprintf("id:%d", i))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if node == "a" {
+			t.Fatalf("Locate id:%d got removed node a", i)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package codec
+
+import (
+	"encoding/json"
+	"fmt"
+	"sync"
+
+	"github.com/vmihailenco/msgpack/v5"
+	"google.golang.org/protobuf/proto"
+)
+
+// 内置编解码器名称，写入消息信封用于消费端选择解码方式
+const (
+	NameJSON     = "json"
+	NameProtobuf = "protobuf"
+	NameMsgpack  = "msgpack"
+)
+
+// Codec 消息负载编解码器
+type Codec interface {
+	// Name 编解码器名称，需全局唯一
+	Name() string
+	Marshal(v any) ([]byte, error)
+	Unmarshal(data []byte, v any) error
+}
+
+// 内置编解码器
+var (
+	JSON     Codec = jsonCodec{}
+	Protobuf Codec = protobufCodec{}
+	Msgpack  Codec = msgpackCodec{}
+)
+
+var codecs sync.Map
+
+func init() {
+	Register(JSON)
+	Register(Protobuf)
+	Register(Msgpack)
+}
+
+// Register 注册编解码器，同名覆盖
+func Register(c Codec) {
+	codecs.Store(c.Name(), c)
+}
+
+// Get 按名称获取已注册的编解码器
+func Get(name string) (Codec, bool) {
+	v, ok := codecs.Load(name)
+	if !ok {
+		return nil, false
+	}
+	return v.(Codec), true
+}
+
+type jsonCodec struct{}
+
+func (jsonCodec) Name() string {
+	return NameJSON
+}
+
+func (jsonCodec) Marshal(v any) ([]byte, error) {
+	return json.Marshal(v)
+}
+
+func (jsonCodec) Unmarshal(data []byte, v any) error {
+	return json.Unmarshal(data, v)
+}
+
+// protobufCodec v 必须实现 proto.Message
+type protobufCodec struct{}
+
+func (protobufCodec) Name() string {
+	return NameProtobuf
+}
+
+func (protobufCodec) Marshal(v any) ([]byte, error) {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return nil, fmt.Errorf("codec.Protobuf.Marshal %T is not a proto.Message", v)
+	}
+	return proto.Marshal(m)
+}
+
+func (protobufCodec) Unmarshal(data []byte, v any) error {
+	m, ok := v.(proto.Message)
+	if !ok {
+		return fmt.Errorf("codec.Protobuf.Unmarshal %T is not a proto.Message", v)
+	}
+	return proto.Unmarshal(data, m)
+}
+
+type msgpackCodec struct{}
+
+func (msgpackCodec) Name() string {
+	return NameMsgpack
+}
+
+func (msgpackCodec) Marshal(v any) ([]byte, error) {
+	return msgpack.Marshal(v)
+}
+
+func (msgpackCodec) Unmarshal(data []byte, v any) error {
+	return msgpack.Unmarshal(data, v)
+}
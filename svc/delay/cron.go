@@ -0,0 +1,27 @@
+package delay
+
+import (
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zhuud/go-library/svc/delay/internal"
+)
+
+// 错过执行时间的处理策略
+const (
+	MisfireSkip    = internal.MisfireSkip
+	MisfireCatchUp = internal.MisfireCatchUp
+)
+
+// 定时任务类型别名
+type (
+	Cron          = internal.Cron
+	CronJob       = internal.CronJob
+	CronHandler   = internal.CronHandler
+	MisfirePolicy = internal.MisfirePolicy
+)
+
+// NewCron 创建基于 Redis 的分布式定时任务调度器，同一 prefix 的任务全集群每次只执行一次。
+// 注册任务后调用 Start 启动调度，opts 中的 Concurrency 控制单次最多并发执行的任务数。
+func NewCron(redisClient *redis.Redis, prefix string, opts ...OptionFunc) *Cron {
+	opts = append(opts, internal.WithPrefix(prefix))
+	return internal.NewCron(redisClient, opts...)
+}
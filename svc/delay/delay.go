@@ -7,30 +7,69 @@ import (
 
 	"github.com/zeromicro/go-zero/core/stores/redis"
 	"github.com/zhuud/go-library/svc/delay/internal"
+	"github.com/zhuud/go-library/svc/gorm"
 )
 
 // 选项函数别名
 var (
-	WithPrefix           = internal.WithPrefix
-	WithMaxPushDelay     = internal.WithMaxPushDelay
-	WithConsumeInterval  = internal.WithConsumeInterval
-	WithBatchSize        = internal.WithBatchSize
-	WithConcurrency      = internal.WithConcurrency
-	WithHandlerTimeout   = internal.WithHandlerTimeout
-	WithMaxRetryAttempts = internal.WithMaxRetryAttempts
-	WithRetryDelay       = internal.WithRetryDelay
-	WithReservedTimeout  = internal.WithReservedTimeout
+	WithPrefix              = internal.WithPrefix
+	WithMaxPushDelay        = internal.WithMaxPushDelay
+	WithConsumeInterval     = internal.WithConsumeInterval
+	WithBatchSize           = internal.WithBatchSize
+	WithConcurrency         = internal.WithConcurrency
+	WithHandlerTimeout      = internal.WithHandlerTimeout
+	WithMaxRetryAttempts    = internal.WithMaxRetryAttempts
+	WithRetryDelay          = internal.WithRetryDelay
+	WithReservedTimeout     = internal.WithReservedTimeout
+	WithMaxReservedExpiries = internal.WithMaxReservedExpiries
+	WithShards              = internal.WithShards
+	WithShardBalance        = internal.WithShardBalance
+	WithMySQLTable          = internal.WithMySQLTable
+	WithStatsInterval       = internal.WithStatsInterval
+	WithDrainTimeout        = internal.WithDrainTimeout
+	WithPriorityWeights     = internal.WithPriorityWeights
+	WithKeyLimit            = internal.WithKeyLimit
+	WithDefaultKeyLimit     = internal.WithDefaultKeyLimit
+	WithThrottleDelay       = internal.WithThrottleDelay
 )
 
+// Push 选项
+var WithPriority = internal.WithPriority
+
+// 消息优先级，每个优先级是一条独立的 delayed lane
+const (
+	PriorityNormal = internal.PriorityNormal
+	PriorityHigh   = internal.PriorityHigh
+	PriorityLow    = internal.PriorityLow
+)
+
+// Extend 延长当前处理中消息的可见性截止时间，供长耗时 handler 作为心跳调用
+var Extend = internal.Extend
+
+// ErrReservationLost 消息已不在 reserved 中（已 ack 或已因可见性超时被重投递）
+var ErrReservationLost = internal.ErrReservationLost
+
 // 类型别名，公开 internal 中的核心类型
 type (
 	Message        = internal.Message
 	MessageHandler = internal.MessageHandler
 	OptionFunc     = internal.OptionFunc
 	Config         = internal.Config
+	Store          = internal.Store
+	Delivery       = internal.Delivery
+	RedisStore     = internal.RedisStore
+	MySQLStore     = internal.MySQLStore
+	Stats          = internal.Stats
+	DueBucket      = internal.DueBucket
+	LaneStats      = internal.LaneStats
+	Priority       = internal.Priority
+	PushOption     = internal.PushOption
+	KeyLimit       = internal.KeyLimit
+	BatchStore     = internal.BatchStore
+	PushItem       = internal.PushItem
+	PushResult     = internal.PushResult
 )
 
-
 var (
 	mu       sync.Mutex
 	delayers sync.Map
@@ -41,8 +80,34 @@ type Delay struct {
 	delayer *internal.Delayer
 }
 
-// NewDelay 创建延迟队列实例（per-prefix 单例，相同 prefix 返回已有实例）
+// NewDelay 创建基于 Redis 存储的延迟队列实例（per-prefix 单例，相同 prefix 返回已有实例）
 func NewDelay(redisClient *redis.Redis, prefix string, opts ...OptionFunc) *Delay {
+	return newDelay(prefix, func() Store {
+		return NewRedisStore(redisClient, prefix, opts...)
+	}, opts...)
+}
+
+// NewDelayWithStore 创建使用自定义存储的延迟队列实例（per-prefix 单例，相同 prefix 返回已有实例）
+func NewDelayWithStore(store Store, prefix string, opts ...OptionFunc) *Delay {
+	return newDelay(prefix, func() Store {
+		return store
+	}, opts...)
+}
+
+// NewRedisStore 创建 Redis 存储（默认存储），opts 中的 Shards、ShardBalance 生效
+func NewRedisStore(redisClient *redis.Redis, prefix string, opts ...OptionFunc) *RedisStore {
+	opts = append(opts, internal.WithPrefix(prefix))
+	return internal.NewRedisStore(redisClient, opts...)
+}
+
+// NewMySQLStore 基于 svc/gorm 的 dbName 连接创建 MySQL 存储，opts 中的 MySQLTable 生效。
+// 需要 MySQL 8.0+（SKIP LOCKED），表结构见 MySQLStore 说明，也可调用 Migrate 自动创建。
+func NewMySQLStore(dbName, prefix string, opts ...OptionFunc) *MySQLStore {
+	opts = append(opts, internal.WithPrefix(prefix))
+	return internal.NewMySQLStore(gorm.GetDB(dbName), opts...)
+}
+
+func newDelay(prefix string, newStore func() Store, opts ...OptionFunc) *Delay {
 	if v, ok := delayers.Load(prefix); ok {
 		return v.(*Delay)
 	}
@@ -55,7 +120,7 @@ func NewDelay(redisClient *redis.Redis, prefix string, opts ...OptionFunc) *Dela
 	}
 
 	opts = append(opts, internal.WithPrefix(prefix))
-	d := &Delay{delayer: internal.NewDelayer(redisClient, opts...)}
+	d := &Delay{delayer: internal.NewDelayer(newStore(), opts...)}
 	delayers.Store(prefix, d)
 
 	return d
@@ -66,12 +131,28 @@ func (d *Delay) Start(handler MessageHandler) {
 	d.delayer.Start(handler)
 }
 
-// Stop 停止消费并等待退出
+// Stop 停止拉取并等待处理中的消息完成（最多 DrainTimeout），超时时 handler 未返回的消息立即放回队列，已返回的照常 ack
 func (d *Delay) Stop() {
 	d.delayer.Stop()
 }
 
-// Push 推送延迟消息
-func (d *Delay) Push(ctx context.Context, key string, data any, delayDuration time.Duration) error {
-	return d.delayer.Push(ctx, key, data, delayDuration)
+// Push 推送延迟消息，可通过 WithPriority 指定优先级
+func (d *Delay) Push(ctx context.Context, key string, data any, delayDuration time.Duration, opts ...PushOption) error {
+	return d.delayer.Push(ctx, key, data, delayDuration, opts...)
+}
+
+// PushBatch 批量推送延迟消息，按块批量写入存储，返回与 items 一一对应的消息 ID 或错误
+func (d *Delay) PushBatch(ctx context.Context, items []PushItem) []PushResult {
+	return d.delayer.PushBatch(ctx, items)
+}
+
+// PushBatchAtomic 原子地批量推送延迟消息，任意一条失败则全部不写入。
+// Redis 存储要求所有消息位于同一分片（Shards=1 或 Key 路由到同一分片），且单批不超过 1000 条，超过时返回错误。
+func (d *Delay) PushBatchAtomic(ctx context.Context, items []PushItem) ([]string, error) {
+	return d.delayer.PushBatchAtomic(ctx, items)
+}
+
+// Stats 返回 delayed/reserved/dead 消息数、最早到期消息的逾期时长及未来 1 小时的到期分布
+func (d *Delay) Stats(ctx context.Context) (*Stats, error) {
+	return d.delayer.Stats(ctx)
 }
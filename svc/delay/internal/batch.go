@@ -0,0 +1,135 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// pushBatchChunkSize PushBatch 单次写入存储的消息数
+const pushBatchChunkSize = 500
+
+type (
+	// PushItem 批量推送中的一条消息
+	PushItem struct {
+		Key     string
+		Data    any
+		Delay   time.Duration
+		Options []PushOption
+	}
+
+	// PushResult 批量推送中一条消息的结果，Err 为 nil 时 ID 为消息 ID
+	PushResult struct {
+		ID  string
+		Err error
+	}
+)
+
+// PushBatch 批量推送延迟消息：逐条校验后按 pushBatchChunkSize 分块批量写入，
+// 返回与 items 一一对应的结果，部分失败不影响其他消息。
+func (dl *Delayer) PushBatch(ctx context.Context, items []PushItem) []PushResult {
+	ctx, span := delayTracer.Start(ctx, "push_batch", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	results := make([]PushResult, len(items))
+	msgs := make([]*Message, 0, len(items))
+	idx := make([]int, 0, len(items))
+	for i, item := range items {
+		msg, err := dl.prepareItem(ctx, item)
+		if err != nil {
+			results[i].Err = err
+			continue
+		}
+		msgs = append(msgs, msg)
+		idx = append(idx, i)
+	}
+
+	for start := 0; start < len(msgs); start += pushBatchChunkSize {
+		end := min(start+pushBatchChunkSize, len(msgs))
+		errs := dl.pushChunk(ctx, msgs[start:end])
+		for j := start; j < end; j++ {
+			result := &results[idx[j]]
+			if errs != nil && errs[j-start] != nil {
+				result.Err = fmt.Errorf("delay.PushBatch error: %w", errs[j-start])
+				continue
+			}
+			result.ID = msgs[j].ID
+		}
+	}
+
+	return results
+}
+
+// PushBatchAtomic 原子地批量推送延迟消息，任意一条校验或写入失败则一条都不写入，成功时返回与 items 对应的消息 ID。
+// Redis 存储要求所有消息位于同一分片（Shards=1 或 Key 路由到同一分片），且单批不超过 1000 条。
+func (dl *Delayer) PushBatchAtomic(ctx context.Context, items []PushItem) ([]string, error) {
+	batchStore, ok := dl.store.(BatchStore)
+	if !ok {
+		return nil, fmt.Errorf("delay.PushBatchAtomic store %T does not support batch push", dl.store)
+	}
+
+	ctx, span := delayTracer.Start(ctx, "push_batch", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	msgs := make([]*Message, 0, len(items))
+	ids := make([]string, 0, len(items))
+	for i, item := range items {
+		msg, err := dl.prepareItem(ctx, item)
+		if err != nil {
+			return nil, fmt.Errorf("delay.PushBatchAtomic item: %d, error: %w", i, err)
+		}
+		msgs = append(msgs, msg)
+		ids = append(ids, msg.ID)
+	}
+	if len(msgs) == 0 {
+		return ids, nil
+	}
+
+	if err := batchStore.PushBatchAtomic(ctx, msgs); err != nil {
+		return nil, fmt.Errorf("delay.PushBatchAtomic error: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (dl *Delayer) prepareItem(ctx context.Context, item PushItem) (*Message, error) {
+	msg := &Message{Key: item.Key, Data: item.Data}
+	for _, opt := range item.Options {
+		opt(msg)
+	}
+	if err := dl.prepareMessage(ctx, msg, item.Delay); err != nil {
+		return nil, err
+	}
+
+	return msg, nil
+}
+
+// pushChunk 写入一块消息，存储未实现 BatchStore 时逐条写入
+func (dl *Delayer) pushChunk(ctx context.Context, msgs []*Message) []error {
+	if batchStore, ok := dl.store.(BatchStore); ok {
+		return batchStore.PushBatch(ctx, msgs)
+	}
+
+	var errs []error
+	for i, msg := range msgs {
+		if err := dl.store.Push(ctx, msg); err != nil {
+			if errs == nil {
+				errs = make([]error, len(msgs))
+			}
+			errs[i] = err
+		}
+	}
+	return errs
+}
+
+// batchErrors 全部成功时返回 nil
+func batchErrors(errs []error) []error {
+	for _, err := range errs {
+		if err != nil {
+			return errs
+		}
+	}
+	return nil
+}
@@ -0,0 +1,455 @@
+package internal
+
+// Cron 基于 delay 的 Redis 基础设施实现的分布式定时任务：
+// - 每个任务在 `delayed` 中只有一个成员（任务名），score=下一次执行时间（unix ms），多实例重复注册不会产生重复
+// - `pop`：Lua 原子地把到期任务迁移到 `reserved`，score=租约截止时间，全集群同一次执行只会被一个实例取出
+// - `reschedule`：执行结束后 Lua 原子地从 `reserved` 删除并写入下一次执行时间，租约已过期的旧执行不会重复调度
+// - `expire`：租约过期（实例崩溃）的任务按原计划时间写回 `delayed`，再由 misfire 策略决定是否补执行
+// - `Pause`/`Resume`：暂停时从 `delayed` 移除，恢复时按当前时间重新计算下一次执行时间
+// - `Unregister`：从集群中删除任务，执行中的本次结束后不再调度
+
+import (
+	"context"
+	_ "embed"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"github.com/spf13/cast"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zeromicro/go-zero/core/threading"
+)
+
+const (
+	// Cron key 格式：{delay:cron:prefix}:type，同一 prefix 的 key 位于同一 hash slot
+	cronKey = "{delay:cron:%s}:%s"
+
+	cronJobsName   = "jobs"
+	cronPausedName = "paused"
+	cronFiredName  = "fired"
+
+	// cronPollInterval 拉取到期任务的间隔，定时任务精度为秒级
+	cronPollInterval = time.Second
+	// cronLeaseMargin 租约在任务超时之外额外保留的时间
+	cronLeaseMargin = time.Minute
+	// cronPutBackDelay 本实例未注册的任务延后放回的时间，避免每次拉取都重复取出
+	cronPutBackDelay = time.Second * 30
+
+	defaultCronTimeout          = time.Minute * 5
+	defaultCronMisfireThreshold = time.Minute
+)
+
+const (
+	// MisfireSkip 错过执行时间超过阈值时跳过本次，从当前时间计算下一次（默认）
+	MisfireSkip MisfirePolicy = iota
+	// MisfireCatchUp 错过的每一次都补执行，直到追上当前时间
+	MisfireCatchUp
+)
+
+var (
+	//go:embed cron_register.lua
+	cronRegisterLuaScript string
+	cronRegisterScript    = redis.NewScript(cronRegisterLuaScript)
+
+	//go:embed cron_pop.lua
+	cronPopLuaScript string
+	cronPopScript    = redis.NewScript(cronPopLuaScript)
+
+	//go:embed cron_reschedule.lua
+	cronRescheduleLuaScript string
+	cronRescheduleScript    = redis.NewScript(cronRescheduleLuaScript)
+
+	//go:embed cron_expire.lua
+	cronExpireLuaScript string
+	cronExpireScript    = redis.NewScript(cronExpireLuaScript)
+
+	//go:embed cron_resume.lua
+	cronResumeLuaScript string
+	cronResumeScript    = redis.NewScript(cronResumeLuaScript)
+
+	//go:embed cron_pause.lua
+	cronPauseLuaScript string
+	cronPauseScript    = redis.NewScript(cronPauseLuaScript)
+
+	//go:embed cron_unregister.lua
+	cronUnregisterLuaScript string
+	cronUnregisterScript    = redis.NewScript(cronUnregisterLuaScript)
+
+	cronParser = cron.NewParser(cron.Minute | cron.Hour | cron.Dom | cron.Month | cron.Dow | cron.Descriptor)
+)
+
+type (
+	// MisfirePolicy 错过执行时间（实例全部下线、租约过期重投递等）时的处理策略
+	MisfirePolicy int
+
+	// CronHandler 定时任务回调，scheduledAt 为本次计划执行时间
+	CronHandler func(ctx context.Context, name string, scheduledAt time.Time) error
+
+	// CronJob 定时任务定义
+	CronJob struct {
+		// Name 任务名，同一 prefix 下唯一
+		Name string
+		// Spec cron 表达式（分 时 日 月 周），支持 @daily、@every 5m 等描述符，与 Interval 二选一
+		Spec string
+		// Interval 固定间隔执行，与 Spec 二选一
+		Interval time.Duration
+		// Misfire 错过执行时间的处理策略，默认 MisfireSkip
+		Misfire MisfirePolicy
+		// MisfireThreshold 晚于计划时间多久视为错过，默认 1 分钟
+		MisfireThreshold time.Duration
+		// Timeout 单次执行超时时间，默认 5 分钟
+		Timeout time.Duration
+		Handler CronHandler
+	}
+
+	cronJob struct {
+		CronJob
+		schedule cron.Schedule
+	}
+
+	// Cron 分布式定时任务调度器
+	Cron struct {
+		mu     sync.Mutex
+		state  int32
+		cancel context.CancelFunc
+		wg     sync.WaitGroup
+
+		client *redis.Redis
+		logger *delayLogger
+		prefix string
+
+		concurrency int
+		sem         chan struct{} // 执行中的任务占用的并发槽位
+		jobs        sync.Map      // name → *cronJob
+	}
+)
+
+// NewCron 创建定时任务调度器，使用 opts 中的 Prefix、Concurrency 配置
+func NewCron(client *redis.Redis, opts ...OptionFunc) *Cron {
+	if client == nil {
+		panic("delay: redis client cannot be nil")
+	}
+
+	config := NewConfig(opts...)
+	if len(config.Prefix) == 0 {
+		panic("delay prefix cannot be empty")
+	}
+
+	return &Cron{
+		client:      client,
+		logger:      newDelayLogger(fmt.Sprintf("delay.cron.%s", config.Prefix)),
+		prefix:      config.Prefix,
+		concurrency: config.Concurrency,
+		sem:         make(chan struct{}, config.Concurrency),
+	}
+}
+
+// Register 注册定时任务。spec 未变化时保留集群中已有的下一次执行时间，可在每个实例启动时重复调用；
+// 只有注册了 handler 的实例才会执行该任务。
+func (c *Cron) Register(ctx context.Context, job CronJob) error {
+	if len(job.Name) == 0 {
+		return fmt.Errorf("delay.Cron.Register name must not be empty")
+	}
+	if job.Handler == nil {
+		return fmt.Errorf("delay.Cron.Register job: %s, handler must not be nil", job.Name)
+	}
+
+	spec := job.Spec
+	if job.Interval > 0 {
+		spec = fmt.Sprintf("@every %s", job.Interval)
+	}
+	schedule, err := cronParser.Parse(spec)
+	if err != nil {
+		return fmt.Errorf("delay.Cron.Register job: %s, parse spec: %s, error: %w", job.Name, spec, err)
+	}
+	if job.MisfireThreshold <= 0 {
+		job.MisfireThreshold = defaultCronMisfireThreshold
+	}
+	if job.Timeout <= 0 {
+		job.Timeout = defaultCronTimeout
+	}
+
+	_, err = c.client.ScriptRunCtx(ctx, cronRegisterScript,
+		[]string{
+			c.fmtKey(cronJobsName),
+			c.fmtKey(delayQueueName),
+			c.fmtKey(reservedQueueName),
+			c.fmtKey(cronPausedName),
+		}, []string{
+			job.Name,
+			spec,
+			cast.ToString(schedule.Next(time.Now()).UnixMilli()),
+		})
+	if err != nil {
+		return fmt.Errorf("delay.Cron.Register job: %s, ScriptRun error: %w", job.Name, err)
+	}
+
+	c.jobs.Store(job.Name, &cronJob{CronJob: job, schedule: schedule})
+	return nil
+}
+
+// Pause 暂停任务（全集群生效），执行中的本次不受影响
+func (c *Cron) Pause(ctx context.Context, name string) error {
+	_, err := c.client.ScriptRunCtx(ctx, cronPauseScript,
+		[]string{
+			c.fmtKey(cronPausedName),
+			c.fmtKey(delayQueueName),
+		}, []string{name})
+	if err != nil {
+		return fmt.Errorf("delay.Cron.Pause job: %s, ScriptRun error: %w", name, err)
+	}
+
+	return nil
+}
+
+// Unregister 从集群中删除任务（含暂停状态），执行中的本次不受影响、结束后不再调度；
+// 下线任务时调用，否则未注册该任务的实例会持续延后放回
+func (c *Cron) Unregister(ctx context.Context, name string) error {
+	_, err := c.client.ScriptRunCtx(ctx, cronUnregisterScript,
+		[]string{
+			c.fmtKey(cronJobsName),
+			c.fmtKey(delayQueueName),
+			c.fmtKey(cronPausedName),
+		}, []string{name})
+	if err != nil {
+		return fmt.Errorf("delay.Cron.Unregister job: %s, ScriptRun error: %w", name, err)
+	}
+
+	c.jobs.Delete(name)
+	return nil
+}
+
+// Resume 恢复暂停的任务，从当前时间计算下一次执行时间；任务需已在本实例 Register
+func (c *Cron) Resume(ctx context.Context, name string) error {
+	v, ok := c.jobs.Load(name)
+	if !ok {
+		return fmt.Errorf("delay.Cron.Resume job: %s not registered", name)
+	}
+
+	_, err := c.client.ScriptRunCtx(ctx, cronResumeScript,
+		[]string{
+			c.fmtKey(cronPausedName),
+			c.fmtKey(delayQueueName),
+			c.fmtKey(reservedQueueName),
+		}, []string{
+			name,
+			cast.ToString(v.(*cronJob).schedule.Next(time.Now()).UnixMilli()),
+		})
+	if err != nil {
+		return fmt.Errorf("delay.Cron.Resume job: %s, ScriptRun error: %w", name, err)
+	}
+
+	return nil
+}
+
+// Start 启动后台调度。只能调用一次，重复调用或已 Stop 后调用会被忽略。
+func (c *Cron) Start() {
+	c.mu.Lock()
+	if c.state != stateNew {
+		c.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	c.cancel = cancel
+	c.state = stateRunning
+	c.wg.Add(1)
+	c.mu.Unlock()
+
+	c.logger.Infof("delay cron start in the background")
+	go func() {
+		defer c.wg.Done()
+		c.loop(ctx)
+	}()
+}
+
+// Stop 停止调度并等待执行中的任务退出。幂等安全，多次调用不会 panic。
+func (c *Cron) Stop() {
+	c.mu.Lock()
+	if c.state != stateRunning {
+		c.mu.Unlock()
+		return
+	}
+	c.state = stateStopped
+	c.mu.Unlock()
+
+	c.cancel()
+	c.wg.Wait()
+}
+
+func (c *Cron) loop(ctx context.Context) {
+	defer c.logger.Infof("delay cron stop")
+
+	ticker := time.NewTicker(cronPollInterval)
+	defer ticker.Stop()
+
+	var lastExpire time.Time
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			now := time.Now()
+			if now.Sub(lastExpire) >= maintainInterval {
+				c.expire(ctx, now)
+				lastExpire = now
+			}
+			c.runOnce(ctx, now)
+		}
+	}
+}
+
+// runOnce 按空闲并发槽位取出到期任务，后台执行后立即返回，不阻塞下一次拉取；自带 panic 恢复
+func (c *Cron) runOnce(ctx context.Context, now time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			c.logger.Errorf("delay.Cron.runOnce panic: %v", err)
+		}
+	}()
+
+	free := c.concurrency - len(c.sem)
+	if free <= 0 {
+		return
+	}
+
+	lease := now.Add(c.maxTimeout() + cronLeaseMargin).UnixMilli()
+	data, err := c.client.ScriptRunCtx(ctx, cronPopScript,
+		[]string{
+			c.fmtKey(delayQueueName),
+			c.fmtKey(reservedQueueName),
+			c.fmtKey(cronPausedName),
+			c.fmtKey(cronFiredName),
+		}, []string{
+			cast.ToString(now.UnixMilli()),
+			cast.ToString(free),
+			cast.ToString(lease),
+		})
+	if err != nil {
+		c.logger.Errorf("delay.Cron.runOnce ScriptRun error: %v", err)
+		return
+	}
+
+	// 只有 loop 协程会占用槽位，取出的任务数不超过 free，写入 sem 不会阻塞
+	vals := cast.ToStringSlice(data)
+	for i := 0; i+1 < len(vals); i += 2 {
+		name, scheduledAt := vals[i], time.UnixMilli(cast.ToInt64(vals[i+1]))
+		c.sem <- struct{}{}
+		c.wg.Add(1)
+		threading.GoSafe(func() {
+			defer func() {
+				<-c.sem
+				c.wg.Done()
+			}()
+			c.fire(ctx, name, scheduledAt, now, lease)
+		})
+	}
+}
+
+// fire 按 misfire 策略执行一次任务并调度下一次
+func (c *Cron) fire(ctx context.Context, name string, scheduledAt, now time.Time, lease int64) {
+	v, ok := c.jobs.Load(name)
+	if !ok {
+		// 本实例未注册该任务（如滚动发布期间），延后放回由其他实例执行；任务已下线时应调用 Unregister
+		c.logger.Errorf("delay.Cron.fire job: %s not registered on this instance, put back after %v", name, cronPutBackDelay)
+		c.reschedule(ctx, name, lease, time.Now().Add(cronPutBackDelay))
+		return
+	}
+	job := v.(*cronJob)
+
+	next := job.schedule.Next(scheduledAt)
+	if now.Sub(scheduledAt) > job.MisfireThreshold && job.Misfire == MisfireSkip {
+		c.logger.Errorf("delay.Cron.fire job: %s misfired, scheduled at: %v, skip", name, scheduledAt)
+		c.reschedule(ctx, name, lease, job.schedule.Next(now))
+		return
+	}
+
+	c.execute(ctx, job, scheduledAt)
+	c.reschedule(ctx, name, lease, next)
+}
+
+func (c *Cron) execute(ctx context.Context, job *cronJob, scheduledAt time.Time) {
+	defer func() {
+		if err := recover(); err != nil {
+			c.logger.Errorf("delay.Cron.execute job: %s, panic: %v", job.Name, err)
+		}
+	}()
+
+	handlerCtx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	startTime := time.Now()
+	err := job.Handler(handlerCtx, job.Name, scheduledAt)
+	if err != nil {
+		c.logger.Errorf("delay.Cron.execute job: %s, scheduled at: %v, error: %v", job.Name, scheduledAt, err)
+	} else {
+		c.logger.Infof("delay.Cron.execute job: %s, scheduled at: %v, duration: %v", job.Name, scheduledAt, time.Since(startTime))
+	}
+	// 定时任务复用 delay 的 handler 指标，key 为任务名
+	metricHandlerTotal.Inc(c.prefix, job.Name, handlerResult(err))
+	metricHandlerDuration.Observe(time.Since(startTime).Milliseconds(), c.prefix, job.Name)
+}
+
+// reschedule 凭租约把任务从 reserved 写回 delayed，next 为零值时不再调度
+func (c *Cron) reschedule(ctx context.Context, name string, lease int64, next time.Time) {
+	var nextMs int64
+	if !next.IsZero() {
+		nextMs = next.UnixMilli()
+	}
+
+	// 使用独立 ctx，Stop 时也要把执行完的任务写回
+	ret, err := c.client.ScriptRunCtx(context.WithoutCancel(ctx), cronRescheduleScript,
+		[]string{
+			c.fmtKey(reservedQueueName),
+			c.fmtKey(delayQueueName),
+			c.fmtKey(cronPausedName),
+			c.fmtKey(cronFiredName),
+			c.fmtKey(cronJobsName),
+		}, []string{
+			name,
+			cast.ToString(lease),
+			cast.ToString(nextMs),
+		})
+	if err != nil {
+		c.logger.Errorf("delay.Cron.reschedule job: %s, ScriptRun error: %v", name, err)
+		return
+	}
+	if cast.ToInt(ret) != 1 {
+		c.logger.Errorf("delay.Cron.reschedule job: %s, lease lost", name)
+	}
+}
+
+// expire 把租约过期的任务按原计划时间写回 delayed
+func (c *Cron) expire(ctx context.Context, now time.Time) {
+	ret, err := c.client.ScriptRunCtx(ctx, cronExpireScript,
+		[]string{
+			c.fmtKey(reservedQueueName),
+			c.fmtKey(delayQueueName),
+			c.fmtKey(cronFiredName),
+			c.fmtKey(cronJobsName),
+		}, []string{
+			cast.ToString(now.UnixMilli()),
+			cast.ToString(c.concurrency),
+		})
+	if err != nil {
+		c.logger.Errorf("delay.Cron.expire ScriptRun error: %v", err)
+		return
+	}
+	if n := cast.ToInt(ret); n > 0 {
+		c.logger.Errorf("delay.Cron.expire requeued %d lease expired jobs", n)
+	}
+}
+
+// maxTimeout 本实例已注册任务的最大超时时间，用于计算租约
+func (c *Cron) maxTimeout() time.Duration {
+	timeout := defaultCronTimeout
+	c.jobs.Range(func(_, v any) bool {
+		timeout = max(timeout, v.(*cronJob).Timeout)
+		return true
+	})
+	return timeout
+}
+
+func (c *Cron) fmtKey(keyType string) string {
+	return fmt.Sprintf(cronKey, c.prefix, keyType)
+}
@@ -0,0 +1,20 @@
+-- KEYS[1] - The reserved queue (e.g., {delay:cron:prefix}:reserved)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[3] - The fired hash (e.g., {delay:cron:prefix}:fired)
+-- KEYS[4] - The jobs hash (e.g., {delay:cron:prefix}:jobs)
+-- ARGV[1] - The threshold UNIX timestamp (ms)
+-- ARGV[2] - The batch size
+
+-- 租约过期（执行实例崩溃或超时）的任务按原计划执行时间写回 delayed，由 misfire 策略决定是否补执行；已注销的任务直接清理
+local val = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'limit', 0, ARGV[2])
+
+for i = 1, #val do
+    local scheduled = redis.call('hget', KEYS[3], val[i]) or ARGV[1]
+    redis.call('zrem', KEYS[1], val[i])
+    redis.call('hdel', KEYS[3], val[i])
+    if redis.call('hexists', KEYS[4], val[i]) == 1 then
+        redis.call('zadd', KEYS[2], scheduled, val[i])
+    end
+end
+
+return #val
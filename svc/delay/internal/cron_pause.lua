@@ -0,0 +1,9 @@
+-- KEYS[1] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- ARGV[1] - The job name
+
+-- 执行中的任务由 reschedule 检查 paused 后不再写入下一次
+redis.call('sadd', KEYS[1], ARGV[1])
+redis.call('zrem', KEYS[2], ARGV[1])
+
+return 1
@@ -0,0 +1,23 @@
+-- KEYS[1] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[2] - The reserved queue (e.g., {delay:cron:prefix}:reserved)
+-- KEYS[3] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- KEYS[4] - The fired hash (e.g., {delay:cron:prefix}:fired)，记录执行中任务的计划执行时间
+-- ARGV[1] - The threshold UNIX timestamp (ms)
+-- ARGV[2] - The batch size
+-- ARGV[3] - The lease deadline UNIX timestamp (ms)，同时作为本次执行的凭证
+
+local val = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'withscores', 'limit', 0, ARGV[2])
+local jobs = {}
+
+for i = 1, #val, 2 do
+    redis.call('zrem', KEYS[1], val[i])
+    -- 暂停中的任务直接移除，resume 时重新计算下一次执行时间
+    if redis.call('sismember', KEYS[3], val[i]) == 0 then
+        redis.call('zadd', KEYS[2], ARGV[3], val[i])
+        redis.call('hset', KEYS[4], val[i], val[i + 1])
+        table.insert(jobs, val[i])
+        table.insert(jobs, val[i + 1])
+    end
+end
+
+return jobs
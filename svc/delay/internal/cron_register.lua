@@ -0,0 +1,24 @@
+-- KEYS[1] - The jobs hash (e.g., {delay:cron:prefix}:jobs)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[3] - The reserved queue (e.g., {delay:cron:prefix}:reserved)
+-- KEYS[4] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- ARGV[1] - The job name
+-- ARGV[2] - The job spec
+-- ARGV[3] - The next occurrence UNIX timestamp (ms)
+
+local old = redis.call('hget', KEYS[1], ARGV[1])
+redis.call('hset', KEYS[1], ARGV[1], ARGV[2])
+
+-- 已暂停或正在执行的任务不写入，执行结束后由 reschedule 写入下一次
+if redis.call('sismember', KEYS[4], ARGV[1]) == 1 or redis.call('zscore', KEYS[3], ARGV[1]) then
+    return 0
+end
+
+-- spec 未变化时保留已有的下一次执行时间，多实例重复注册不会重置或重复
+if old == ARGV[2] then
+    redis.call('zadd', KEYS[2], 'NX', ARGV[3], ARGV[1])
+else
+    redis.call('zadd', KEYS[2], ARGV[3], ARGV[1])
+end
+
+return 1
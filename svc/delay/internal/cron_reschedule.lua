@@ -0,0 +1,24 @@
+-- KEYS[1] - The reserved queue (e.g., {delay:cron:prefix}:reserved)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[3] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- KEYS[4] - The fired hash (e.g., {delay:cron:prefix}:fired)
+-- KEYS[5] - The jobs hash (e.g., {delay:cron:prefix}:jobs)
+-- ARGV[1] - The job name
+-- ARGV[2] - The lease deadline UNIX timestamp (ms)，pop 时的凭证
+-- ARGV[3] - The next occurrence UNIX timestamp (ms)，0 表示不再调度
+
+-- 凭证不一致说明租约已过期并被其他实例重新取出，不再重复调度
+local lease = redis.call('zscore', KEYS[1], ARGV[1])
+if not lease or tonumber(lease) ~= tonumber(ARGV[2]) then
+    return 0
+end
+
+redis.call('zrem', KEYS[1], ARGV[1])
+redis.call('hdel', KEYS[4], ARGV[1])
+
+-- 已暂停或已注销的任务不再调度
+if redis.call('sismember', KEYS[3], ARGV[1]) == 0 and redis.call('hexists', KEYS[5], ARGV[1]) == 1 and tonumber(ARGV[3]) > 0 then
+    redis.call('zadd', KEYS[2], ARGV[3], ARGV[1])
+end
+
+return 1
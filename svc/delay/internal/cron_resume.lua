@@ -0,0 +1,16 @@
+-- KEYS[1] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[3] - The reserved queue (e.g., {delay:cron:prefix}:reserved)
+-- ARGV[1] - The job name
+-- ARGV[2] - The next occurrence UNIX timestamp (ms)
+
+if redis.call('srem', KEYS[1], ARGV[1]) == 0 then
+    return 0
+end
+
+-- 暂停期间仍在执行的任务由 reschedule 负责写入下一次
+if not redis.call('zscore', KEYS[3], ARGV[1]) then
+    redis.call('zadd', KEYS[2], 'NX', ARGV[2], ARGV[1])
+end
+
+return 1
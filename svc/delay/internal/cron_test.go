@@ -0,0 +1,246 @@
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestCron(t *testing.T) {
+	ctx := context.Background()
+
+	newCron := func(t *testing.T, mr *miniredis.Miniredis) *Cron {
+		return NewCron(redis.New(mr.Addr()), WithPrefix("test"))
+	}
+	register := func(t *testing.T, c *Cron, misfire MisfirePolicy, runs *atomic.Int32) {
+		err := c.Register(ctx, CronJob{
+			Name:     "job",
+			Interval: time.Minute,
+			Misfire:  misfire,
+			Handler: func(ctx context.Context, name string, scheduledAt time.Time) error {
+				runs.Add(1)
+				return nil
+			},
+		})
+		if err != nil {
+			t.Fatalf("Register error: %v", err)
+		}
+	}
+	// runOnce 拉取一次并等待本次取出的任务执行完
+	runOnce := func(c *Cron, now time.Time) {
+		c.runOnce(ctx, now)
+		c.wg.Wait()
+	}
+	nextAt := func(t *testing.T, c *Cron, mr *miniredis.Miniredis) time.Time {
+		score, err := mr.ZScore(c.fmtKey(delayQueueName), "job")
+		if err != nil {
+			t.Fatalf("job not scheduled: %v", err)
+		}
+		return time.UnixMilli(int64(score))
+	}
+
+	t.Run("runs once across instances", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		a, b := newCron(t, mr), newCron(t, mr)
+		register(t, a, MisfireSkip, &runs)
+		first := nextAt(t, a, mr)
+		register(t, b, MisfireSkip, &runs)
+		if got := nextAt(t, b, mr); !got.Equal(first) {
+			t.Fatalf("re-register changed next occurrence from %v to %v", first, got)
+		}
+
+		runOnce(a, first)
+		runOnce(b, first)
+		if runs.Load() != 1 {
+			t.Fatalf("runs got %d, want 1", runs.Load())
+		}
+		if got := nextAt(t, a, mr); !got.Equal(first.Add(time.Minute)) {
+			t.Fatalf("next occurrence got %v, want %v", got, first.Add(time.Minute))
+		}
+		if n, _ := mr.ZMembers(a.fmtKey(delayQueueName)); len(n) != 1 {
+			t.Fatalf("delayed got %v, want exactly one occurrence", n)
+		}
+	})
+
+	t.Run("misfire skip", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		c := newCron(t, mr)
+		register(t, c, MisfireSkip, &runs)
+		late := nextAt(t, c, mr).Add(time.Minute * 10)
+
+		runOnce(c, late)
+		if runs.Load() != 0 {
+			t.Fatalf("runs got %d, want misfire skipped", runs.Load())
+		}
+		if got := nextAt(t, c, mr); !got.After(late) {
+			t.Fatalf("next occurrence got %v, want after %v", got, late)
+		}
+	})
+
+	t.Run("misfire catch up", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		c := newCron(t, mr)
+		register(t, c, MisfireCatchUp, &runs)
+		first := nextAt(t, c, mr)
+		late := first.Add(time.Minute*2 + time.Second)
+
+		for i := 0; i < 5; i++ {
+			runOnce(c, late)
+		}
+		if runs.Load() != 3 {
+			t.Fatalf("runs got %d, want 3 caught up", runs.Load())
+		}
+		if got := nextAt(t, c, mr); !got.Equal(first.Add(time.Minute * 3)) {
+			t.Fatalf("next occurrence got %v, want %v", got, first.Add(time.Minute*3))
+		}
+	})
+
+	t.Run("pause and resume", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		c := newCron(t, mr)
+		register(t, c, MisfireSkip, &runs)
+		first := nextAt(t, c, mr)
+
+		if err := c.Pause(ctx, "job"); err != nil {
+			t.Fatalf("Pause error: %v", err)
+		}
+		register(t, c, MisfireSkip, &runs)
+		runOnce(c, first)
+		if runs.Load() != 0 {
+			t.Fatalf("paused job ran %d times", runs.Load())
+		}
+
+		if err := c.Resume(ctx, "job"); err != nil {
+			t.Fatalf("Resume error: %v", err)
+		}
+		runOnce(c, nextAt(t, c, mr))
+		if runs.Load() != 1 {
+			t.Fatalf("resumed job runs got %d, want 1", runs.Load())
+		}
+	})
+
+	t.Run("expired lease requeues", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		c := newCron(t, mr)
+		register(t, c, MisfireCatchUp, &runs)
+		first := nextAt(t, c, mr)
+
+		// 模拟实例取出后崩溃：只 pop 不执行
+		_, err := c.client.ScriptRunCtx(ctx, cronPopScript,
+			[]string{c.fmtKey(delayQueueName), c.fmtKey(reservedQueueName), c.fmtKey(cronPausedName), c.fmtKey(cronFiredName)},
+			[]string{"9999999999999", "10", "1"})
+		if err != nil {
+			t.Fatalf("pop error: %v", err)
+		}
+
+		c.expire(ctx, first)
+		if got := nextAt(t, c, mr); !got.Equal(first) {
+			t.Fatalf("requeued occurrence got %v, want original %v", got, first)
+		}
+		runOnce(c, first)
+		if runs.Load() != 1 {
+			t.Fatalf("runs got %d, want 1", runs.Load())
+		}
+	})
+
+	t.Run("slow job does not block polling", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		c := NewCron(redis.New(mr.Addr()), WithPrefix("test"), WithConcurrency(2))
+		release := make(chan struct{})
+		var runs atomic.Int32
+		for _, name := range []string{"slow", "fast"} {
+			err := c.Register(ctx, CronJob{
+				Name:     name,
+				Interval: time.Minute,
+				Misfire:  MisfireCatchUp,
+				Handler: func(ctx context.Context, name string, scheduledAt time.Time) error {
+					runs.Add(1)
+					if name == "slow" {
+						<-release
+					}
+					return nil
+				},
+			})
+			if err != nil {
+				t.Fatalf("Register error: %v", err)
+			}
+		}
+		// 先只让 slow 到期
+		mr.ZAdd(c.fmtKey(delayQueueName), float64(time.Now().Add(time.Hour).UnixMilli()), "fast")
+		c.runOnce(ctx, time.Now().Add(time.Minute))
+
+		// slow 未结束时下一次拉取仍能取出 fast
+		mr.ZAdd(c.fmtKey(delayQueueName), float64(time.Now().UnixMilli()), "fast")
+		c.runOnce(ctx, time.Now().Add(time.Minute))
+		deadline := time.Now().Add(time.Second * 5)
+		for runs.Load() < 2 && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond * 10)
+		}
+		if runs.Load() != 2 {
+			t.Fatalf("runs got %d, want fast job to run while slow job is running", runs.Load())
+		}
+		close(release)
+		c.wg.Wait()
+	})
+
+	t.Run("unregistered job put back later", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		a, b := newCron(t, mr), newCron(t, mr)
+		register(t, a, MisfireSkip, &runs)
+		first := nextAt(t, a, mr)
+
+		runOnce(b, first)
+		if got := nextAt(t, b, mr); got.Before(time.Now().Add(cronPutBackDelay - time.Second)) {
+			t.Fatalf("put back at %v, want delayed by %v", got, cronPutBackDelay)
+		}
+		if runs.Load() != 0 {
+			t.Fatalf("runs got %d, want 0", runs.Load())
+		}
+	})
+
+	t.Run("unregister", func(t *testing.T) {
+		mr := miniredis.RunT(t)
+		var runs atomic.Int32
+		c := newCron(t, mr)
+		register(t, c, MisfireSkip, &runs)
+		first := nextAt(t, c, mr)
+
+		if err := c.Unregister(ctx, "job"); err != nil {
+			t.Fatalf("Unregister error: %v", err)
+		}
+		runOnce(c, first)
+		if runs.Load() != 0 {
+			t.Fatalf("unregistered job ran %d times", runs.Load())
+		}
+		if mr.Exists(c.fmtKey(delayQueueName)) || mr.Exists(c.fmtKey(cronJobsName)) {
+			t.Fatalf("unregistered job still scheduled")
+		}
+
+		// 执行中被删除的任务结束后不再调度
+		register(t, c, MisfireSkip, &runs)
+		_, err := c.client.ScriptRunCtx(ctx, cronPopScript,
+			[]string{c.fmtKey(delayQueueName), c.fmtKey(reservedQueueName), c.fmtKey(cronPausedName), c.fmtKey(cronFiredName)},
+			[]string{"9999999999999", "10", "1"})
+		if err != nil {
+			t.Fatalf("pop error: %v", err)
+		}
+		if err := c.Unregister(ctx, "job"); err != nil {
+			t.Fatalf("Unregister error: %v", err)
+		}
+		c.reschedule(ctx, "job", 1, time.Now().Add(time.Minute))
+		c.expire(ctx, time.Now().Add(time.Hour))
+		if mr.Exists(c.fmtKey(delayQueueName)) {
+			t.Fatalf("unregistered job rescheduled")
+		}
+	})
+}
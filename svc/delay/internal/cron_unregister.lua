@@ -0,0 +1,11 @@
+-- KEYS[1] - The jobs hash (e.g., {delay:cron:prefix}:jobs)
+-- KEYS[2] - The delayed queue (e.g., {delay:cron:prefix}:delayed)
+-- KEYS[3] - The paused set (e.g., {delay:cron:prefix}:paused)
+-- ARGV[1] - The job name
+
+-- 执行中的任务留在 reserved，由 reschedule/expire 检查 jobs 后清理，不再写入下一次
+local removed = redis.call('hdel', KEYS[1], ARGV[1])
+redis.call('zrem', KEYS[2], ARGV[1])
+redis.call('srem', KEYS[3], ARGV[1])
+
+return removed
@@ -1,27 +1,26 @@
 package internal
 
-// Delayer 是 delay queue 的核心实现。
-// 使用 Redis Sorted Set（`delayed`/`reserved`）+ Lua 脚本保证原子出队/重投递：
-// - `Push`：把消息写入 `delayed`，score=目标触发时间（unix seconds）
-// - `pop`：从 `delayed` 中取出到期元素，并原子迁移到 `reserved`
+// Delayer 是 delay queue 的核心实现，存储由 Store 接口抽象（默认 Redis，可选 MySQL）：
+// - `Push`：把消息写入 `delayed`，score=目标触发时间（unix seconds），trace context 写入消息 Headers
+// - `pop`：按优先级权重从各 `delayed` lane 中取出到期元素，并原子迁移到 `reserved`，score=可见性截止时间
 // - `successAck`：成功消费后从 `reserved` 删除
-// - `failAck`：失败时根据重试策略把消息重投递到 `delayed`（通过 Lua 原子替换 reserved）
-// - `removeStaleReserved`：当消费者异常导致 reserved 未 ack 时，定期清除
+// - `failAck`：失败时根据重试策略把消息原子地从 `reserved` 重投递到 `delayed`
+// - `Extend`：处理中的消息延长可见性截止时间（心跳）
+// - `expireReserved`：可见性超时未 ack 的消息重投递到 `delayed`，超时次数过多则移入 `dead`
+// - `Stats`：队列统计，消费中定期导出为 Prometheus gauge；handler 耗时与结果按 Key 记录
 
 import (
 	"context"
-	_ "embed"
-	"encoding/json"
 	"fmt"
+	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
-	"github.com/avast/retry-go/v4"
-	"github.com/spf13/cast"
 	"github.com/zeromicro/go-zero/core/stat"
-	"github.com/zeromicro/go-zero/core/stores/redis"
 	"github.com/zeromicro/go-zero/core/threading"
 	"github.com/zhuud/go-library/utils"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -30,30 +29,22 @@ const (
 	stateRunning              // 消费循环运行中
 	stateStopped              // 已停止，终态
 
-	// 队列 key 格式：{delay:queue:prefix}:queueType
-	queueKey          = "{delay:queue:%s}:%s"
-	delayQueueName    = "delayed"
-	reservedQueueName = "reserved"
+	// maintainInterval reserved 可见性超时检查、分片归属刷新的间隔
+	maintainInterval = time.Second * 5
 
 	// 默认配置
-	defaultMaxPushDelayDuration = time.Hour * 24 * 7 // 默认最大推送延迟时长
+	defaultMaxPushDelayDuration = time.Hour * 24 * 7     // 默认最大推送延迟时长
 	defaultConsumeInterval      = time.Millisecond * 100 // 默认消费轮询间隔
-	defaultBatchSize            = 100 // 默认单次消费数量
-	defaultConcurrency          = 100 // 默认并发处理协程数
-	defaultHandlerTimeout       = time.Second * 5 // 默认单条消息处理超时时间
-	defaultMaxRetryAttempts     = 0 // 默认最大重试次数
-	defaultRetryDelay           = time.Second * 30 // 默认重试延迟时间
-	defaultReservedTimeout      = time.Minute * 10 // 默认 reserved 队列中消息的超时时间
-)
-
-var (
-	//go:embed delayer_pop.lua
-	popLuaScript string
-	popScript    = redis.NewScript(popLuaScript)
-
-	//go:embed delayer_release.lua
-	releaseLuaScript string
-	releaseScript    = redis.NewScript(releaseLuaScript)
+	defaultBatchSize            = 100                    // 默认单次消费数量
+	defaultConcurrency          = 100                    // 默认并发处理协程数
+	defaultHandlerTimeout       = time.Second * 5        // 默认单条消息处理超时时间
+	defaultMaxRetryAttempts     = 0                      // 默认最大重试次数
+	defaultRetryDelay           = time.Second * 30       // 默认重试延迟时间
+	defaultReservedTimeout      = time.Minute * 10       // 默认 reserved 队列中消息的可见性超时时间
+	defaultMaxReservedExpiries  = 3                      // 默认可见性超时最大次数，超过后移入死信队列
+	defaultShards               = 1                      // 默认分片数
+	defaultMySQLTable           = "delay_message"        // 默认 MySQL 存储表名
+	defaultDrainTimeout         = time.Second * 10       // 默认 Stop 时等待处理中消息完成的时间
 )
 
 type (
@@ -64,6 +55,16 @@ type (
 		Data      any    `json:"data"`
 		Timestamp int64  `json:"timestamp"`
 		Attempts  int    `json:"attempts"`
+		// Expiries 可见性超时被重投递的次数
+		Expiries int `json:"expiries,omitempty"`
+		// Codec Data 的编解码器名称，为空表示普通 JSON 消息；非空时 Data 解码为 json.RawMessage 保留原始负载
+		Codec string `json:"codec,omitempty"`
+		// Version 负载 schema 版本，供消费端兼容负载演进
+		Version int `json:"version,omitempty"`
+		// Headers 消息头，Push 时写入 trace context，处理时据此恢复 trace
+		Headers map[string]string `json:"headers,omitempty"`
+		// Priority 优先级，决定消息所在的 delayed lane，重试/重投递时保持不变
+		Priority Priority `json:"priority,omitempty"`
 	}
 
 	// MessageHandler 消息到期处理回调。
@@ -99,22 +100,62 @@ type (
 		// RetryDelayDuration 重试延迟时长。
 		RetryDelayDuration time.Duration
 
-		// ReservedTimeout reserved 队列中消息的超时时间，超时未 ack 的消息将被直接删除并记录 error。
+		// ReservedTimeout reserved 队列中消息的可见性超时时间，超时未 ack 的消息将被重投递到 delayed。
 		ReservedTimeout time.Duration
+
+		// MaxReservedExpiries 可见性超时最大次数，超过后消息移入死信队列 dead。
+		MaxReservedExpiries int
+
+		// Shards 分片数，大于 1 时消息按 Key 哈希分散到 {delay:queue:prefix:i} 多个 hash slot，仅 Redis 存储生效。
+		// 注意：调整分片数不会迁移已有消息，旧 key 中的消息需自行处理。
+		Shards int
+
+		// ShardBalance 是否使用 rendezvous 哈希把分片分配给存活的消费者实例，降低多实例 Lua 竞争。
+		ShardBalance bool
+
+		// MySQLTable MySQL 存储使用的表名，默认 delay_message。
+		MySQLTable string
+
+		// StatsInterval 消费中定期把队列统计导出为 Prometheus gauge 的间隔，仅开启 Prometheus 时生效。
+		StatsInterval time.Duration
+
+		// PriorityWeights 各优先级 lane 的出队权重，默认 high:normal:low = 6:3:1。
+		PriorityWeights map[Priority]int
+
+		// KeyLimits 按 Message.Key 配置的并发和速率限制（单实例），未配置的 Key 使用 DefaultKeyLimit。
+		KeyLimits map[string]KeyLimit
+
+		// DefaultKeyLimit 未单独配置的 Key 的限制，零值表示不限制。
+		DefaultKeyLimit KeyLimit
+
+		// ThrottleDelay 被限流的消息放回 delayed 的延迟，不计入重试次数。
+		ThrottleDelay time.Duration
+
+		// DrainTimeout Stop 时等待处理中消息完成的时间，超时后 handler 未返回的消息立即放回 delayed 并取消 handler ctx，已返回的消息照常 ack。
+		DrainTimeout time.Duration
+	}
+
+	// reservation 处理中消息的 reserved 凭证，通过 context 传递给 Extend
+	reservation struct {
+		dl       *Delayer
+		delivery *Delivery
 	}
 
+	reservationKey struct{}
+
 	// Delayer 延迟队列实例。
 	Delayer struct {
-		mu     sync.Mutex
-		state  int32
-		cancel context.CancelFunc
-		wg     sync.WaitGroup
-
-		client        *redis.Redis
-		metrics       *stat.Metrics
-		popScript     *redis.Script
-		releaseScript *redis.Script
-		logger        *delayLogger
+		mu           sync.Mutex
+		state        int32
+		cancel       context.CancelFunc // 停止拉取
+		handleCancel context.CancelFunc // 取消处理中 handler，drain 超时后调用
+		wg           sync.WaitGroup
+		inflight     sync.Map // 已取出尚未 ack 的 *Delivery -> *atomic.Bool（已由 handler 或 Stop 认领）
+
+		store   Store
+		limiter *keyLimiter
+		metrics *stat.Metrics
+		logger  *delayLogger
 
 		prefix               string
 		maxPushDelayDuration time.Duration
@@ -125,15 +166,15 @@ type (
 		maxRetryAttempts     int
 		retryDelayDuration   time.Duration
 		reservedTimeout      time.Duration
+		maxReservedExpiries  int
+		statsInterval        time.Duration
+		drainTimeout         time.Duration
+		throttleDelay        time.Duration
 	}
 )
 
-// NewDelayer 创建新的延迟队列实例
-func NewDelayer(redisClient *redis.Redis, opts ...OptionFunc) *Delayer {
-	if redisClient == nil {
-		panic("delay: redis client cannot be nil")
-	}
-
+// NewConfig 返回应用了 opts 的默认配置
+func NewConfig(opts ...OptionFunc) Config {
 	config := Config{
 		MaxPushDelayDuration: defaultMaxPushDelayDuration,
 		ConsumeInterval:      defaultConsumeInterval,
@@ -143,22 +184,42 @@ func NewDelayer(redisClient *redis.Redis, opts ...OptionFunc) *Delayer {
 		MaxRetryAttempts:     defaultMaxRetryAttempts,
 		RetryDelayDuration:   defaultRetryDelay,
 		ReservedTimeout:      defaultReservedTimeout,
+		MaxReservedExpiries:  defaultMaxReservedExpiries,
+		Shards:               defaultShards,
+		MySQLTable:           defaultMySQLTable,
+		StatsInterval:        defaultStatsInterval,
+		DrainTimeout:         defaultDrainTimeout,
+		ThrottleDelay:        defaultThrottleDelay,
+		PriorityWeights: map[Priority]int{
+			PriorityHigh:   defaultHighWeight,
+			PriorityNormal: defaultNormalWeight,
+			PriorityLow:    defaultLowWeight,
+		},
 	}
 
 	for _, opt := range opts {
 		opt(&config)
 	}
 
+	return config
+}
+
+// NewDelayer 创建新的延迟队列实例
+func NewDelayer(store Store, opts ...OptionFunc) *Delayer {
+	if store == nil {
+		panic("delay: store cannot be nil")
+	}
+
+	config := NewConfig(opts...)
 	if len(config.Prefix) == 0 {
 		panic("delay prefix cannot be empty")
 	}
 
 	return &Delayer{
-		client:        redisClient,
-		metrics:       stat.NewMetrics(fmt.Sprintf("delay.%s", config.Prefix)),
-		popScript:     popScript,
-		releaseScript: releaseScript,
-		logger:        newDelayLogger(fmt.Sprintf("delay.%s", config.Prefix)),
+		store:   store,
+		limiter: newKeyLimiter(config.KeyLimits, config.DefaultKeyLimit),
+		metrics: stat.NewMetrics(fmt.Sprintf("delay.%s", config.Prefix)),
+		logger:  newDelayLogger(fmt.Sprintf("delay.%s", config.Prefix)),
 
 		prefix:               config.Prefix,
 		maxPushDelayDuration: config.MaxPushDelayDuration,
@@ -169,36 +230,52 @@ func NewDelayer(redisClient *redis.Redis, opts ...OptionFunc) *Delayer {
 		maxRetryAttempts:     config.MaxRetryAttempts,
 		retryDelayDuration:   config.RetryDelayDuration,
 		reservedTimeout:      config.ReservedTimeout,
+		maxReservedExpiries:  config.MaxReservedExpiries,
+		statsInterval:        config.StatsInterval,
+		drainTimeout:         config.DrainTimeout,
+		throttleDelay:        config.ThrottleDelay,
 	}
 }
 
 // Push 推送延迟消息
-func (dl *Delayer) Push(ctx context.Context, key string, data any, delayDuration time.Duration) error {
-	if len(key) == 0 {
-		return fmt.Errorf("delay.Push key must not be empty")
-	}
-	if delayDuration < time.Second || delayDuration > dl.maxPushDelayDuration {
-		return fmt.Errorf("delay.Push delayDuration must be at least 1 second and at most %v", dl.maxPushDelayDuration)
+func (dl *Delayer) Push(ctx context.Context, key string, data any, delayDuration time.Duration, opts ...PushOption) error {
+	msg := &Message{Key: key, Data: data}
+	for _, opt := range opts {
+		opt(msg)
 	}
 
-	ts := time.Now().Add(delayDuration).Unix()
-	msg := Message{
-		ID:        utils.GenUniqId(),
-		Key:       key,
-		Data:      data,
-		Timestamp: ts,
-		Attempts:  0,
+	return dl.PushMessage(ctx, msg, delayDuration)
+}
+
+// PushMessage 推送自定义信封的延迟消息，ID、Timestamp、Attempts 由 Delayer 填充
+func (dl *Delayer) PushMessage(ctx context.Context, msg *Message, delayDuration time.Duration) error {
+	ctx, span := delayTracer.Start(ctx, "push", trace.WithSpanKind(trace.SpanKindProducer))
+	defer span.End()
+
+	if err := dl.prepareMessage(ctx, msg, delayDuration); err != nil {
+		return err
 	}
-	mj, err := json.Marshal(msg)
-	if err != nil {
-		return fmt.Errorf("delay.Push Marshal error: %w", err)
+	if err := dl.store.Push(ctx, msg); err != nil {
+		return fmt.Errorf("delay.Push error: %w", err)
 	}
 
-	_, err = dl.client.ZaddCtx(ctx, dl.fmtQueueKey(delayQueueName), ts, string(mj))
-	if err != nil {
-		return fmt.Errorf("delay.Push ZaddCtx error: %w", err)
+	return nil
+}
+
+// prepareMessage 校验并填充待写入的消息，ctx 中的 trace context 写入消息 Headers
+func (dl *Delayer) prepareMessage(ctx context.Context, msg *Message, delayDuration time.Duration) error {
+	if len(msg.Key) == 0 {
+		return fmt.Errorf("delay.Push key must not be empty")
+	}
+	if delayDuration < time.Second || delayDuration > dl.maxPushDelayDuration {
+		return fmt.Errorf("delay.Push delayDuration must be at least 1 second and at most %v", dl.maxPushDelayDuration)
 	}
 
+	injectContextToMessage(ctx, msg)
+	msg.ID = utils.GenUniqId()
+	msg.Timestamp = time.Now().Add(delayDuration).Unix()
+	msg.Attempts = 0
+
 	return nil
 }
 
@@ -213,20 +290,29 @@ func (dl *Delayer) Start(handler MessageHandler) {
 		dl.mu.Unlock()
 		return
 	}
+	// 拉取与 handler 使用独立的 ctx，Stop 时先停止拉取，处理中的 handler 不受影响
 	ctx, cancel := context.WithCancel(context.Background())
+	handleCtx, handleCancel := context.WithCancel(context.Background())
 	dl.cancel = cancel
+	dl.handleCancel = handleCancel
 	dl.state = stateRunning
-	dl.wg.Add(1)
+	dl.wg.Add(2)
 	dl.mu.Unlock()
 
 	dl.logger.Infof("delay consumer start in the background")
 	go func() {
 		defer dl.wg.Done()
-		dl.loopFetch(ctx, handler)
+		dl.loopFetch(ctx, handleCtx, handler)
+	}()
+	go func() {
+		defer dl.wg.Done()
+		dl.loopStats(ctx)
 	}()
 }
 
 // Stop 停止后台消费并等待退出。幂等安全，多次调用不会 panic。
+// 先停止拉取，等待处理中的消息最多 DrainTimeout；超时后把 handler 尚未返回的消息立即放回 delayed（不计入重试次数），
+// 再取消 handler ctx，不必等待 ReservedTimeout 才被重投递；handler 已返回的消息照常 ack，不会重复处理。
 func (dl *Delayer) Stop() {
 	dl.mu.Lock()
 	if dl.state != stateRunning {
@@ -237,200 +323,239 @@ func (dl *Delayer) Stop() {
 	dl.mu.Unlock()
 
 	dl.cancel()
+	if !waitTimeout(&dl.wg, dl.drainTimeout) {
+		dl.logger.Errorf("delay.Stop drain timeout after %v, release in-flight messages", dl.drainTimeout)
+		dl.inflight.Range(func(k, v any) bool {
+			if v.(*atomic.Bool).CompareAndSwap(false, true) {
+				dl.releaseUnprocessed(k.(*Delivery))
+			}
+			return true
+		})
+	}
+	dl.handleCancel()
 	dl.wg.Wait()
+
+	if closer, ok := dl.store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			dl.logger.Errorf("delay.Stop store close error: %v", err)
+		}
+	}
 }
 
-func (dl *Delayer) loopFetch(ctx context.Context, handler MessageHandler) {
+func (dl *Delayer) loopFetch(ctx, handleCtx context.Context, handler MessageHandler) {
 	defer dl.logger.Infof("delay consumer stop")
 
 	ticker := time.NewTicker(dl.consumeInterval)
 	defer ticker.Stop()
 
-	var lastClean time.Time
+	var lastExpire time.Time
 
 	for {
 		select {
 		case <-ctx.Done():
 			return
 		case <-ticker.C:
-			dl.consumeOnce(ctx, handler, &lastClean)
+			dl.consumeOnce(ctx, handleCtx, handler, &lastExpire)
 		}
 	}
 }
 
-// consumeOnce 单次消费（含 reserved 超时清理），自带 panic 恢复，确保 loopFetch 循环不因 panic 中断
-func (dl *Delayer) consumeOnce(ctx context.Context, handler MessageHandler, lastClean *time.Time) {
+// consumeOnce 单次消费（含 reserved 可见性超时处理），自带 panic 恢复，确保 loopFetch 循环不因 panic 中断。
+// ctx 控制拉取，handleCtx 控制 handler；ctx 取消后本批次尚未开始处理的消息立即放回 delayed
+func (dl *Delayer) consumeOnce(ctx, handleCtx context.Context, handler MessageHandler, lastExpire *time.Time) {
 	defer func() {
 		if err := recover(); err != nil {
 			dl.logger.Errorf("delay.consumeOnce panic: %v", err)
 		}
 	}()
 
-	// 清理超时未 ack 的消息
-	if time.Since(*lastClean) >= dl.reservedTimeout/2 {
-		dl.removeStaleReserved()
-		*lastClean = time.Now()
+	// 重投递可见性超时未 ack 的消息
+	if time.Since(*lastExpire) >= maintainInterval {
+		dl.expireReserved(ctx)
+		*lastExpire = time.Now()
 	}
 
 	// 消费消息
-	taskJsonList := dl.pop()
-	if len(taskJsonList) == 0 {
+	deliveries := dl.pop(ctx)
+	if len(deliveries) == 0 {
 		return
 	}
 
+	for _, d := range deliveries {
+		dl.inflight.Store(d, new(atomic.Bool))
+	}
+
 	runner := threading.NewTaskRunner(dl.concurrency)
-	for _, taskJson := range taskJsonList {
-		task := taskJson
+	for _, d := range deliveries {
+		d := d
+		if ctx.Err() != nil {
+			dl.releaseUnprocessed(d)
+			dl.inflight.Delete(d)
+			continue
+		}
+
+		// 超过 Key 的并发/速率限制时放回 delayed，不占用其他 Key 的处理协程
+		release, ok := dl.acquireKey(d)
+		if !ok {
+			dl.throttle(d)
+			dl.inflight.Delete(d)
+			continue
+		}
+
 		runner.Schedule(func() {
-			dl.process(ctx, handler, task)
+			defer dl.inflight.Delete(d)
+			defer release()
+			dl.process(handleCtx, handler, d)
 		})
 	}
 	runner.Wait()
 }
 
-// pop 从 delayed 中取出到期消息并原子迁移到 reserved。
-// Lua 脚本返回的是 reserved 中的 taskJson 列表，供后续 process 处理。
-func (dl *Delayer) pop() []string {
-	ts := time.Now().Unix()
+// pop 从 delayed 中取出到期消息并原子迁移到 reserved，reserved 的 score 为可见性截止时间。
+func (dl *Delayer) pop(ctx context.Context) []*Delivery {
+	now := time.Now()
 
-	data, err := dl.client.ScriptRun(dl.popScript,
-		[]string{
-			dl.fmtQueueKey(delayQueueName),
-			dl.fmtQueueKey(reservedQueueName),
-		}, []string{
-			cast.ToString(ts),
-			cast.ToString(dl.batchSize),
-		})
+	deliveries, err := dl.store.Pop(ctx, now, dl.batchSize, now.Add(dl.reservedTimeout))
 	if err != nil {
-		dl.logger.Errorf("delay.pop ScriptRun error: %v", err)
-		return nil
+		dl.logger.Errorf("delay.pop error: %v", err)
 	}
 
-	return cast.ToStringSlice(data)
+	return deliveries
 }
 
-func (dl *Delayer) process(ctx context.Context, handler MessageHandler, taskJson string) {
+func (dl *Delayer) process(ctx context.Context, handler MessageHandler, d *Delivery) {
 	defer func() {
 		if err := recover(); err != nil {
-			dl.logger.Errorf("delay.process data: %s, panic: %v", taskJson, err)
+			dl.logger.Errorf("delay.process data: %s, panic: %v", d.Raw, err)
 		}
 	}()
 
+	// Stop 超时后会取消 ctx，ack 不随之取消，避免 handler 已返回的消息 ack 失败后被重复处理
+	ackCtx := context.WithoutCancel(ctx)
 	now := utils.Now()
-	var msg Message
-	err := json.Unmarshal([]byte(taskJson), &msg)
-	if err != nil {
-		dl.logger.Errorf("delay.process Unmarshal data: %s, error: %v", taskJson, err)
-		if ackErr := dl.successAck(taskJson, now); ackErr != nil {
-			dl.logger.Errorf("delay.process Unmarshal data: %s, successAck error: %v", taskJson, ackErr)
+	if d.Message == nil {
+		dl.logger.Errorf("delay.process Unmarshal data: %s, error: invalid message", d.Raw)
+		if ackErr := dl.successAck(ackCtx, d, now); ackErr != nil {
+			dl.logger.Errorf("delay.process Unmarshal data: %s, successAck error: %v", d.Raw, ackErr)
 		}
 		return
 	}
 
+	msg := *d.Message
 	if len(msg.Key) == 0 {
-		dl.logger.Errorf("delay.process invalid empty key, data: %s", taskJson)
-		if ackErr := dl.successAck(taskJson, now); ackErr != nil {
-			dl.logger.Errorf("delay.process invalid empty key, data: %s, successAck error: %v", taskJson, ackErr)
+		dl.logger.Errorf("delay.process invalid empty key, data: %s", d.Raw)
+		if ackErr := dl.successAck(ackCtx, d, now); ackErr != nil {
+			dl.logger.Errorf("delay.process invalid empty key, data: %s, successAck error: %v", d.Raw, ackErr)
 		}
 		return
 	}
 
 	dl.logger.Infof("delay.process forward message: %+v", msg)
 
-	handlerCtx, cancel := context.WithTimeout(ctx, dl.handlerTimeout)
+	spanCtx, span := startConsumeSpan(ctx, &msg)
+	defer span.End()
+
+	handlerCtx, cancel := context.WithTimeout(spanCtx, dl.handlerTimeout)
 	defer cancel()
+	handlerCtx = context.WithValue(handlerCtx, reservationKey{}, &reservation{dl: dl, delivery: d})
 
-	err = handler(handlerCtx, &msg)
+	handleStart := time.Now()
+	err := handler(handlerCtx, &msg)
+	dl.recordHandlerMetrics(msg.Key, time.Since(handleStart), err)
+	if !dl.settle(d) {
+		dl.logger.Errorf("delay.process handler message: %+v, released by Stop before handler returned, skip ack", msg)
+		return
+	}
 	if err != nil {
 		dl.logger.Errorf("delay.process handler message: %+v, error: %v", msg, err)
-		if ackErr := dl.failAck(taskJson, now); ackErr != nil {
+		if ackErr := dl.failAck(ackCtx, d, now); ackErr != nil {
 			dl.logger.Errorf("delay.process handler message: %+v, failAck error: %v", msg, ackErr)
 		}
 	} else {
-		if ackErr := dl.successAck(taskJson, now); ackErr != nil {
+		if ackErr := dl.successAck(ackCtx, d, now); ackErr != nil {
 			dl.logger.Errorf("delay.process handler message: %+v, successAck error: %v", msg, ackErr)
 		}
 	}
 }
 
-func (dl *Delayer) successAck(taskJson string, startTime time.Duration) error {
-	if len(taskJson) == 0 {
-		return fmt.Errorf("delay.successAck taskJson is empty")
-	}
+// settle 认领 handler 已返回的消息，返回 false 表示 Stop 已把它放回 delayed，不再 ack
+func (dl *Delayer) settle(d *Delivery) bool {
+	v, ok := dl.inflight.Load(d)
+	return !ok || v.(*atomic.Bool).CompareAndSwap(false, true)
+}
 
-	err := dl.removeFromReserved(taskJson)
+func (dl *Delayer) successAck(ctx context.Context, d *Delivery, startTime time.Duration) error {
+	err := dl.store.Ack(ctx, d)
 	dl.recordMetrics(startTime, err != nil)
 
 	return err
 }
 
-func (dl *Delayer) failAck(taskJson string, startTime time.Duration) error {
-	if len(taskJson) == 0 {
-		return fmt.Errorf("delay.failAck taskJson is empty")
-	}
-
-	var msg Message
-	err := json.Unmarshal([]byte(taskJson), &msg)
-	if err != nil {
-		removeErr := dl.removeFromReserved(taskJson)
+func (dl *Delayer) failAck(ctx context.Context, d *Delivery, startTime time.Duration) error {
+	if d.Message == nil {
+		err := dl.store.Ack(ctx, d)
 		dl.recordMetrics(startTime, true)
-		return fmt.Errorf("delay.failAck Unmarshal error: %w, removeFromReserved error: %w", err, removeErr)
+		return fmt.Errorf("delay.failAck invalid message, Ack error: %w", err)
 	}
 
-	if msg.Attempts >= dl.maxRetryAttempts {
-		removeErr := dl.removeFromReserved(taskJson)
+	if d.Message.Attempts >= dl.maxRetryAttempts {
+		err := dl.store.Ack(ctx, d)
 		dl.recordMetrics(startTime, true)
-		return fmt.Errorf("delay.failAck max delivery attempts exceeded, max: %d, removeFromReserved error: %w", dl.maxRetryAttempts, removeErr)
+		return fmt.Errorf("delay.failAck max delivery attempts exceeded, max: %d, Ack error: %w", dl.maxRetryAttempts, err)
 	}
 
+	msg := *d.Message
 	msg.Attempts++
-	newTimestamp := time.Now().Add(dl.retryDelayDuration).Unix()
-	msg.Timestamp = newTimestamp
-
-	newTaskJson, err := json.Marshal(msg)
-	if err != nil {
-		removeErr := dl.removeFromReserved(taskJson)
-		dl.recordMetrics(startTime, true)
-		return fmt.Errorf("delay.failAck Marshal error: %w, removeFromReserved error: %w", err, removeErr)
-	}
-
-	err = retry.Do(func() error {
-		_, err := dl.client.ScriptRun(dl.releaseScript,
-			[]string{
-				dl.fmtQueueKey(reservedQueueName),
-				dl.fmtQueueKey(delayQueueName),
-			}, []string{
-				taskJson,
-				cast.ToString(newTimestamp),
-				string(newTaskJson),
-			})
-		return err
-	}, retry.Attempts(2), retry.Delay(10*time.Millisecond))
+	msg.Timestamp = time.Now().Add(dl.retryDelayDuration).Unix()
 
+	_, err := dl.store.Release(ctx, d, &msg)
 	dl.recordMetrics(startTime, true)
 
 	return err
 }
 
-func (dl *Delayer) removeFromReserved(taskJson string) error {
-	return retry.Do(func() error {
-		_, err := dl.client.Zrem(dl.fmtQueueKey(reservedQueueName), taskJson)
-		return err
-	}, retry.Attempts(2), retry.Delay(10*time.Millisecond))
+// expireReserved 处理 reserved 中可见性超时未 ack 的消息：
+// Attempts、Expiries 加一后立即重投递到 delayed；Expiries 超过 maxReservedExpiries 的移入 dead。
+func (dl *Delayer) expireReserved(ctx context.Context) {
+	requeued, dead, err := dl.store.ExpireReserved(ctx, time.Now(), dl.batchSize, dl.maxReservedExpiries)
+	if err != nil {
+		dl.logger.Errorf("delay.expireReserved error: %v", err)
+	}
+
+	if requeued > 0 || dead > 0 {
+		dl.logger.Errorf("delay.expireReserved requeued %d, dead %d expired messages from reserved", requeued, dead)
+	}
+}
+
+// acquireKey 按消息 Key 占用处理名额，无法解析的消息不受限制，由 process 处理
+func (dl *Delayer) acquireKey(d *Delivery) (func(), bool) {
+	if d.Message == nil || len(d.Message.Key) == 0 {
+		return func() {}, true
+	}
+	return dl.limiter.acquire(d.Message.Key)
 }
 
-// removeStaleReserved 清理 reserved 队列中超时未 ack 的消息（直接删除 + 记录 error）
-func (dl *Delayer) removeStaleReserved() {
-	threshold := time.Now().Add(-dl.reservedTimeout).Unix()
+// throttle 把被限流的消息延迟 ThrottleDelay 后放回 delayed，不增加 Attempts
+func (dl *Delayer) throttle(d *Delivery) {
+	msg := *d.Message
+	msg.Timestamp = time.Now().Add(dl.throttleDelay).Unix()
+	if _, err := dl.store.Release(context.Background(), d, &msg); err != nil {
+		dl.logger.Errorf("delay.throttle data: %s, error: %v", d.Raw, err)
+	}
+	metricHandlerThrottled.Inc(dl.prefix, msg.Key)
+}
 
-	count, err := dl.client.Zremrangebyscore(dl.fmtQueueKey(reservedQueueName), 0, threshold)
-	if err != nil {
-		dl.logger.Errorf("delay.removeStaleReserved Zremrangebyscore error: %v", err)
+// releaseUnprocessed 把未处理完的消息原样放回 delayed 立即到期，不增加 Attempts。
+// Release 以 reserved 凭证为条件，handler 之后的 ack/重试不会重复投递
+func (dl *Delayer) releaseUnprocessed(d *Delivery) {
+	if d.Message == nil {
 		return
 	}
 
-	if count > 0 {
-		dl.logger.Errorf("delay.removeStaleReserved removed %d stale messages from reserved", count)
+	msg := *d.Message
+	msg.Timestamp = time.Now().Unix()
+	if _, err := dl.store.Release(context.Background(), d, &msg); err != nil {
+		dl.logger.Errorf("delay.releaseUnprocessed data: %s, error: %v", d.Raw, err)
 	}
 }
 
@@ -441,11 +566,7 @@ func (dl *Delayer) recordMetrics(startTime time.Duration, drop bool) {
 	})
 }
 
-func (dl *Delayer) fmtQueueKey(queueType string) string {
-	return fmt.Sprintf(queueKey, dl.prefix, queueType)
-}
-
-// WithPrefix 配置队列 redis 名称前缀
+// WithPrefix 配置队列名称前缀
 func WithPrefix(prefix string) OptionFunc {
 	return func(config *Config) {
 		config.Prefix = prefix
@@ -515,7 +636,7 @@ func WithRetryDelay(d time.Duration) OptionFunc {
 	}
 }
 
-// WithReservedTimeout 配置 reserved 队列中消息的超时时间，超时未 ack 的消息将被直接删除并记录 error
+// WithReservedTimeout 配置 reserved 队列中消息的可见性超时时间，超时未 ack 的消息将被重投递到 delayed
 func WithReservedTimeout(d time.Duration) OptionFunc {
 	return func(config *Config) {
 		if d >= time.Minute {
@@ -523,3 +644,124 @@ func WithReservedTimeout(d time.Duration) OptionFunc {
 		}
 	}
 }
+
+// WithShards 配置分片数，大于 1 时消息按 Key 哈希分散到多个 Redis hash slot
+func WithShards(shards int) OptionFunc {
+	return func(config *Config) {
+		if shards > 0 {
+			config.Shards = shards
+		}
+	}
+}
+
+// WithShardBalance 配置是否按 rendezvous 哈希为每个消费者实例分配分片，仅 Shards>1 时生效
+func WithShardBalance(enable bool) OptionFunc {
+	return func(config *Config) {
+		config.ShardBalance = enable
+	}
+}
+
+// WithMySQLTable 配置 MySQL 存储使用的表名
+func WithMySQLTable(table string) OptionFunc {
+	return func(config *Config) {
+		if len(table) > 0 {
+			config.MySQLTable = table
+		}
+	}
+}
+
+// WithPriorityWeights 配置 high、normal、low 三个 lane 的出队权重，权重需大于 0
+func WithPriorityWeights(high, normal, low int) OptionFunc {
+	return func(config *Config) {
+		if high > 0 && normal > 0 && low > 0 {
+			config.PriorityWeights = map[Priority]int{
+				PriorityHigh:   high,
+				PriorityNormal: normal,
+				PriorityLow:    low,
+			}
+		}
+	}
+}
+
+// WithKeyLimit 配置单个 Message.Key 的并发和速率限制（单实例维度）
+func WithKeyLimit(key string, limit KeyLimit) OptionFunc {
+	return func(config *Config) {
+		if config.KeyLimits == nil {
+			config.KeyLimits = make(map[string]KeyLimit)
+		}
+		config.KeyLimits[key] = limit
+	}
+}
+
+// WithDefaultKeyLimit 配置未单独设置限制的 Key 的默认并发和速率限制（单实例维度）
+func WithDefaultKeyLimit(limit KeyLimit) OptionFunc {
+	return func(config *Config) {
+		config.DefaultKeyLimit = limit
+	}
+}
+
+// WithThrottleDelay 配置被限流的消息放回 delayed 的延迟，默认 1 秒
+func WithThrottleDelay(d time.Duration) OptionFunc {
+	return func(config *Config) {
+		if d >= time.Second {
+			config.ThrottleDelay = d
+		}
+	}
+}
+
+// WithDrainTimeout 配置 Stop 时等待处理中消息完成的时间，默认 10 秒
+func WithDrainTimeout(d time.Duration) OptionFunc {
+	return func(config *Config) {
+		if d > 0 {
+			config.DrainTimeout = d
+		}
+	}
+}
+
+// WithStatsInterval 配置队列统计导出为 Prometheus gauge 的间隔，默认 30 秒
+func WithStatsInterval(d time.Duration) OptionFunc {
+	return func(config *Config) {
+		if d > 0 {
+			config.StatsInterval = d
+		}
+	}
+}
+
+// WithMaxReservedExpiries 配置可见性超时最大次数，超过后消息移入死信队列
+func WithMaxReservedExpiries(expiries int) OptionFunc {
+	return func(config *Config) {
+		if expiries > 0 {
+			config.MaxReservedExpiries = expiries
+		}
+	}
+}
+
+// Extend 延长当前处理中消息的可见性截止时间为 now+d，供长耗时 handler 作为心跳调用。
+// ctx 必须是 MessageHandler 收到的 ctx；注意 HandlerTimeout 依然生效，长任务需同时调大。
+func Extend(ctx context.Context, d time.Duration) error {
+	r, ok := ctx.Value(reservationKey{}).(*reservation)
+	if !ok || r == nil {
+		return fmt.Errorf("delay.Extend ctx is not a delay handler context")
+	}
+	if d <= 0 {
+		return fmt.Errorf("delay.Extend duration must be positive")
+	}
+
+	return r.dl.store.Extend(ctx, r.delivery, time.Now().Add(d))
+}
+
+// waitTimeout 等待 wg 完成，超时返回 false
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,12 @@
+-- KEYS[1] - The reserved queue key
+-- ARGV[1] - The taskJson
+-- ARGV[2] - The new visibility deadline UNIX timestamp
+
+-- 仅当消息仍在 reserved 中时才延长，避免把已 ack 或已重投递的消息写回 reserved
+if redis.call('zscore', KEYS[1], ARGV[1]) == false then
+    return 0
+end
+
+redis.call('zadd', KEYS[1], ARGV[2], ARGV[1])
+
+return 1
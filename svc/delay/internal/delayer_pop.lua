@@ -2,6 +2,7 @@
 -- KEYS[2] - The destination queue (e.g., {delay:queue:prefix}:reserved)
 -- ARGV[1] - The threshold UNIX timestamp
 -- ARGV[2] - The batch size
+-- ARGV[3] - The visibility deadline UNIX timestamp (reserved 中的 score)
 
 local val = redis.call('zrangebyscore', KEYS[1], '-inf', ARGV[1], 'limit', 0, ARGV[2])
 local taskVal = {}
@@ -10,7 +11,7 @@ if next(val) ~= nil then
     redis.call('zremrangebyrank', KEYS[1], 0, #val - 1)
     for i = 1, #val do
         table.insert(taskVal, val[i])
-        redis.call('zadd', KEYS[2], ARGV[1], val[i])
+        redis.call('zadd', KEYS[2], ARGV[3], val[i])
     end
 end
 
@@ -0,0 +1,8 @@
+-- KEYS[n]  - The delayed lane queues (e.g., {delay:queue:prefix}:delayed)，须位于同一 hash slot
+-- ARGV     - 三元组序列：lane 在 KEYS 中的下标、到期 UNIX timestamp、taskJson
+
+for i = 1, #ARGV, 3 do
+    redis.call('zadd', KEYS[tonumber(ARGV[i])], ARGV[i + 1], ARGV[i + 2])
+end
+
+return #ARGV / 3
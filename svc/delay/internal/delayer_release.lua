@@ -1,13 +1,15 @@
 -- KEYS[1] - The reserved queue key (要删除旧值的队列)
--- KEYS[2] - The delay queue key (要重新投递的队列)
+-- KEYS[2] - The target queue key (要重新投递的队列，delayed 或 dead)
 -- ARGV[1] - The old taskJson (需要删除的旧值)
 -- ARGV[2] - The new timestamp (新的时间戳)
 -- ARGV[3] - The new taskJson (需要添加的新值)
 
--- 删除 reserved 队列中的旧值
-redis.call('zrem', KEYS[1], ARGV[1])
+-- 删除 reserved 队列中的旧值，旧值已不存在说明已被 ack 或已被重投递，不再重复写入
+if redis.call('zrem', KEYS[1], ARGV[1]) == 0 then
+    return 0
+end
 
--- 添加新的任务到 delay 队列（使用新的 taskJson）
+-- 添加新的任务到目标队列（使用新的 taskJson）
 redis.call('zadd', KEYS[2], ARGV[2], ARGV[3])
 
-return true
+return 1
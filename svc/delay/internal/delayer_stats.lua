@@ -0,0 +1,33 @@
+-- KEYS[1]  - The reserved queue (e.g., {delay:queue:prefix}:reserved)
+-- KEYS[2]  - The dead queue (e.g., {delay:queue:prefix}:dead)
+-- KEYS[n]  - The delayed lane queues (e.g., {delay:queue:prefix}:delayed)，须位于同一 hash slot
+-- ARGV[1]  - 当前 UNIX timestamp
+-- ARGV[n]  - 到期分布桶的区间对 [min, max]（UNIX timestamp）
+-- 返回 reserved 数、dead 数，之后每个 lane 依次为：消息数、最早的已到期时间（没有时为 0）、已到期数、各桶消息数
+
+local now = tonumber(ARGV[1])
+local buckets = (#ARGV - 1) / 2
+local result = {redis.call('zcard', KEYS[1]), redis.call('zcard', KEYS[2])}
+
+for i = 3, #KEYS do
+    local n = redis.call('zcard', KEYS[i])
+    result[#result + 1] = n
+    if n == 0 then
+        for _ = 1, 2 + buckets do
+            result[#result + 1] = 0
+        end
+    else
+        local oldest = 0
+        local first = redis.call('zrange', KEYS[i], 0, 0, 'WITHSCORES')
+        if #first > 0 and tonumber(first[2]) <= now then
+            oldest = tonumber(first[2])
+        end
+        result[#result + 1] = oldest
+        result[#result + 1] = redis.call('zcount', KEYS[i], 0, now)
+        for j = 2, #ARGV, 2 do
+            result[#result + 1] = redis.call('zcount', KEYS[i], ARGV[j], ARGV[j + 1])
+        end
+    end
+end
+
+return result
@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestDelayerStopDrain(t *testing.T) {
+	ctx := context.Background()
+
+	newDelayer := func(t *testing.T) (*Delayer, Store) {
+		mr := miniredis.RunT(t)
+		store := NewRedisStore(redis.New(mr.Addr()), WithPrefix("drain"))
+		dl := NewDelayer(store,
+			WithPrefix("drain"),
+			WithConsumeInterval(time.Millisecond*10),
+			WithHandlerTimeout(time.Minute),
+			WithDrainTimeout(time.Millisecond*200),
+		)
+		if err := store.Push(ctx, &Message{ID: "id", Key: "k", Timestamp: time.Now().Add(-time.Second).Unix()}); err != nil {
+			t.Fatalf("Push error: %v", err)
+		}
+		return dl, store
+	}
+
+	t.Run("in-flight handler finishes", func(t *testing.T) {
+		dl, store := newDelayer(t)
+		started, finished := make(chan struct{}), make(chan error, 1)
+		dl.Start(func(ctx context.Context, msg *Message) error {
+			close(started)
+			time.Sleep(time.Millisecond * 50)
+			finished <- ctx.Err()
+			return nil
+		})
+		<-started
+		dl.Stop()
+
+		if err := <-finished; err != nil {
+			t.Fatalf("handler ctx canceled during drain: %v", err)
+		}
+		stats, err := store.Stats(ctx, time.Now())
+		if err != nil || stats.Delayed+stats.Reserved != 0 {
+			t.Fatalf("Stats got %+v, %v, want message acked", stats, err)
+		}
+	})
+
+	t.Run("drain timeout releases message", func(t *testing.T) {
+		dl, store := newDelayer(t)
+		started := make(chan struct{})
+		dl.Start(func(ctx context.Context, msg *Message) error {
+			close(started)
+			<-ctx.Done()
+			return ctx.Err()
+		})
+		<-started
+		dl.Stop()
+
+		deliveries, err := store.Pop(ctx, time.Now(), 10, time.Now().Add(time.Minute))
+		if err != nil || len(deliveries) != 1 || deliveries[0].Message.Attempts != 0 {
+			t.Fatalf("Pop after Stop got %+v, %v, want one released message with attempts 0", deliveries, err)
+		}
+	})
+
+	t.Run("drain timeout keeps returned handler ack", func(t *testing.T) {
+		dl, store := newDelayer(t)
+		// handler 已返回但 ack 超过 DrainTimeout，Stop 不应放回该消息
+		dl.store = slowAckStore{Store: store, delay: time.Millisecond * 400}
+		returned := make(chan struct{})
+		dl.Start(func(ctx context.Context, msg *Message) error {
+			close(returned)
+			return nil
+		})
+		<-returned
+		dl.Stop()
+
+		stats, err := store.Stats(ctx, time.Now())
+		if err != nil || stats.Delayed+stats.Reserved != 0 {
+			t.Fatalf("Stats got %+v, %v, want message acked once", stats, err)
+		}
+	})
+}
+
+// slowAckStore 延迟 ack，模拟 handler 返回后 ack 未完成时 Stop 超时
+type slowAckStore struct {
+	Store
+	delay time.Duration
+}
+
+func (s slowAckStore) Ack(ctx context.Context, d *Delivery) error {
+	time.Sleep(s.delay)
+	return s.Store.Ack(ctx, d)
+}
+
+func TestDelayerRedeliver(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.New(mr.Addr()), WithPrefix("redeliver"))
+	dl := NewDelayer(store, WithPrefix("redeliver"), WithMaxReservedExpiries(1))
+	// 出队即超过可见性截止时间，模拟 handler 未在 ReservedTimeout 内 ack
+	dl.reservedTimeout = -time.Second
+	if err := store.Push(ctx, &Message{ID: "id", Key: "k", Timestamp: time.Now().Add(-time.Second).Unix()}); err != nil {
+		t.Fatalf("Push error: %v", err)
+	}
+	stats := func() *Stats {
+		t.Helper()
+		s, err := store.Stats(ctx, time.Now())
+		if err != nil {
+			t.Fatalf("Stats error: %v", err)
+		}
+		return s
+	}
+
+	first := dl.pop(ctx)
+	if len(first) != 1 {
+		t.Fatalf("pop got %d messages, want 1", len(first))
+	}
+
+	// 可见性超时后重投递，Attempts、Expiries 加一
+	dl.expireReserved(ctx)
+	second := dl.pop(ctx)
+	if len(second) != 1 || second[0].Message.Attempts != 1 || second[0].Message.Expiries != 1 {
+		t.Fatalf("pop after expire got %+v, want redelivered message", second)
+	}
+
+	// 超时前的投递凭证已失效，迟到的 ack、release 不影响重投递的消息
+	if err := dl.successAck(ctx, first[0], 0); err != nil {
+		t.Fatalf("late successAck error: %v", err)
+	}
+	dl.releaseUnprocessed(first[0])
+	if s := stats(); s.Reserved != 1 || s.Delayed != 0 {
+		t.Fatalf("Stats after late ack got %+v, want redelivered message reserved", s)
+	}
+
+	// 超过 MaxReservedExpiries 后移入死信，不再投递
+	dl.expireReserved(ctx)
+	if s := stats(); s.Dead != 1 || s.Reserved != 0 || s.Delayed != 0 {
+		t.Fatalf("Stats after expire got %+v, want dead message", s)
+	}
+	if again := dl.pop(ctx); len(again) != 0 {
+		t.Fatalf("pop dead message got %+v", again)
+	}
+}
+
+func TestDelayerKeyLimit(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.New(mr.Addr()), WithPrefix("limit"))
+	dl := NewDelayer(store,
+		WithPrefix("limit"),
+		WithKeyLimit("hot", KeyLimit{Concurrency: 1}),
+		WithThrottleDelay(time.Minute),
+	)
+
+	for i, key := range []string{"hot", "hot", "hot", "cold", "cold"} {
+		msg := &Message{ID: fmt.Sprintf("%s-%d", key, i), Key: key, Timestamp: time.Now().Add(-time.Second).Unix()}
+		if err := store.Push(ctx, msg); err != nil {
+			t.Fatalf("Push error: %v", err)
+		}
+	}
+
+	var handled sync.Map
+	var lastExpire time.Time
+	dl.consumeOnce(ctx, ctx, func(ctx context.Context, msg *Message) error {
+		time.Sleep(time.Millisecond * 50)
+		v, _ := handled.LoadOrStore(msg.Key, new(atomic.Int32))
+		v.(*atomic.Int32).Add(1)
+		return nil
+	}, &lastExpire)
+
+	count := func(key string) int32 {
+		v, ok := handled.Load(key)
+		if !ok {
+			return 0
+		}
+		return v.(*atomic.Int32).Load()
+	}
+	if count("hot") != 1 || count("cold") != 2 {
+		t.Fatalf("handled hot %d, cold %d, want hot 1, cold 2", count("hot"), count("cold"))
+	}
+
+	stats, err := store.Stats(ctx, time.Now())
+	if err != nil || stats.Delayed != 2 || stats.Reserved != 0 || stats.Due != 0 {
+		t.Fatalf("Stats got %+v, %v, want 2 throttled messages delayed", stats, err)
+	}
+	deliveries, err := store.Pop(ctx, time.Now().Add(time.Minute), 10, time.Now().Add(time.Hour))
+	if err != nil || len(deliveries) != 2 || deliveries[0].Message.Attempts != 0 {
+		t.Fatalf("Pop throttled got %+v, %v, want 2 messages with attempts 0", deliveries, err)
+	}
+}
+
+func TestKeyLimiterSweep(t *testing.T) {
+	l := newKeyLimiter(nil, KeyLimit{Concurrency: 1, Rate: 1})
+
+	idle, ok := l.acquire("idle")
+	if !ok {
+		t.Fatalf("acquire idle failed")
+	}
+	idle()
+	if _, ok := l.acquire("running"); !ok {
+		t.Fatalf("acquire running failed")
+	}
+
+	// 刚使用过的 Key 不清理
+	l.sweep(time.Now())
+	if len(l.states) != 2 {
+		t.Fatalf("states got %d, want 2", len(l.states))
+	}
+
+	// 空闲且令牌已填满的 Key 被清理，处理中的 Key 保留
+	l.sweep(time.Now().Add(keyStateIdleTimeout))
+	if _, ok := l.states["idle"]; ok || len(l.states) != 1 {
+		t.Fatalf("states got %v, want only running key", l.states)
+	}
+}
+
+func TestDelayerPushBatch(t *testing.T) {
+	ctx := context.Background()
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.New(mr.Addr()), WithPrefix("batch"))
+	dl := NewDelayer(store, WithPrefix("batch"))
+
+	items := make([]PushItem, 0, pushBatchChunkSize+2)
+	for i := 0; i < pushBatchChunkSize+1; i++ {
+		items = append(items, PushItem{Key: fmt.Sprintf("k%d", i), Data: i, Delay: time.Minute})
+	}
+	items = append(items, PushItem{Key: "", Data: "invalid", Delay: time.Minute})
+
+	results := dl.PushBatch(ctx, items)
+	for i, result := range results[:len(results)-1] {
+		if result.Err != nil || len(result.ID) == 0 {
+			t.Fatalf("PushBatch item %d got %+v, want success", i, result)
+		}
+	}
+	if last := results[len(results)-1]; last.Err == nil {
+		t.Fatalf("PushBatch invalid item got %+v, want error", last)
+	}
+
+	if _, err := dl.PushBatchAtomic(ctx, []PushItem{{Key: "a", Delay: time.Minute}, {Key: "b"}}); err == nil {
+		t.Fatalf("PushBatchAtomic with invalid item succeeded, want error")
+	}
+	stats, err := store.Stats(ctx, time.Now())
+	if err != nil || stats.Delayed != int64(pushBatchChunkSize+1) {
+		t.Fatalf("Stats got %+v, %v, want %d delayed", stats, err, pushBatchChunkSize+1)
+	}
+}
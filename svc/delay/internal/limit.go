@@ -0,0 +1,114 @@
+package internal
+
+import (
+	"math"
+	"sync"
+	"time"
+
+	"golang.org/x/time/rate"
+)
+
+const (
+	defaultThrottleDelay  = time.Second     // 默认被限流消息放回 delayed 的延迟
+	keyStateIdleTimeout   = time.Minute     // Key 状态空闲超过该时间后可被清理
+	keyStateSweepInterval = time.Minute * 5 // 清理空闲 Key 状态的间隔
+)
+
+type (
+	// KeyLimit 单个 Message.Key 的消费限制，均为单实例维度，零值表示不限制
+	KeyLimit struct {
+		// Concurrency 同一 Key 同时处理的最大消息数
+		Concurrency int
+		// Rate 同一 Key 每秒最多开始处理的消息数（令牌桶）
+		Rate float64
+		// Burst 令牌桶容量，默认 ceil(Rate)
+		Burst int
+	}
+
+	// keyLimiter 按 Key 维护并发计数和令牌桶
+	keyLimiter struct {
+		mu           sync.Mutex
+		limits       map[string]KeyLimit
+		defaultLimit KeyLimit
+		states       map[string]*keyState
+		lastSweep    time.Time
+	}
+
+	keyState struct {
+		running  int
+		limiter  *rate.Limiter
+		lastSeen time.Time
+	}
+)
+
+func newKeyLimiter(limits map[string]KeyLimit, defaultLimit KeyLimit) *keyLimiter {
+	return &keyLimiter{
+		limits:       limits,
+		defaultLimit: defaultLimit,
+		states:       make(map[string]*keyState),
+		lastSweep:    time.Now(),
+	}
+}
+
+// acquire 尝试为 key 占用一个处理名额，成功时返回的 release 需在处理结束后调用
+func (l *keyLimiter) acquire(key string) (release func(), ok bool) {
+	limit, ok := l.limits[key]
+	if !ok {
+		limit = l.defaultLimit
+	}
+	if limit.Concurrency <= 0 && limit.Rate <= 0 {
+		return func() {}, true
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(l.lastSweep) >= keyStateSweepInterval {
+		l.sweep(now)
+	}
+
+	state, ok := l.states[key]
+	if !ok {
+		state = &keyState{}
+		if limit.Rate > 0 {
+			burst := limit.Burst
+			if burst <= 0 {
+				burst = int(math.Ceil(limit.Rate))
+			}
+			state.limiter = rate.NewLimiter(rate.Limit(limit.Rate), burst)
+		}
+		l.states[key] = state
+	}
+	state.lastSeen = now
+
+	// 先判断并发，避免并发已满时白白消耗令牌
+	if limit.Concurrency > 0 && state.running >= limit.Concurrency {
+		return nil, false
+	}
+	if state.limiter != nil && !state.limiter.Allow() {
+		return nil, false
+	}
+
+	state.running++
+	return func() {
+		l.mu.Lock()
+		state.running--
+		l.mu.Unlock()
+	}, true
+}
+
+// sweep 清理空闲的 Key 状态，避免 Key 基数大时内存无限增长；调用方需持有锁。
+// 只清理无处理中消息且令牌桶已填满的 Key，重建后的状态与清理前等价
+func (l *keyLimiter) sweep(now time.Time) {
+	l.lastSweep = now
+	for key, state := range l.states {
+		if state.running > 0 || now.Sub(state.lastSeen) < keyStateIdleTimeout {
+			continue
+		}
+		if state.limiter != nil && state.limiter.TokensAt(now) < float64(state.limiter.Burst()) {
+			continue
+		}
+		delete(l.states, key)
+	}
+}
@@ -0,0 +1,26 @@
+package internal
+
+import "encoding/json"
+
+// UnmarshalJSON 指定了 Codec 的消息 Data 保持为 json.RawMessage，
+// 避免经 map 中转丢失数字精度，也便于按 Codec 解码为具体类型；普通消息保持原有解码行为
+func (m *Message) UnmarshalJSON(b []byte) error {
+	type message Message
+	aux := struct {
+		*message
+		Data json.RawMessage `json:"data"`
+	}{message: (*message)(m)}
+	if err := json.Unmarshal(b, &aux); err != nil {
+		return err
+	}
+
+	m.Data = nil
+	if len(aux.Data) == 0 || string(aux.Data) == "null" {
+		return nil
+	}
+	if len(m.Codec) > 0 {
+		m.Data = aux.Data
+		return nil
+	}
+	return json.Unmarshal(aux.Data, &m.Data)
+}
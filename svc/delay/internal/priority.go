@@ -0,0 +1,105 @@
+package internal
+
+const (
+	// PriorityNormal 默认优先级，沿用原 delayed 队列，兼容已有数据
+	PriorityNormal Priority = iota
+	// PriorityHigh 高优先级，如支付超时等时效敏感的消息
+	PriorityHigh
+	// PriorityLow 低优先级，如通知重试等可延后的消息
+	PriorityLow
+)
+
+// 默认各优先级出队权重 high:normal:low
+const (
+	defaultHighWeight   = 6
+	defaultNormalWeight = 3
+	defaultLowWeight    = 1
+)
+
+// lanes 按优先级从高到低排列，出队时据此分配配额
+var lanes = []Priority{PriorityHigh, PriorityNormal, PriorityLow}
+
+type (
+	// Priority 消息优先级，每个优先级是一条独立的 delayed 队列（lane）
+	Priority int
+
+	// PushOption Push 选项
+	PushOption func(msg *Message)
+)
+
+// WithPriority 指定消息优先级，默认 PriorityNormal
+func WithPriority(p Priority) PushOption {
+	return func(msg *Message) {
+		msg.Priority = p
+	}
+}
+
+// String 返回 lane 名称，用于指标标签
+func (p Priority) String() string {
+	switch p {
+	case PriorityHigh:
+		return "high"
+	case PriorityLow:
+		return "low"
+	default:
+		return "normal"
+	}
+}
+
+// laneQueueName 返回优先级对应的 delayed 队列名，normal 沿用 delayed
+func laneQueueName(p Priority) string {
+	switch p {
+	case PriorityHigh, PriorityLow:
+		return delayQueueName + ":" + p.String()
+	default:
+		return delayQueueName
+	}
+}
+
+// drawLanes 按权重从各 lane 取出共 limit 条：先按权重比例分配配额，
+// 再把空闲 lane 未用完的配额按优先级从高到低让给仍有积压的 lane，保证低优先级不会饿死、也不浪费批次容量。
+// draw 返回实际取出的条数，出错时也需返回已取出的条数。
+func drawLanes(limit int, weights map[Priority]int, draw func(lane Priority, n int) (int, error)) error {
+	var total int
+	for _, lane := range lanes {
+		total += weights[lane]
+	}
+	if total <= 0 || limit <= 0 {
+		return nil
+	}
+
+	var (
+		drawn   int
+		lastErr error
+		backlog []Priority
+	)
+	for _, lane := range lanes {
+		// 每个 lane 至少分到 1 条配额，避免批次较小时低优先级分不到
+		quota := min(max(limit*weights[lane]/total, 1), limit-drawn)
+		if quota <= 0 {
+			continue
+		}
+		n, err := draw(lane, quota)
+		drawn += n
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		if n == quota {
+			backlog = append(backlog, lane)
+		}
+	}
+
+	for _, lane := range backlog {
+		if drawn >= limit {
+			break
+		}
+		n, err := draw(lane, limit-drawn)
+		drawn += n
+		if err != nil {
+			lastErr = err
+		}
+	}
+
+	return lastErr
+}
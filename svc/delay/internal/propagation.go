@@ -0,0 +1,34 @@
+package internal
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var (
+	delayTracer = otel.Tracer("github.com/zhuud/go-library/svc/delay")
+)
+
+// injectContextToMessage 把 ctx 中的 trace context（W3C traceparent 等）写入消息 Headers
+func injectContextToMessage(ctx context.Context, msg *Message) {
+	if msg.Headers == nil {
+		msg.Headers = make(map[string]string)
+	}
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(msg.Headers))
+}
+
+// startConsumeSpan 从消息 Headers 恢复生产端 trace context，在同一 trace 下创建 consumer span 并链接到生产端 span。
+// ctx 的取消/超时语义保持不变
+func startConsumeSpan(ctx context.Context, msg *Message) (context.Context, trace.Span) {
+	ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(msg.Headers))
+
+	opts := []trace.SpanStartOption{trace.WithSpanKind(trace.SpanKindConsumer)}
+	if producer := trace.SpanContextFromContext(ctx); producer.IsValid() {
+		opts = append(opts, trace.WithLinks(trace.Link{SpanContext: producer}))
+	}
+
+	return delayTracer.Start(ctx, "consume", opts...)
+}
@@ -0,0 +1,58 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestTracePropagation(t *testing.T) {
+	oldPropagator, oldProvider := otel.GetTextMapPropagator(), otel.GetTracerProvider()
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	tp := sdktrace.NewTracerProvider()
+	otel.SetTracerProvider(tp)
+	t.Cleanup(func() {
+		_ = tp.Shutdown(context.Background())
+		otel.SetTextMapPropagator(oldPropagator)
+		otel.SetTracerProvider(oldProvider)
+	})
+
+	mr := miniredis.RunT(t)
+	store := NewRedisStore(redis.New(mr.Addr()), WithPrefix("trace"))
+	dl := NewDelayer(store, WithPrefix("trace"))
+
+	ctx, span := tp.Tracer("test").Start(context.Background(), "producer")
+	if err := dl.Push(ctx, "k", "v", time.Second); err != nil {
+		t.Fatalf("Push error: %v", err)
+	}
+	span.End()
+	mr.FastForward(time.Second)
+
+	deliveries, err := store.Pop(context.Background(), time.Now().Add(time.Second), 1, time.Now().Add(time.Minute))
+	if err != nil || len(deliveries) != 1 || deliveries[0].Message == nil {
+		t.Fatalf("Pop got %+v, %v, want one message", deliveries, err)
+	}
+	if len(deliveries[0].Message.Headers["traceparent"]) == 0 {
+		t.Fatalf("Headers got %v, want traceparent", deliveries[0].Message.Headers)
+	}
+
+	var got trace.SpanContext
+	dl.process(context.Background(), func(ctx context.Context, msg *Message) error {
+		got = trace.SpanContextFromContext(ctx)
+		return nil
+	}, deliveries[0])
+
+	if got.TraceID() != span.SpanContext().TraceID() {
+		t.Fatalf("handler trace id got %s, want %s", got.TraceID(), span.SpanContext().TraceID())
+	}
+	if got.SpanID() == span.SpanContext().SpanID() {
+		t.Fatalf("handler span id equals producer span, want a new consumer span")
+	}
+}
@@ -0,0 +1,124 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zhuud/go-library/svc/balance"
+	"github.com/zhuud/go-library/utils"
+)
+
+const (
+	// 消费者实例注册 key 格式：{delay:queue:prefix}:consumers，score=最近一次心跳时间
+	consumersKey = "{delay:queue:%s}:consumers"
+	// shardNodeTTL 实例心跳超时时间，超过后视为下线，其分片由其他实例接管
+	shardNodeTTL = maintainInterval * 3
+)
+
+// shardOwner 使用 svc/balance 的 rendezvous 哈希把分片分配给存活的消费者实例。
+// 实例通过 consumers ZSET 心跳注册，每次刷新时重新计算本实例负责的分片；
+// 归属切换的短暂窗口内可能有两个实例拉取同一分片，pop 的 Lua 原子性保证不会重复投递。
+type shardOwner struct {
+	client   *redis.Redis
+	key      string
+	instance string
+	shards   int
+	balance  *balance.NodeBalance
+	logger   *delayLogger
+
+	mu     sync.RWMutex
+	owned  []int
+	synced bool
+}
+
+func newShardOwner(client *redis.Redis, prefix string, shards int) *shardOwner {
+	hostname, _ := os.Hostname()
+	owner := &shardOwner{
+		client:   client,
+		key:      fmt.Sprintf(consumersKey, prefix),
+		instance: fmt.Sprintf("%s-%d-%s", hostname, os.Getpid(), utils.GenUniqId()[:8]),
+		shards:   shards,
+		logger:   newDelayLogger(fmt.Sprintf("delay.%s", prefix)),
+	}
+	// provider 非空时 NewNodeBalance 不会返回错误
+	owner.balance, _ = balance.NewNodeBalance(owner)
+
+	return owner
+}
+
+// GetPinnedNode 分片没有固定映射，全部走 rendezvous 哈希
+func (o *shardOwner) GetPinnedNode(ctx context.Context, id string) (string, bool, error) {
+	return "", false, nil
+}
+
+// GetNodeList 返回心跳未超时的消费者实例
+func (o *shardOwner) GetNodeList(ctx context.Context) ([]string, error) {
+	pairs, err := o.client.ZrangebyscoreWithScoresCtx(ctx, o.key, time.Now().Add(-shardNodeTTL).Unix(), math.MaxInt64)
+	if err != nil {
+		return nil, err
+	}
+
+	nodes := make([]string, 0, len(pairs))
+	for _, pair := range pairs {
+		nodes = append(nodes, pair.Key)
+	}
+	return nodes, nil
+}
+
+// refresh 上报心跳、清理下线实例并重新计算本实例负责的分片，失败时退化为消费全部分片
+func (o *shardOwner) refresh(ctx context.Context) {
+	now := time.Now()
+	if _, err := o.client.ZaddCtx(ctx, o.key, now.Unix(), o.instance); err != nil {
+		o.logger.Errorf("delay.shardOwner heartbeat error: %v", err)
+		o.reset()
+		return
+	}
+	if _, err := o.client.ZremrangebyscoreCtx(ctx, o.key, 0, now.Add(-shardNodeTTL).Unix()); err != nil {
+		o.logger.Errorf("delay.shardOwner remove offline consumers error: %v", err)
+	}
+
+	owned := make([]int, 0, o.shards)
+	for i := 0; i < o.shards; i++ {
+		node, err := o.balance.Locate(ctx, fmt.Sprintf("shard:%d", i))
+		if err != nil {
+			o.logger.Errorf("delay.shardOwner locate shard: %d, error: %v", i, err)
+			o.reset()
+			return
+		}
+		if node == o.instance {
+			owned = append(owned, i)
+		}
+	}
+
+	o.mu.Lock()
+	o.owned = owned
+	o.synced = true
+	o.mu.Unlock()
+}
+
+// ownedShards 返回本实例负责的分片，ok=false 表示归属尚未计算成功
+func (o *shardOwner) ownedShards() ([]int, bool) {
+	o.mu.RLock()
+	defer o.mu.RUnlock()
+	return o.owned, o.synced
+}
+
+// leave 实例退出时注销，使其分片尽快被其他实例接管
+func (o *shardOwner) leave() {
+	if _, err := o.client.Zrem(o.key, o.instance); err != nil {
+		o.logger.Errorf("delay.shardOwner leave error: %v", err)
+	}
+	o.reset()
+}
+
+func (o *shardOwner) reset() {
+	o.mu.Lock()
+	o.owned = nil
+	o.synced = false
+	o.mu.Unlock()
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+func TestShardOwner(t *testing.T) {
+	ctx := context.Background()
+	const shards = 16
+	mr := miniredis.RunT(t)
+	client := redis.New(mr.Addr())
+	a, b, c := newShardOwner(client, "test", shards), newShardOwner(client, "test", shards), newShardOwner(client, "test", shards)
+
+	// refreshAll 先全部上报心跳，再由各实例按同一节点列表计算归属
+	refreshAll := func(owners ...*shardOwner) {
+		for _, o := range owners {
+			o.refresh(ctx)
+		}
+		for _, o := range owners {
+			o.refresh(ctx)
+		}
+	}
+	// assertPartition 各实例负责的分片不重叠且覆盖全部分片
+	assertPartition := func(t *testing.T, owners ...*shardOwner) {
+		t.Helper()
+		seen := make(map[int]string, shards)
+		for _, o := range owners {
+			owned, ok := o.ownedShards()
+			if !ok {
+				t.Fatalf("%s shards not synced", o.instance)
+			}
+			for _, shard := range owned {
+				if other, dup := seen[shard]; dup {
+					t.Fatalf("shard %d owned by both %s and %s", shard, other, o.instance)
+				}
+				seen[shard] = o.instance
+			}
+		}
+		if len(seen) != shards {
+			t.Fatalf("owned %d shards, want %d", len(seen), shards)
+		}
+	}
+
+	refreshAll(a, b, c)
+	assertPartition(t, a, b, c)
+
+	// 实例退出后其分片由剩余实例接管
+	c.leave()
+	if owned, ok := c.ownedShards(); ok || len(owned) != 0 {
+		t.Fatalf("left owner got %v, %v, want none", owned, ok)
+	}
+	refreshAll(a, b)
+	assertPartition(t, a, b)
+
+	// 心跳超时的实例视为下线并被清理，其分片由存活实例接管
+	mr.ZAdd(a.key, float64(time.Now().Add(-shardNodeTTL*2).Unix()), b.instance)
+	a.refresh(ctx)
+	assertPartition(t, a)
+	if members, _ := mr.ZMembers(a.key); len(members) != 1 || members[0] != a.instance {
+		t.Fatalf("consumers %v, want only %s", members, a.instance)
+	}
+}
@@ -0,0 +1,206 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/metric"
+	"github.com/zeromicro/go-zero/core/prometheus"
+)
+
+const (
+	// 未来到期分布统计：未来 1 小时，每 5 分钟一个桶
+	dueHistogramWindow = time.Hour
+	dueBucketWidth     = time.Minute * 5
+
+	// 默认队列统计导出间隔
+	defaultStatsInterval = time.Second * 30
+
+	metricNamespace = "delay"
+)
+
+// Prometheus 指标，go-zero 仅在开启 Prometheus 时才会真正上报。
+// 注意 handler 指标以 Message.Key 为标签，Key 需为有限集合（如 topic、业务类型），不能包含业务 ID。
+var (
+	metricQueueMessages = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "queue",
+		Name:      "messages",
+		Help:      "delay queue message count.",
+		Labels:    []string{"prefix", "queue"},
+	})
+	metricQueueOldestDueLag = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "queue",
+		Name:      "oldest_due_lag_seconds",
+		Help:      "delay queue lag of the oldest due message in seconds.",
+		Labels:    []string{"prefix", "lane"},
+	})
+	metricQueueLaneMessages = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "queue",
+		Name:      "lane_messages",
+		Help:      "delay queue message count of each priority lane.",
+		Labels:    []string{"prefix", "lane", "state"},
+	})
+	metricQueueDueMessages = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "queue",
+		Name:      "due_messages",
+		Help:      "delay queue message count due within the next bucket window.",
+		Labels:    []string{"prefix", "within"},
+	})
+	metricHandlerDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "handler",
+		Name:      "duration_ms",
+		Help:      "delay handler duration(ms).",
+		Labels:    []string{"prefix", "key"},
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+	})
+	metricHandlerTotal = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "handler",
+		Name:      "total",
+		Help:      "delay handler result count.",
+		Labels:    []string{"prefix", "key", "result"},
+	})
+	metricHandlerThrottled = metric.NewCounterVec(&metric.CounterVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "handler",
+		Name:      "throttled_total",
+		Help:      "delay messages released back by per key limits.",
+		Labels:    []string{"prefix", "key"},
+	})
+)
+
+type (
+	// Stats 队列统计
+	Stats struct {
+		// Delayed、Reserved、Dead 各队列消息数，Delayed 为所有 lane 之和
+		Delayed  int64
+		Reserved int64
+		Dead     int64
+		// Due delayed 中已到期但尚未被取出的消息数
+		Due int64
+		// OldestDueLag 最早到期消息已逾期的时长（now - 最小 score），没有到期消息时为 0
+		OldestDueLag time.Duration
+		// DueHistogram 未来 1 小时内的到期分布，按时间顺序每 5 分钟一个桶
+		DueHistogram []DueBucket
+		// Lanes 各优先级 lane 的统计，按优先级从高到低排列
+		Lanes []LaneStats
+	}
+
+	// LaneStats 单个优先级 lane 的统计
+	LaneStats struct {
+		Priority     Priority
+		Delayed      int64
+		Due          int64
+		OldestDueLag time.Duration
+
+		oldest int64 // 最早的已到期时间（unix seconds），由 summarize 换算为 OldestDueLag
+	}
+
+	// DueBucket 到期时间落在 (now+Start, now+End] 内的 delayed 消息数
+	DueBucket struct {
+		Start time.Duration
+		End   time.Duration
+		Count int64
+	}
+)
+
+// newStats 返回包含全部 lane 和空到期分布的统计
+func newStats() *Stats {
+	stats := &Stats{DueHistogram: newDueHistogram()}
+	for _, lane := range lanes {
+		stats.Lanes = append(stats.Lanes, LaneStats{Priority: lane})
+	}
+	return stats
+}
+
+// summarize 根据各 lane 的统计计算 OldestDueLag 并汇总 Delayed、Due
+func (s *Stats) summarize(now time.Time) {
+	s.Delayed, s.Due, s.OldestDueLag = 0, 0, 0
+	for i := range s.Lanes {
+		lane := &s.Lanes[i]
+		if lane.oldest > 0 {
+			lane.OldestDueLag = now.Sub(time.Unix(lane.oldest, 0))
+		}
+		s.Delayed += lane.Delayed
+		s.Due += lane.Due
+		s.OldestDueLag = max(s.OldestDueLag, lane.OldestDueLag)
+	}
+}
+
+// newDueHistogram 返回未来 1 小时的空桶
+func newDueHistogram() []DueBucket {
+	buckets := make([]DueBucket, 0, dueHistogramWindow/dueBucketWidth)
+	for start := time.Duration(0); start < dueHistogramWindow; start += dueBucketWidth {
+		buckets = append(buckets, DueBucket{Start: start, End: start + dueBucketWidth})
+	}
+	return buckets
+}
+
+// Stats 返回队列统计
+func (dl *Delayer) Stats(ctx context.Context) (*Stats, error) {
+	stats, err := dl.store.Stats(ctx, time.Now())
+	if err != nil {
+		return nil, fmt.Errorf("delay.Stats error: %w", err)
+	}
+
+	return stats, nil
+}
+
+func (dl *Delayer) loopStats(ctx context.Context) {
+	ticker := time.NewTicker(dl.statsInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			dl.exportStats(ctx)
+		}
+	}
+}
+
+// exportStats 把队列统计导出为 Prometheus gauge，未开启 Prometheus 时不查询存储
+func (dl *Delayer) exportStats(ctx context.Context) {
+	if !prometheus.Enabled() {
+		return
+	}
+
+	stats, err := dl.Stats(ctx)
+	if err != nil {
+		dl.logger.Errorf("delay.exportStats error: %v", err)
+		return
+	}
+
+	metricQueueMessages.Set(float64(stats.Delayed), dl.prefix, delayQueueName)
+	metricQueueMessages.Set(float64(stats.Reserved), dl.prefix, reservedQueueName)
+	metricQueueMessages.Set(float64(stats.Dead), dl.prefix, deadQueueName)
+	metricQueueMessages.Set(float64(stats.Due), dl.prefix, "due")
+	for _, lane := range stats.Lanes {
+		metricQueueLaneMessages.Set(float64(lane.Delayed), dl.prefix, lane.Priority.String(), delayQueueName)
+		metricQueueLaneMessages.Set(float64(lane.Due), dl.prefix, lane.Priority.String(), "due")
+		metricQueueOldestDueLag.Set(lane.OldestDueLag.Seconds(), dl.prefix, lane.Priority.String())
+	}
+	for _, bucket := range stats.DueHistogram {
+		metricQueueDueMessages.Set(float64(bucket.Count), dl.prefix, bucket.End.String())
+	}
+}
+
+// recordHandlerMetrics 记录按 Key 区分的 handler 耗时与结果
+func (dl *Delayer) recordHandlerMetrics(key string, duration time.Duration, err error) {
+	metricHandlerDuration.Observe(duration.Milliseconds(), dl.prefix, key)
+	metricHandlerTotal.Inc(dl.prefix, key, handlerResult(err))
+}
+
+func handlerResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,60 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	// 队列类型
+	delayQueueName    = "delayed"
+	reservedQueueName = "reserved"
+	deadQueueName     = "dead"
+)
+
+// ErrReservationLost 消息已不在 reserved 中（已 ack 或已因可见性超时被重投递）
+var ErrReservationLost = errors.New("delay: reservation lost")
+
+type (
+	// Store 延迟队列存储后端，负责 delayed/reserved/dead 三个队列之间的原子迁移。
+	// 实现需保证并发安全，且同一条消息同一时刻只会被一个消费者从 delayed 取出。
+	Store interface {
+		// Push 写入 msg.Priority 对应的 delayed lane，到期时间为 msg.Timestamp（unix seconds）
+		Push(ctx context.Context, msg *Message) error
+		// Pop 按优先级权重从各 lane 取出最多 limit 条到期时间不晚于 now 的消息，原子迁移到 reserved，可见性截止时间为 visibleUntil
+		Pop(ctx context.Context, now time.Time, limit int, visibleUntil time.Time) ([]*Delivery, error)
+		// Ack 从 reserved 删除；消息已不在 reserved 中时视为成功
+		Ack(ctx context.Context, d *Delivery) error
+		// Release 原子地用 msg 替换 reserved 中的 d 并写回 msg.Priority 对应的 delayed lane（到期时间为 msg.Timestamp）。
+		// d 已不在 reserved 中时不写入并返回 false
+		Release(ctx context.Context, d *Delivery, msg *Message) (bool, error)
+		// Extend 把 d 的可见性截止时间延长到 visibleUntil；d 已不在 reserved 中时返回 ErrReservationLost
+		Extend(ctx context.Context, d *Delivery, visibleUntil time.Time) error
+		// ExpireReserved 处理最多 limit 条可见性截止时间不晚于 now 的消息：
+		// Attempts、Expiries 加一后写回 delayed 立即到期，Expiries 超过 maxExpiries 或无法解析的移入 dead
+		ExpireReserved(ctx context.Context, now time.Time, limit int, maxExpiries int) (requeued, dead int, err error)
+		// Stats 以 now 为基准统计各队列消息数、最早到期消息的逾期时长和未来 1 小时的到期分布
+		Stats(ctx context.Context, now time.Time) (*Stats, error)
+	}
+
+	// BatchStore Store 的可选扩展，支持批量写入；未实现时 PushBatch 退化为逐条 Push，且不支持原子模式
+	BatchStore interface {
+		// PushBatch 批量写入 delayed，返回与 msgs 一一对应的错误，全部成功时返回 nil
+		PushBatch(ctx context.Context, msgs []*Message) []error
+		// PushBatchAtomic 原子写入全部消息，返回错误时一条都不写入
+		PushBatchAtomic(ctx context.Context, msgs []*Message) error
+	}
+
+	// Delivery 从 reserved 中取出的一条待处理消息
+	Delivery struct {
+		// Message 解析后的消息，解析失败时为 nil
+		Message *Message
+		// Raw 存储中的原始消息内容，用于日志排查
+		Raw string
+		// Shard 消息所在分片，由 Store 实现自行解释
+		Shard int
+		// Receipt reserved 凭证，Ack/Release/Extend 时由 Store 校验消息是否仍归当前消费者所有
+		Receipt string
+	}
+)
@@ -0,0 +1,423 @@
+package internal
+
+// MySQLStore 使用单表保存 delayed/reserved/dead 三个队列，通过 queue 字段区分，due_at 对应 Redis 中的 score；
+// 各优先级 lane 的 queue 分别为 delayed:high/delayed/delayed:low。
+// 出队使用 `SELECT ... FOR UPDATE SKIP LOCKED` 保证多消费者并发时同一行只会被一个事务取出；
+// 每次迁移到 reserved 都会生成新的 receipt，Ack/Release/Extend 均以 id+receipt 为条件，
+// 可见性超时被重投递后旧消费者持有的 receipt 自然失效。需要 MySQL 8.0+。
+//
+// 表结构（也可调用 Migrate 自动创建）：
+//
+//	CREATE TABLE `delay_message` (
+//	  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+//	  `prefix` varchar(64) NOT NULL,
+//	  `queue` varchar(16) NOT NULL,
+//	  `due_at` bigint NOT NULL,
+//	  `msg_id` varchar(64) NOT NULL,
+//	  `receipt` varchar(64) NOT NULL DEFAULT '',
+//	  `body` mediumtext NOT NULL,
+//	  `created_at` datetime(3) NOT NULL,
+//	  `updated_at` datetime(3) NOT NULL,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_prefix_queue_due` (`prefix`, `queue`, `due_at`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zhuud/go-library/utils"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type (
+	// mysqlMessage delay 消息表结构
+	mysqlMessage struct {
+		ID        uint64    `gorm:"column:id;primaryKey;autoIncrement"`
+		Prefix    string    `gorm:"column:prefix;type:varchar(64);not null;index:idx_prefix_queue_due,priority:1"`
+		Queue     string    `gorm:"column:queue;type:varchar(16);not null;index:idx_prefix_queue_due,priority:2"`
+		DueAt     int64     `gorm:"column:due_at;not null;index:idx_prefix_queue_due,priority:3"`
+		MsgID     string    `gorm:"column:msg_id;type:varchar(64);not null"`
+		Receipt   string    `gorm:"column:receipt;type:varchar(64);not null;default:''"`
+		Body      string    `gorm:"column:body;type:mediumtext;not null"`
+		CreatedAt time.Time `gorm:"column:created_at;not null"`
+		UpdatedAt time.Time `gorm:"column:updated_at;not null"`
+	}
+
+	// MySQLStore MySQL 存储实现
+	MySQLStore struct {
+		db      *gorm.DB
+		table   string
+		prefix  string
+		weights map[Priority]int
+		logger  *delayLogger
+	}
+)
+
+// NewMySQLStore 创建 MySQL 存储，使用 opts 中的 Prefix、MySQLTable 配置
+func NewMySQLStore(db *gorm.DB, opts ...OptionFunc) *MySQLStore {
+	if db == nil {
+		panic("delay: mysql db cannot be nil")
+	}
+
+	config := NewConfig(opts...)
+	if len(config.Prefix) == 0 {
+		panic("delay prefix cannot be empty")
+	}
+
+	return &MySQLStore{
+		db:      db,
+		table:   config.MySQLTable,
+		prefix:  config.Prefix,
+		weights: config.PriorityWeights,
+		logger:  newDelayLogger(fmt.Sprintf("delay.%s", config.Prefix)),
+	}
+}
+
+// Migrate 自动创建/更新消息表
+func (s *MySQLStore) Migrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).Table(s.table).AutoMigrate(&mysqlMessage{})
+}
+
+// Push 写入 Priority 对应的 delayed lane
+func (s *MySQLStore) Push(ctx context.Context, msg *Message) error {
+	row, err := s.newRow(msg)
+	if err != nil {
+		return fmt.Errorf("delay.MySQLStore.Push Marshal error: %w", err)
+	}
+	if err := s.query(ctx).Create(row).Error; err != nil {
+		return fmt.Errorf("delay.MySQLStore.Push Create error: %w", err)
+	}
+
+	return nil
+}
+
+// PushBatch 单条多行 INSERT 写入一块消息，整块成功或失败
+func (s *MySQLStore) PushBatch(ctx context.Context, msgs []*Message) []error {
+	errs := make([]error, len(msgs))
+	rows := make([]*mysqlMessage, 0, len(msgs))
+	for i, msg := range msgs {
+		row, err := s.newRow(msg)
+		if err != nil {
+			errs[i] = fmt.Errorf("delay.MySQLStore.PushBatch Marshal error: %w", err)
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	if len(rows) > 0 {
+		if err := s.query(ctx).Create(&rows).Error; err != nil {
+			for i := range errs {
+				if errs[i] == nil {
+					errs[i] = fmt.Errorf("delay.MySQLStore.PushBatch Create error: %w", err)
+				}
+			}
+		}
+	}
+
+	return batchErrors(errs)
+}
+
+// PushBatchAtomic 在一个事务中分批写入全部消息
+func (s *MySQLStore) PushBatchAtomic(ctx context.Context, msgs []*Message) error {
+	rows := make([]*mysqlMessage, 0, len(msgs))
+	for _, msg := range msgs {
+		row, err := s.newRow(msg)
+		if err != nil {
+			return fmt.Errorf("delay.MySQLStore.PushBatchAtomic Marshal error: %w", err)
+		}
+		rows = append(rows, row)
+	}
+
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return tx.Table(s.table).CreateInBatches(&rows, pushBatchChunkSize).Error
+	})
+	if err != nil {
+		return fmt.Errorf("delay.MySQLStore.PushBatchAtomic error: %w", err)
+	}
+
+	return nil
+}
+
+// Pop 按优先级权重锁定各 lane 的到期消息并迁移到 reserved
+func (s *MySQLStore) Pop(ctx context.Context, now time.Time, limit int, visibleUntil time.Time) ([]*Delivery, error) {
+	var deliveries []*Delivery
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var rows []*mysqlMessage
+		err := drawLanes(limit, s.weights, func(lane Priority, n int) (int, error) {
+			laneRows, err := s.lockDue(tx, laneQueueName(lane), now, n)
+			rows = append(rows, laneRows...)
+			return len(laneRows), err
+		})
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		// 同一批次共用一个 receipt，与 id 组合后在每次出队时唯一
+		receipt := utils.GenUniqId()
+		ids := make([]uint64, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		err = tx.Table(s.table).
+			Where("id IN ?", ids).
+			Updates(map[string]any{
+				"queue":      reservedQueueName,
+				"due_at":     visibleUntil.Unix(),
+				"receipt":    receipt,
+				"updated_at": time.Now(),
+			}).Error
+		if err != nil {
+			return err
+		}
+
+		deliveries = make([]*Delivery, 0, len(rows))
+		for _, row := range rows {
+			deliveries = append(deliveries, newMySQLDelivery(row, receipt))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("delay.MySQLStore.Pop error: %w", err)
+	}
+
+	return deliveries, nil
+}
+
+// Ack 从 reserved 删除
+func (s *MySQLStore) Ack(ctx context.Context, d *Delivery) error {
+	id, receipt, err := parseMySQLReceipt(d.Receipt)
+	if err != nil {
+		return err
+	}
+
+	err = s.query(ctx).
+		Where("id = ? AND queue = ? AND receipt = ?", id, reservedQueueName, receipt).
+		Delete(&mysqlMessage{}).Error
+	if err != nil {
+		return fmt.Errorf("delay.MySQLStore.Ack Delete error: %w", err)
+	}
+
+	return nil
+}
+
+// Release 用 msg 替换 reserved 中的 d 并写回 msg.Priority 对应的 delayed lane
+func (s *MySQLStore) Release(ctx context.Context, d *Delivery, msg *Message) (bool, error) {
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		return false, fmt.Errorf("delay.MySQLStore.Release Marshal error: %w", err)
+	}
+
+	n, err := s.updateReserved(ctx, d, map[string]any{
+		"queue":      laneQueueName(msg.Priority),
+		"due_at":     msg.Timestamp,
+		"receipt":    "",
+		"body":       string(mj),
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return false, fmt.Errorf("delay.MySQLStore.Release error: %w", err)
+	}
+
+	return n == 1, nil
+}
+
+// Extend 延长 reserved 中 d 的可见性截止时间
+func (s *MySQLStore) Extend(ctx context.Context, d *Delivery, visibleUntil time.Time) error {
+	n, err := s.updateReserved(ctx, d, map[string]any{
+		"due_at":     visibleUntil.Unix(),
+		"updated_at": time.Now(),
+	})
+	if err != nil {
+		return fmt.Errorf("delay.MySQLStore.Extend error: %w", err)
+	}
+	if n != 1 {
+		return ErrReservationLost
+	}
+
+	return nil
+}
+
+// ExpireReserved 处理 reserved 中可见性超时未 ack 的消息
+func (s *MySQLStore) ExpireReserved(ctx context.Context, now time.Time, limit int, maxExpiries int) (int, int, error) {
+	var requeued, dead int
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		rows, err := s.lockDue(tx, reservedQueueName, now, limit)
+		if err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			queueType, body := expireMessage(row.Body, maxExpiries)
+			ret := tx.Table(s.table).
+				Where("id = ?", row.ID).
+				Updates(map[string]any{
+					"queue":      queueType,
+					"due_at":     now.Unix(),
+					"receipt":    "",
+					"body":       body,
+					"updated_at": time.Now(),
+				})
+			if ret.Error != nil {
+				return ret.Error
+			}
+			if queueType == deadQueueName {
+				dead++
+				s.logger.Errorf("delay.MySQLStore.ExpireReserved moved to dead, data: %s", body)
+			} else {
+				requeued++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, 0, fmt.Errorf("delay.MySQLStore.ExpireReserved error: %w", err)
+	}
+
+	return requeued, dead, nil
+}
+
+// Stats 统计当前 prefix 下的队列消息数和到期分布
+func (s *MySQLStore) Stats(ctx context.Context, now time.Time) (*Stats, error) {
+	laneQueues := make([]string, 0, len(lanes))
+	for _, lane := range lanes {
+		laneQueues = append(laneQueues, laneQueueName(lane))
+	}
+
+	var counts []struct {
+		Queue  string
+		Count  int64
+		Due    int64
+		Oldest *int64
+	}
+	err := s.query(ctx).
+		Select("queue, COUNT(*) AS count, SUM(due_at <= ?) AS due, MIN(CASE WHEN due_at <= ? THEN due_at END) AS oldest", now.Unix(), now.Unix()).
+		Where("prefix = ?", s.prefix).
+		Group("queue").
+		Scan(&counts).Error
+	if err != nil {
+		return nil, fmt.Errorf("delay.MySQLStore.Stats count error: %w", err)
+	}
+
+	stats := newStats()
+	for _, c := range counts {
+		switch c.Queue {
+		case reservedQueueName:
+			stats.Reserved = c.Count
+		case deadQueueName:
+			stats.Dead = c.Count
+		default:
+			for i := range stats.Lanes {
+				lane := &stats.Lanes[i]
+				if laneQueueName(lane.Priority) != c.Queue {
+					continue
+				}
+				lane.Delayed, lane.Due = c.Count, c.Due
+				if c.Oldest != nil {
+					lane.oldest = *c.Oldest
+				}
+			}
+		}
+	}
+	stats.summarize(now)
+
+	// due_at 为整数秒，落在 (now+i*width, now+(i+1)*width] 的消息归入第 i 个桶
+	width := int64(dueBucketWidth.Seconds())
+	var buckets []struct {
+		Bucket int
+		Count  int64
+	}
+	err = s.query(ctx).
+		Select("FLOOR((due_at - ? - 1) / ?) AS bucket, COUNT(*) AS count", now.Unix(), width).
+		Where("prefix = ? AND queue IN ? AND due_at > ? AND due_at <= ?",
+			s.prefix, laneQueues, now.Unix(), now.Add(dueHistogramWindow).Unix()).
+		Group("bucket").
+		Scan(&buckets).Error
+	if err != nil {
+		return nil, fmt.Errorf("delay.MySQLStore.Stats histogram error: %w", err)
+	}
+	for _, b := range buckets {
+		if b.Bucket >= 0 && b.Bucket < len(stats.DueHistogram) {
+			stats.DueHistogram[b.Bucket].Count = b.Count
+		}
+	}
+
+	return stats, nil
+}
+
+// lockDue 在事务中锁定指定队列最多 limit 条到期的行，已被其他事务锁定的行直接跳过
+func (s *MySQLStore) lockDue(tx *gorm.DB, queueType string, now time.Time, limit int) ([]*mysqlMessage, error) {
+	var rows []*mysqlMessage
+	err := tx.Table(s.table).
+		Clauses(clause.Locking{Strength: clause.LockingStrengthUpdate, Options: clause.LockingOptionsSkipLocked}).
+		Where("prefix = ? AND queue = ? AND due_at <= ?", s.prefix, queueType, now.Unix()).
+		Order("due_at ASC, id ASC").
+		Limit(limit).
+		Find(&rows).Error
+
+	return rows, err
+}
+
+// updateReserved 仅当 d 仍以相同 receipt 处于 reserved 时才更新，返回影响行数
+func (s *MySQLStore) updateReserved(ctx context.Context, d *Delivery, values map[string]any) (int64, error) {
+	id, receipt, err := parseMySQLReceipt(d.Receipt)
+	if err != nil {
+		return 0, err
+	}
+
+	ret := s.query(ctx).
+		Where("id = ? AND queue = ? AND receipt = ?", id, reservedQueueName, receipt).
+		Updates(values)
+
+	return ret.RowsAffected, ret.Error
+}
+
+// newRow 构造写入 delayed lane 的行
+func (s *MySQLStore) newRow(msg *Message) (*mysqlMessage, error) {
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	return &mysqlMessage{
+		Prefix: s.prefix,
+		Queue:  laneQueueName(msg.Priority),
+		DueAt:  msg.Timestamp,
+		MsgID:  msg.ID,
+		Body:   string(mj),
+	}, nil
+}
+
+func (s *MySQLStore) query(ctx context.Context) *gorm.DB {
+	return s.db.WithContext(ctx).Table(s.table)
+}
+
+// newMySQLDelivery Receipt 格式为 id:receipt
+func newMySQLDelivery(row *mysqlMessage, receipt string) *Delivery {
+	d := &Delivery{
+		Raw:     row.Body,
+		Receipt: fmt.Sprintf("%d:%s", row.ID, receipt),
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(row.Body), &msg); err == nil {
+		d.Message = &msg
+	}
+	return d
+}
+
+func parseMySQLReceipt(receipt string) (uint64, string, error) {
+	idStr, token, ok := strings.Cut(receipt, ":")
+	if !ok {
+		return 0, "", fmt.Errorf("delay.MySQLStore invalid receipt: %s", receipt)
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		return 0, "", fmt.Errorf("delay.MySQLStore invalid receipt: %s", receipt)
+	}
+	return id, token, nil
+}
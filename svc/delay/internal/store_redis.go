@@ -0,0 +1,453 @@
+package internal
+
+// RedisStore 使用 Redis Sorted Set（`delayed`/`reserved`/`dead`）+ Lua 脚本保证原子出队/重投递。
+// reserved 中的成员即消息 JSON，Delivery.Receipt 保存该成员；消息重投递时 JSON 会变化（Attempts 等），
+// 因此过期重投递后旧的 Receipt 自然失效，不会出现重复 ack/重复投递。
+// 配置 Shards>1 时，每个分片是独立的一组 key（独立 hash slot），Push 按 Key 哈希路由，Pop 轮转拉取各分片。
+// 每个优先级是分片内独立的 delayed lane（`delayed:high`/`delayed`/`delayed:low`），共用同一个 reserved。
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync/atomic"
+	"time"
+
+	"github.com/avast/retry-go/v4"
+	"github.com/spf13/cast"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+)
+
+const (
+	// 队列 key 格式：{delay:queue:prefix}:queueType，分片时为 {delay:queue:prefix:shard}:queueType
+	queueKey      = "{delay:queue:%s}:%s"
+	shardQueueKey = "{delay:queue:%s:%d}:%s"
+
+	// maxAtomicBatchSize 原子批量写入单批最大消息数，避免单个 Lua 脚本长时间阻塞 Redis
+	maxAtomicBatchSize = 1000
+)
+
+var (
+	//go:embed delayer_pop.lua
+	popLuaScript string
+	popScript    = redis.NewScript(popLuaScript)
+
+	//go:embed delayer_release.lua
+	releaseLuaScript string
+	releaseScript    = redis.NewScript(releaseLuaScript)
+
+	//go:embed delayer_push_batch.lua
+	pushBatchLuaScript string
+	pushBatchScript    = redis.NewScript(pushBatchLuaScript)
+
+	//go:embed delayer_extend.lua
+	extendLuaScript string
+	extendScript    = redis.NewScript(extendLuaScript)
+
+	//go:embed delayer_stats.lua
+	statsLuaScript string
+	statsScript    = redis.NewScript(statsLuaScript)
+)
+
+// errBatchCrossShard 原子批量写入的消息跨越多个分片，无法在同一个 Lua 脚本中写入
+var errBatchCrossShard = errors.New("delay: atomic batch spans multiple shards")
+
+// errBatchTooLarge 原子批量写入的消息数超过 maxAtomicBatchSize；拆分会失去原子性，由调用方决定如何处理
+var errBatchTooLarge = fmt.Errorf("delay: atomic batch exceeds %d messages", maxAtomicBatchSize)
+
+// RedisStore Redis 存储实现
+type RedisStore struct {
+	client *redis.Redis
+	logger *delayLogger
+	owner  *shardOwner // 分片归属（可选），nil 时消费全部分片
+
+	prefix  string
+	shards  int
+	weights map[Priority]int
+
+	popCursor   atomic.Int64 // 轮转拉取分片的起始游标
+	lastRefresh atomic.Int64 // 最近一次刷新分片归属的时间（unix nano）
+}
+
+// NewRedisStore 创建 Redis 存储，使用 opts 中的 Prefix、Shards、ShardBalance 配置
+func NewRedisStore(client *redis.Redis, opts ...OptionFunc) *RedisStore {
+	if client == nil {
+		panic("delay: redis client cannot be nil")
+	}
+
+	config := NewConfig(opts...)
+	if len(config.Prefix) == 0 {
+		panic("delay prefix cannot be empty")
+	}
+
+	s := &RedisStore{
+		client:  client,
+		logger:  newDelayLogger(fmt.Sprintf("delay.%s", config.Prefix)),
+		prefix:  config.Prefix,
+		shards:  config.Shards,
+		weights: config.PriorityWeights,
+	}
+	if config.ShardBalance && config.Shards > 1 {
+		s.owner = newShardOwner(client, config.Prefix, config.Shards)
+	}
+
+	return s
+}
+
+// Push 写入 Key 所在分片、Priority 对应的 delayed lane
+func (s *RedisStore) Push(ctx context.Context, msg *Message) error {
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		return fmt.Errorf("delay.RedisStore.Push Marshal error: %w", err)
+	}
+
+	_, err = s.client.ZaddCtx(ctx, s.fmtQueueKey(s.shardOf(msg.Key), laneQueueName(msg.Priority)), msg.Timestamp, string(mj))
+	if err != nil {
+		return fmt.Errorf("delay.RedisStore.Push ZaddCtx error: %w", err)
+	}
+
+	return nil
+}
+
+// PushBatch 按分片和 lane 分组，每组一次多成员 ZADD
+func (s *RedisStore) PushBatch(ctx context.Context, msgs []*Message) []error {
+	errs := make([]error, len(msgs))
+	groups := make(map[string][]int)
+	pairs := make(map[string][]redis.Pair)
+	for i, msg := range msgs {
+		mj, err := json.Marshal(msg)
+		if err != nil {
+			errs[i] = fmt.Errorf("delay.RedisStore.PushBatch Marshal error: %w", err)
+			continue
+		}
+		key := s.fmtQueueKey(s.shardOf(msg.Key), laneQueueName(msg.Priority))
+		groups[key] = append(groups[key], i)
+		pairs[key] = append(pairs[key], redis.Pair{Key: string(mj), Score: msg.Timestamp})
+	}
+
+	for key, ps := range pairs {
+		if _, err := s.client.ZaddsCtx(ctx, key, ps...); err != nil {
+			for _, i := range groups[key] {
+				errs[i] = fmt.Errorf("delay.RedisStore.PushBatch ZaddsCtx error: %w", err)
+			}
+		}
+	}
+
+	return batchErrors(errs)
+}
+
+// PushBatchAtomic 通过一个 Lua 脚本写入全部消息，要求所有消息位于同一分片且不超过 maxAtomicBatchSize 条
+func (s *RedisStore) PushBatchAtomic(ctx context.Context, msgs []*Message) error {
+	if len(msgs) > maxAtomicBatchSize {
+		return errBatchTooLarge
+	}
+
+	shard := s.shardOf(msgs[0].Key)
+	var (
+		keys []string
+		args = make([]any, 0, len(msgs)*3)
+		pos  = make(map[string]int)
+	)
+	for _, msg := range msgs {
+		if s.shardOf(msg.Key) != shard {
+			return errBatchCrossShard
+		}
+		mj, err := json.Marshal(msg)
+		if err != nil {
+			return fmt.Errorf("delay.RedisStore.PushBatchAtomic Marshal error: %w", err)
+		}
+
+		key := s.fmtQueueKey(shard, laneQueueName(msg.Priority))
+		if _, ok := pos[key]; !ok {
+			keys = append(keys, key)
+			pos[key] = len(keys)
+		}
+		args = append(args, pos[key], msg.Timestamp, string(mj))
+	}
+
+	if _, err := s.client.ScriptRunCtx(ctx, pushBatchScript, keys, args...); err != nil {
+		return fmt.Errorf("delay.RedisStore.PushBatchAtomic ScriptRun error: %w", err)
+	}
+
+	return nil
+}
+
+// Pop 从各分片的 delayed lane 中取出到期消息并原子迁移到 reserved。
+// 先按优先级权重分配各 lane 的配额，每个 lane 再从不同分片开始轮转，单分片最多取配额/分片数 条，保证各分片公平消费。
+func (s *RedisStore) Pop(ctx context.Context, now time.Time, limit int, visibleUntil time.Time) ([]*Delivery, error) {
+	if s.owner != nil && time.Since(time.Unix(0, s.lastRefresh.Load())) >= maintainInterval {
+		s.owner.refresh(ctx)
+		s.lastRefresh.Store(time.Now().UnixNano())
+	}
+
+	shards := s.consumeShards()
+	if len(shards) == 0 {
+		return nil, nil
+	}
+
+	start := int(s.popCursor.Add(1)-1) % len(shards)
+
+	var deliveries []*Delivery
+	err := drawLanes(limit, s.weights, func(lane Priority, n int) (int, error) {
+		var (
+			drawn   int
+			lastErr error
+		)
+		quota := (n + len(shards) - 1) / len(shards)
+		for i := 0; i < len(shards) && drawn < n; i++ {
+			shard := shards[(start+i)%len(shards)]
+			data, err := s.client.ScriptRunCtx(ctx, popScript,
+				[]string{
+					s.fmtQueueKey(shard, laneQueueName(lane)),
+					s.fmtQueueKey(shard, reservedQueueName),
+				}, []string{
+					cast.ToString(now.Unix()),
+					cast.ToString(min(quota, n-drawn)),
+					cast.ToString(visibleUntil.Unix()),
+				})
+			if err != nil {
+				lastErr = fmt.Errorf("delay.RedisStore.Pop shard: %d, lane: %s, ScriptRun error: %w", shard, lane, err)
+				continue
+			}
+
+			for _, taskJson := range cast.ToStringSlice(data) {
+				deliveries = append(deliveries, newRedisDelivery(shard, taskJson))
+				drawn++
+			}
+		}
+		return drawn, lastErr
+	})
+
+	return deliveries, err
+}
+
+// Ack 从 reserved 删除
+func (s *RedisStore) Ack(ctx context.Context, d *Delivery) error {
+	return retry.Do(func() error {
+		_, err := s.client.ZremCtx(ctx, s.fmtQueueKey(d.Shard, reservedQueueName), d.Receipt)
+		return err
+	}, retry.Attempts(2), retry.Delay(10*time.Millisecond))
+}
+
+// Release 原子地用 msg 替换 reserved 中的 d 并写回 msg.Priority 对应的 delayed lane
+func (s *RedisStore) Release(ctx context.Context, d *Delivery, msg *Message) (bool, error) {
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		return false, fmt.Errorf("delay.RedisStore.Release Marshal error: %w", err)
+	}
+
+	return s.release(ctx, d.Shard, d.Receipt, laneQueueName(msg.Priority), msg.Timestamp, string(mj))
+}
+
+// Extend 延长 reserved 中 d 的可见性截止时间
+func (s *RedisStore) Extend(ctx context.Context, d *Delivery, visibleUntil time.Time) error {
+	ret, err := s.client.ScriptRunCtx(ctx, extendScript,
+		[]string{
+			s.fmtQueueKey(d.Shard, reservedQueueName),
+		}, []string{
+			d.Receipt,
+			cast.ToString(visibleUntil.Unix()),
+		})
+	if err != nil {
+		return fmt.Errorf("delay.RedisStore.Extend ScriptRun error: %w", err)
+	}
+	if cast.ToInt(ret) != 1 {
+		return ErrReservationLost
+	}
+
+	return nil
+}
+
+// ExpireReserved 处理各分片 reserved 中可见性超时未 ack 的消息
+func (s *RedisStore) ExpireReserved(ctx context.Context, now time.Time, limit int, maxExpiries int) (int, int, error) {
+	var (
+		requeued, dead int
+		lastErr        error
+	)
+	for _, shard := range s.consumeShards() {
+		pairs, err := s.client.ZrangebyscoreWithScoresAndLimitCtx(ctx, s.fmtQueueKey(shard, reservedQueueName), 0, now.Unix(), 0, limit)
+		if err != nil {
+			lastErr = fmt.Errorf("delay.RedisStore.ExpireReserved shard: %d, ZrangebyscoreWithScoresAndLimit error: %w", shard, err)
+			continue
+		}
+
+		for _, pair := range pairs {
+			queueType, newTaskJson := expireMessage(pair.Key, maxExpiries)
+			moved, err := s.release(ctx, shard, pair.Key, queueType, now.Unix(), newTaskJson)
+			if err != nil {
+				lastErr = fmt.Errorf("delay.RedisStore.ExpireReserved release data: %s, error: %w", pair.Key, err)
+				continue
+			}
+			if !moved {
+				continue
+			}
+			if queueType == deadQueueName {
+				dead++
+				s.logger.Errorf("delay.RedisStore.ExpireReserved moved to dead, data: %s", newTaskJson)
+			} else {
+				requeued++
+			}
+		}
+	}
+
+	return requeued, dead, lastErr
+}
+
+// Stats 汇总所有分片（不受分片归属限制）的队列统计
+func (s *RedisStore) Stats(ctx context.Context, now time.Time) (*Stats, error) {
+	stats := newStats()
+	for shard := 0; shard < s.shards; shard++ {
+		if err := s.shardStats(ctx, shard, now.Unix(), stats); err != nil {
+			return nil, fmt.Errorf("delay.RedisStore.Stats shard: %d, error: %w", shard, err)
+		}
+	}
+	stats.summarize(now)
+
+	return stats, nil
+}
+
+// shardStats 用一次 Lua 脚本统计单个分片并累加到 stats，lane 的 OldestDueLag 暂存最小到期时间（unix seconds）
+func (s *RedisStore) shardStats(ctx context.Context, shard int, now int64, stats *Stats) error {
+	keys := []string{s.fmtQueueKey(shard, reservedQueueName), s.fmtQueueKey(shard, deadQueueName)}
+	for _, lane := range stats.Lanes {
+		keys = append(keys, s.fmtQueueKey(shard, laneQueueName(lane.Priority)))
+	}
+	// score 为整数秒，(start, end] 即 [start+1, end]
+	args := []any{now}
+	for _, bucket := range stats.DueHistogram {
+		args = append(args, now+int64(bucket.Start.Seconds())+1, now+int64(bucket.End.Seconds()))
+	}
+
+	ret, err := s.client.ScriptRunCtx(ctx, statsScript, keys, args...)
+	if err != nil {
+		return err
+	}
+	values, ok := ret.([]any)
+	if !ok || len(values) != 2+len(stats.Lanes)*(3+len(stats.DueHistogram)) {
+		return fmt.Errorf("unexpected stats result: %v", ret)
+	}
+	next := func() int64 {
+		v, _ := values[0].(int64)
+		values = values[1:]
+		return v
+	}
+
+	stats.Reserved += next()
+	stats.Dead += next()
+	for i := range stats.Lanes {
+		lane := &stats.Lanes[i]
+		lane.Delayed += next()
+		if oldest := next(); oldest > 0 && (lane.oldest == 0 || oldest < lane.oldest) {
+			lane.oldest = oldest
+		}
+		lane.Due += next()
+		for j := range stats.DueHistogram {
+			stats.DueHistogram[j].Count += next()
+		}
+	}
+
+	return nil
+}
+
+// Close 注销分片归属，使其分片尽快被其他实例接管
+func (s *RedisStore) Close() error {
+	if s.owner != nil {
+		s.owner.leave()
+	}
+	return nil
+}
+
+// release 原子地把 reserved 中的 taskJson 替换为 newTaskJson 并写入目标队列。
+// 若 taskJson 已不在 reserved 中（已 ack 或已被其他消费者重投递）则不写入，返回 false。
+func (s *RedisStore) release(ctx context.Context, shard int, taskJson, queueType string, score int64, newTaskJson string) (bool, error) {
+	var moved bool
+	err := retry.Do(func() error {
+		ret, err := s.client.ScriptRunCtx(ctx, releaseScript,
+			[]string{
+				s.fmtQueueKey(shard, reservedQueueName),
+				s.fmtQueueKey(shard, queueType),
+			}, []string{
+				taskJson,
+				cast.ToString(score),
+				newTaskJson,
+			})
+		if err != nil {
+			return err
+		}
+		moved = cast.ToInt(ret) == 1
+		return nil
+	}, retry.Attempts(2), retry.Delay(10*time.Millisecond))
+
+	return moved, err
+}
+
+// consumeShards 返回当前实例需要消费的分片；未开启分片归属或归属不可用时返回全部分片
+func (s *RedisStore) consumeShards() []int {
+	if s.owner != nil {
+		if shards, ok := s.owner.ownedShards(); ok {
+			return shards
+		}
+	}
+
+	shards := make([]int, s.shards)
+	for i := range shards {
+		shards[i] = i
+	}
+	return shards
+}
+
+// shardOf 按消息 Key 哈希计算分片
+func (s *RedisStore) shardOf(key string) int {
+	if s.shards <= 1 {
+		return 0
+	}
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+	return int(h.Sum32() % uint32(s.shards))
+}
+
+// fmtQueueKey 单分片时沿用 {delay:queue:prefix}:queueType，保持与未分片数据兼容
+func (s *RedisStore) fmtQueueKey(shard int, queueType string) string {
+	if s.shards <= 1 {
+		return fmt.Sprintf(queueKey, s.prefix, queueType)
+	}
+	return fmt.Sprintf(shardQueueKey, s.prefix, shard, queueType)
+}
+
+func newRedisDelivery(shard int, taskJson string) *Delivery {
+	d := &Delivery{
+		Raw:     taskJson,
+		Shard:   shard,
+		Receipt: taskJson,
+	}
+	var msg Message
+	if err := json.Unmarshal([]byte(taskJson), &msg); err == nil {
+		d.Message = &msg
+	}
+	return d
+}
+
+// expireMessage 计算可见性超时消息的去向：Attempts、Expiries 加一后写回所属 delayed lane，
+// Expiries 超过 maxExpiries 或无法解析（无法重投递，保留现场）的移入 dead
+func expireMessage(raw string, maxExpiries int) (queueType string, newRaw string) {
+	var msg Message
+	if err := json.Unmarshal([]byte(raw), &msg); err != nil {
+		return deadQueueName, raw
+	}
+
+	msg.Attempts++
+	msg.Expiries++
+	queueType = laneQueueName(msg.Priority)
+	if msg.Expiries > maxExpiries {
+		queueType = deadQueueName
+	}
+
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		return deadQueueName, raw
+	}
+	return queueType, string(mj)
+}
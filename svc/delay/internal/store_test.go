@@ -0,0 +1,565 @@
+package internal
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+// storeFactory 为每个用例创建一个空的 Store
+type storeFactory func(t *testing.T) Store
+
+// TestRedisStore 使用 miniredis 运行 Store 一致性用例
+func TestRedisStore(t *testing.T) {
+	newStore := func(shards int) storeFactory {
+		return func(t *testing.T) Store {
+			mr := miniredis.RunT(t)
+			return NewRedisStore(redis.New(mr.Addr()), WithPrefix("test"), WithShards(shards))
+		}
+	}
+
+	t.Run("single", func(t *testing.T) {
+		runStoreConformance(t, newStore(1))
+	})
+	t.Run("sharded", func(t *testing.T) {
+		runStoreConformance(t, newStore(4))
+	})
+	t.Run("atomic batch across shards", func(t *testing.T) {
+		store := newStore(4)(t).(*RedisStore)
+		var msgs []*Message
+		for i := 0; i < 16; i++ {
+			msgs = append(msgs, &Message{ID: fmt.Sprint(i), Key: fmt.Sprint(i), Timestamp: time.Now().Unix()})
+		}
+		if err := store.PushBatchAtomic(context.Background(), msgs); !errors.Is(err, errBatchCrossShard) {
+			t.Fatalf("PushBatchAtomic got %v, want errBatchCrossShard", err)
+		}
+		if stats, _ := store.Stats(context.Background(), time.Now()); stats.Delayed != 0 {
+			t.Fatalf("PushBatchAtomic wrote %d messages, want none", stats.Delayed)
+		}
+	})
+	t.Run("atomic batch too large", func(t *testing.T) {
+		store := newStore(1)(t).(*RedisStore)
+		msgs := make([]*Message, maxAtomicBatchSize+1)
+		for i := range msgs {
+			msgs[i] = &Message{ID: fmt.Sprint(i), Key: "k", Timestamp: time.Now().Unix()}
+		}
+		if err := store.PushBatchAtomic(context.Background(), msgs); !errors.Is(err, errBatchTooLarge) {
+			t.Fatalf("PushBatchAtomic got %v, want errBatchTooLarge", err)
+		}
+		if err := store.PushBatchAtomic(context.Background(), msgs[:maxAtomicBatchSize]); err != nil {
+			t.Fatalf("PushBatchAtomic max size error: %v", err)
+		}
+	})
+}
+
+// TestMySQLStore 设置环境变量 DELAY_TEST_MYSQL_DSN 后运行 Store 一致性用例
+func TestMySQLStore(t *testing.T) {
+	dsn := os.Getenv("DELAY_TEST_MYSQL_DSN")
+	if len(dsn) == 0 {
+		t.Skip("DELAY_TEST_MYSQL_DSN not set")
+	}
+
+	db, err := gorm.Open(mysql.Open(dsn), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("open mysql error: %v", err)
+	}
+
+	runStoreConformance(t, func(t *testing.T) Store {
+		table := fmt.Sprintf("delay_message_test_%d", time.Now().UnixNano())
+		store := NewMySQLStore(db, WithPrefix("test"), WithMySQLTable(table))
+		if err := store.Migrate(context.Background()); err != nil {
+			t.Fatalf("migrate error: %v", err)
+		}
+		t.Cleanup(func() {
+			_ = db.Migrator().DropTable(table)
+		})
+		return store
+	})
+}
+
+// runStoreConformance Store 实现需要共同满足的行为
+func runStoreConformance(t *testing.T, newStore storeFactory) {
+	ctx := context.Background()
+	now := time.Now()
+	visibleUntil := now.Add(time.Minute)
+
+	push := func(t *testing.T, store Store, key string, due time.Time) *Message {
+		msg := &Message{ID: key + "-id", Key: key, Data: map[string]any{"key": key}, Timestamp: due.Unix()}
+		if err := store.Push(ctx, msg); err != nil {
+			t.Fatalf("Push error: %v", err)
+		}
+		return msg
+	}
+	popAll := func(t *testing.T, store Store, at time.Time, until time.Time) []*Delivery {
+		deliveries, err := store.Pop(ctx, at, 100, until)
+		if err != nil {
+			t.Fatalf("Pop error: %v", err)
+		}
+		return deliveries
+	}
+
+	t.Run("pop only due messages", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "due", now.Add(-time.Second))
+		push(t, store, "future", now.Add(time.Hour))
+
+		deliveries := popAll(t, store, now, visibleUntil)
+		if len(deliveries) != 1 || deliveries[0].Message == nil || deliveries[0].Message.Key != "due" {
+			t.Fatalf("Pop got %+v, want only due message", deliveries)
+		}
+		if again := popAll(t, store, now, visibleUntil); len(again) != 0 {
+			t.Fatalf("Pop again got %d messages, want 0", len(again))
+		}
+	})
+
+	t.Run("pop respects limit", func(t *testing.T) {
+		store := newStore(t)
+		for i := 0; i < 5; i++ {
+			push(t, store, fmt.Sprintf("k%d", i), now.Add(-time.Second))
+		}
+
+		deliveries, err := store.Pop(ctx, now, 3, visibleUntil)
+		if err != nil {
+			t.Fatalf("Pop error: %v", err)
+		}
+		if len(deliveries) != 3 {
+			t.Fatalf("Pop got %d messages, want 3", len(deliveries))
+		}
+		if rest := popAll(t, store, now, visibleUntil); len(rest) != 2 {
+			t.Fatalf("Pop rest got %d messages, want 2", len(rest))
+		}
+	})
+
+	t.Run("ack removes reserved", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "k", now.Add(-time.Second))
+		d := popAll(t, store, now, now.Add(-time.Second))[0]
+
+		if err := store.Ack(ctx, d); err != nil {
+			t.Fatalf("Ack error: %v", err)
+		}
+		if _, _, err := store.ExpireReserved(ctx, now, 100, 3); err != nil {
+			t.Fatalf("ExpireReserved error: %v", err)
+		}
+		if got := popAll(t, store, now, visibleUntil); len(got) != 0 {
+			t.Fatalf("acked message redelivered: %+v", got)
+		}
+	})
+
+	t.Run("release requeues once", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "k", now.Add(-time.Second))
+		d := popAll(t, store, now, visibleUntil)[0]
+
+		msg := *d.Message
+		msg.Attempts++
+		msg.Timestamp = now.Add(-time.Second).Unix()
+		if ok, err := store.Release(ctx, d, &msg); err != nil || !ok {
+			t.Fatalf("Release got %v, %v, want true", ok, err)
+		}
+		if ok, err := store.Release(ctx, d, &msg); err != nil || ok {
+			t.Fatalf("Release twice got %v, %v, want false", ok, err)
+		}
+
+		got := popAll(t, store, now, visibleUntil)
+		if len(got) != 1 || got[0].Message.Attempts != 1 {
+			t.Fatalf("Pop after release got %+v, want one message with attempts 1", got)
+		}
+	})
+
+	t.Run("extend keeps reservation", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "k", now.Add(-time.Second))
+		d := popAll(t, store, now, now.Add(-time.Second))[0]
+
+		if err := store.Extend(ctx, d, now.Add(time.Minute)); err != nil {
+			t.Fatalf("Extend error: %v", err)
+		}
+		if requeued, dead, err := store.ExpireReserved(ctx, now, 100, 3); err != nil || requeued+dead != 0 {
+			t.Fatalf("ExpireReserved after extend got %d, %d, %v, want nothing expired", requeued, dead, err)
+		}
+		if err := store.Ack(ctx, d); err != nil {
+			t.Fatalf("Ack error: %v", err)
+		}
+		if err := store.Extend(ctx, d, now.Add(time.Minute)); !errors.Is(err, ErrReservationLost) {
+			t.Fatalf("Extend after ack got %v, want ErrReservationLost", err)
+		}
+	})
+
+	t.Run("pop draws lanes by weight", func(t *testing.T) {
+		store := newStore(t)
+		for i := 0; i < 10; i++ {
+			for _, p := range []Priority{PriorityLow, PriorityHigh} {
+				msg := &Message{ID: fmt.Sprintf("%s-%d", p, i), Key: fmt.Sprintf("%s-%d", p, i), Priority: p, Timestamp: now.Add(-time.Minute).Unix()}
+				if err := store.Push(ctx, msg); err != nil {
+					t.Fatalf("Push error: %v", err)
+				}
+			}
+		}
+
+		deliveries, err := store.Pop(ctx, now, 5, visibleUntil)
+		if err != nil {
+			t.Fatalf("Pop error: %v", err)
+		}
+		got := map[Priority]int{}
+		for _, d := range deliveries {
+			got[d.Message.Priority]++
+		}
+		if len(deliveries) != 5 || got[PriorityHigh] != 4 || got[PriorityLow] != 1 {
+			t.Fatalf("Pop got %v, want 4 high and 1 low", got)
+		}
+
+		// 重试写回原 lane
+		msg := *deliveries[0].Message
+		if ok, err := store.Release(ctx, deliveries[0], &msg); err != nil || !ok {
+			t.Fatalf("Release got %v, %v, want true", ok, err)
+		}
+		stats, err := store.Stats(ctx, now)
+		if err != nil {
+			t.Fatalf("Stats error: %v", err)
+		}
+		for _, lane := range stats.Lanes {
+			want := map[Priority]int64{PriorityHigh: 7, PriorityNormal: 0, PriorityLow: 9}[lane.Priority]
+			if lane.Delayed != want {
+				t.Fatalf("Stats lane %s delayed got %d, want %d", lane.Priority, lane.Delayed, want)
+			}
+		}
+		if stats.Delayed != 16 || stats.Reserved != 4 {
+			t.Fatalf("Stats got %+v, want 16 delayed and 4 reserved", stats)
+		}
+	})
+
+	t.Run("batch push", func(t *testing.T) {
+		store := newStore(t)
+		batchStore, ok := store.(BatchStore)
+		if !ok {
+			t.Skip("store does not implement BatchStore")
+		}
+
+		msgs := []*Message{
+			{ID: "a", Key: "a", Timestamp: now.Add(-time.Second).Unix()},
+			{ID: "b", Key: "b", Priority: PriorityHigh, Timestamp: now.Add(-time.Second).Unix()},
+			{ID: "c", Key: "c", Timestamp: now.Add(time.Hour).Unix()},
+		}
+		if errs := batchStore.PushBatch(ctx, msgs); errs != nil {
+			t.Fatalf("PushBatch errors: %v", errs)
+		}
+		if got := popAll(t, store, now, visibleUntil); len(got) != 2 {
+			t.Fatalf("Pop after PushBatch got %d messages, want 2", len(got))
+		}
+
+		// 相同 Key 保证分片场景下位于同一分片
+		atomicMsgs := []*Message{
+			{ID: "d1", Key: "d", Timestamp: now.Add(-time.Second).Unix()},
+			{ID: "d2", Key: "d", Priority: PriorityLow, Timestamp: now.Add(-time.Second).Unix()},
+		}
+		if err := batchStore.PushBatchAtomic(ctx, atomicMsgs); err != nil {
+			t.Fatalf("PushBatchAtomic error: %v", err)
+		}
+		if got := popAll(t, store, now, visibleUntil); len(got) != 2 {
+			t.Fatalf("Pop after PushBatchAtomic got %d messages, want 2", len(got))
+		}
+	})
+
+	t.Run("stats counts queues and due histogram", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "due-a", now.Add(-time.Minute))
+		push(t, store, "due-b", now.Add(-time.Second))
+		push(t, store, "soon", now.Add(time.Minute))
+		push(t, store, "later", now.Add(time.Minute*12))
+		push(t, store, "tomorrow", now.Add(time.Hour*24))
+
+		stats, err := store.Stats(ctx, now)
+		if err != nil {
+			t.Fatalf("Stats error: %v", err)
+		}
+		if stats.Delayed != 5 || stats.Reserved != 0 || stats.Dead != 0 || stats.Due != 2 {
+			t.Fatalf("Stats got %+v, want 5 delayed and 2 due", stats)
+		}
+		if stats.OldestDueLag < time.Minute-time.Second || stats.OldestDueLag > time.Minute+time.Second {
+			t.Fatalf("Stats OldestDueLag got %v, want about 1m", stats.OldestDueLag)
+		}
+		if len(stats.DueHistogram) != 12 || stats.DueHistogram[0].Count != 1 || stats.DueHistogram[2].Count != 1 {
+			t.Fatalf("Stats DueHistogram got %+v, want one in 0-5m and one in 10-15m", stats.DueHistogram)
+		}
+
+		popAll(t, store, now, visibleUntil)
+		if stats, err = store.Stats(ctx, now); err != nil || stats.Delayed != 3 || stats.Reserved != 2 || stats.OldestDueLag != 0 {
+			t.Fatalf("Stats after pop got %+v, %v, want 3 delayed, 2 reserved and no lag", stats, err)
+		}
+	})
+
+	t.Run("expire requeues then dead letters", func(t *testing.T) {
+		store := newStore(t)
+		push(t, store, "k", now.Add(-time.Second))
+		first := popAll(t, store, now, now.Add(-time.Second))[0]
+
+		if requeued, dead, err := store.ExpireReserved(ctx, now, 100, 1); err != nil || requeued != 1 || dead != 0 {
+			t.Fatalf("ExpireReserved got %d, %d, %v, want 1 requeued", requeued, dead, err)
+		}
+		if ok, _ := store.Release(ctx, first, first.Message); ok {
+			t.Fatalf("Release with expired delivery succeeded, want false")
+		}
+
+		second := popAll(t, store, now, now.Add(-time.Second))
+		if len(second) != 1 || second[0].Message.Attempts != 1 || second[0].Message.Expiries != 1 {
+			t.Fatalf("Pop after expire got %+v, want one message with attempts 1 and expiries 1", second)
+		}
+
+		if requeued, dead, err := store.ExpireReserved(ctx, now, 100, 1); err != nil || requeued != 0 || dead != 1 {
+			t.Fatalf("ExpireReserved got %d, %d, %v, want 1 dead", requeued, dead, err)
+		}
+		if got := popAll(t, store, now, visibleUntil); len(got) != 0 {
+			t.Fatalf("dead message redelivered: %+v", got)
+		}
+	})
+}
+
+// TestMySQLStoreSQL 使用 sqlmock 校验 MySQLStore 出队加锁、receipt 条件和 Ack/Release 语句，无需真实 MySQL
+func TestMySQLStoreSQL(t *testing.T) {
+	store, mock := newMySQLMockStore(t)
+	ctx := context.Background()
+	now := time.Now()
+	visibleUntil := now.Add(time.Minute)
+
+	// Pop：按 lane 配额 6/3/1 依次 SKIP LOCKED 加锁，再以新 receipt 迁移到 reserved
+	mock.ExpectBegin()
+	mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:high", now.Unix(), 6).
+		WillReturnRows(sqlmock.NewRows(mysqlColumns).AddRow(7, "test", "delayed:high", now.Unix(), "m1", "", `{"id":"m1"}`))
+	mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed", now.Unix(), 3).WillReturnRows(sqlmock.NewRows(mysqlColumns))
+	mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:low", now.Unix(), 1).WillReturnRows(sqlmock.NewRows(mysqlColumns))
+	mock.ExpectExec("UPDATE `delay_message` SET `due_at`=\\?,`queue`=\\?,`receipt`=\\?,`updated_at`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(visibleUntil.Unix(), reservedQueueName, sqlmock.AnyArg(), sqlmock.AnyArg(), 7).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	deliveries, err := store.Pop(ctx, now, 10, visibleUntil)
+	if err != nil || len(deliveries) != 1 || deliveries[0].Message.ID != "m1" {
+		t.Fatalf("Pop got %v, error:%v", deliveries, err)
+	}
+	d := deliveries[0]
+	_, receipt, err := parseMySQLReceipt(d.Receipt)
+	if err != nil || len(receipt) == 0 {
+		t.Fatalf("invalid receipt %s, error:%v", d.Receipt, err)
+	}
+
+	// Release：仅当仍以相同 receipt 处于 reserved 时写回 delayed lane，receipt 失效时返回 false
+	releaseSQL := "UPDATE `delay_message` SET `body`=\\?,`due_at`=\\?,`queue`=\\?,`receipt`=\\?,`updated_at`=\\? WHERE id = \\? AND queue = \\? AND receipt = \\?"
+	mock.ExpectExec(releaseSQL).
+		WithArgs(sqlmock.AnyArg(), now.Unix(), "delayed", "", sqlmock.AnyArg(), 7, reservedQueueName, receipt).
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if ok, err := store.Release(ctx, d, &Message{ID: "m1", Timestamp: now.Unix()}); ok || err != nil {
+		t.Fatalf("Release with lost receipt got %v, error:%v, want false", ok, err)
+	}
+
+	// Ack：以 id + receipt 为条件删除
+	mock.ExpectExec("DELETE FROM `delay_message` WHERE id = \\? AND queue = \\? AND receipt = \\?").
+		WithArgs(7, reservedQueueName, receipt).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if err := store.Ack(ctx, d); err != nil {
+		t.Fatalf("Ack error: %v", err)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+const (
+	// mysqlLockSQL lockDue 按到期时间加锁的语句
+	mysqlLockSQL = "SELECT \\* FROM `delay_message` WHERE prefix = \\? AND queue = \\? AND due_at <= \\? ORDER BY due_at ASC, id ASC LIMIT \\? FOR UPDATE SKIP LOCKED"
+	// mysqlUpdateReservedSQL 以 receipt 为条件更新 reserved 的语句
+	mysqlUpdateReservedSQL = "UPDATE `delay_message` SET .* WHERE id = \\? AND queue = \\? AND receipt = \\?"
+)
+
+var mysqlColumns = []string{"id", "prefix", "queue", "due_at", "msg_id", "receipt", "body"}
+
+func newMySQLMockStore(t *testing.T) (*MySQLStore, sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return NewMySQLStore(db, WithPrefix("test")), mock
+}
+
+// messageArg 按 JSON 解析 body 参数并校验消息内容
+type messageArg func(msg Message) bool
+
+func (a messageArg) Match(v driver.Value) bool {
+	body, ok := v.(string)
+	if !ok {
+		return false
+	}
+	var msg Message
+	return json.Unmarshal([]byte(body), &msg) == nil && a(msg)
+}
+
+func mysqlBody(t *testing.T, msg Message) string {
+	mj, err := json.Marshal(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(mj)
+}
+
+// TestMySQLStoreBehavior 使用 sqlmock 校验 MySQLStore 各操作的返回结果
+func TestMySQLStoreBehavior(t *testing.T) {
+	ctx := context.Background()
+	now := time.Now()
+	visibleUntil := now.Add(time.Minute)
+
+	t.Run("pop lanes in priority order", func(t *testing.T) {
+		store, mock := newMySQLMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:high", now.Unix(), 6).
+			WillReturnRows(sqlmock.NewRows(mysqlColumns).AddRow(1, "test", "delayed:high", now.Unix(), "h", "", mysqlBody(t, Message{ID: "h", Priority: PriorityHigh})))
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed", now.Unix(), 3).
+			WillReturnRows(sqlmock.NewRows(mysqlColumns).AddRow(2, "test", "delayed", now.Unix(), "n", "", "invalid"))
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:low", now.Unix(), 1).
+			WillReturnRows(sqlmock.NewRows(mysqlColumns))
+		mock.ExpectExec("UPDATE `delay_message` SET .* WHERE id IN \\(\\?,\\?\\)").
+			WithArgs(visibleUntil.Unix(), reservedQueueName, sqlmock.AnyArg(), sqlmock.AnyArg(), 1, 2).
+			WillReturnResult(sqlmock.NewResult(0, 2))
+		mock.ExpectCommit()
+
+		deliveries, err := store.Pop(ctx, now, 10, visibleUntil)
+		if err != nil || len(deliveries) != 2 {
+			t.Fatalf("Pop got %v, error:%v, want 2 deliveries", deliveries, err)
+		}
+		if deliveries[0].Message == nil || deliveries[0].Message.ID != "h" {
+			t.Fatalf("first delivery %+v, want high lane message", deliveries[0])
+		}
+		// 无法解析的消息保留原始内容，由 Delayer 处理
+		if deliveries[1].Message != nil || deliveries[1].Raw != "invalid" {
+			t.Fatalf("second delivery %+v, want raw invalid message", deliveries[1])
+		}
+		// 同一批次共用 receipt，与各自 id 组合
+		id0, r0, _ := parseMySQLReceipt(deliveries[0].Receipt)
+		id1, r1, _ := parseMySQLReceipt(deliveries[1].Receipt)
+		if id0 != 1 || id1 != 2 || len(r0) == 0 || r0 != r1 {
+			t.Fatalf("receipts %s, %s", deliveries[0].Receipt, deliveries[1].Receipt)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("pop error rolls back", func(t *testing.T) {
+		store, mock := newMySQLMockStore(t)
+		mock.ExpectBegin()
+		// 单个 lane 出错不影响其他 lane 加锁，整个事务回滚
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:high", now.Unix(), 6).WillReturnError(errors.New("lock wait timeout"))
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed", now.Unix(), 3).WillReturnRows(sqlmock.NewRows(mysqlColumns))
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", "delayed:low", now.Unix(), 1).WillReturnRows(sqlmock.NewRows(mysqlColumns))
+		mock.ExpectRollback()
+
+		if deliveries, err := store.Pop(ctx, now, 10, visibleUntil); err == nil || len(deliveries) != 0 {
+			t.Fatalf("Pop got %v, error:%v, want error", deliveries, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("release and ack by receipt", func(t *testing.T) {
+		store, mock := newMySQLMockStore(t)
+		d := &Delivery{Receipt: "7:r1"}
+		msg := &Message{ID: "m1", Attempts: 2, Priority: PriorityLow, Timestamp: now.Unix()}
+
+		// Release 写回消息所在 lane，清空 receipt
+		mock.ExpectExec(mysqlUpdateReservedSQL).
+			WithArgs(messageArg(func(m Message) bool { return m.ID == "m1" && m.Attempts == 2 }), now.Unix(), "delayed:low", "", sqlmock.AnyArg(), 7, reservedQueueName, "r1").
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		if ok, err := store.Release(ctx, d, msg); !ok || err != nil {
+			t.Fatalf("Release got %v, error:%v, want true", ok, err)
+		}
+
+		// Extend 时 receipt 已失效
+		mock.ExpectExec(mysqlUpdateReservedSQL).
+			WithArgs(visibleUntil.Unix(), sqlmock.AnyArg(), 7, reservedQueueName, "r1").
+			WillReturnResult(sqlmock.NewResult(0, 0))
+		if err := store.Extend(ctx, d, visibleUntil); !errors.Is(err, ErrReservationLost) {
+			t.Fatalf("Extend got %v, want ErrReservationLost", err)
+		}
+
+		// Ack 删除失败时返回错误
+		mock.ExpectExec("DELETE FROM `delay_message` WHERE id = \\? AND queue = \\? AND receipt = \\?").
+			WithArgs(7, reservedQueueName, "r1").
+			WillReturnError(errors.New("connection reset"))
+		if err := store.Ack(ctx, d); err == nil {
+			t.Fatal("Ack got nil error, want delete error")
+		}
+
+		// 无效 receipt 不执行 SQL
+		if err := store.Ack(ctx, &Delivery{Receipt: "invalid"}); err == nil {
+			t.Fatal("Ack with invalid receipt got nil error")
+		}
+		if ok, err := store.Release(ctx, &Delivery{Receipt: "x:r1"}, msg); ok || err == nil {
+			t.Fatalf("Release with invalid receipt got %v, error:%v", ok, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("expire reserved requeues and moves to dead", func(t *testing.T) {
+		store, mock := newMySQLMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(mysqlLockSQL).WithArgs("test", reservedQueueName, now.Unix(), 10).
+			WillReturnRows(sqlmock.NewRows(mysqlColumns).
+				AddRow(1, "test", reservedQueueName, now.Unix(), "a", "r1", mysqlBody(t, Message{ID: "a", Attempts: 1, Priority: PriorityHigh})).
+				AddRow(2, "test", reservedQueueName, now.Unix(), "b", "r1", mysqlBody(t, Message{ID: "b", Attempts: 3, Expiries: 3})))
+		// 未超过上限：Attempts、Expiries 加一后立即回到原 lane
+		mock.ExpectExec("UPDATE `delay_message` SET .* WHERE id = \\?").
+			WithArgs(messageArg(func(m Message) bool { return m.Attempts == 2 && m.Expiries == 1 }), now.Unix(), "delayed:high", "", sqlmock.AnyArg(), 1).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		// 超过上限：移入 dead
+		mock.ExpectExec("UPDATE `delay_message` SET .* WHERE id = \\?").
+			WithArgs(messageArg(func(m Message) bool { return m.Attempts == 4 && m.Expiries == 4 }), now.Unix(), deadQueueName, "", sqlmock.AnyArg(), 2).
+			WillReturnResult(sqlmock.NewResult(0, 1))
+		mock.ExpectCommit()
+
+		requeued, dead, err := store.ExpireReserved(ctx, now, 10, 3)
+		if err != nil || requeued != 1 || dead != 1 {
+			t.Fatalf("ExpireReserved got requeued:%d, dead:%d, error:%v, want 1, 1", requeued, dead, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("expire reserved error counts nothing", func(t *testing.T) {
+		store, mock := newMySQLMockStore(t)
+		mock.ExpectBegin()
+		mock.ExpectQuery(mysqlLockSQL).
+			WillReturnRows(sqlmock.NewRows(mysqlColumns).AddRow(1, "test", reservedQueueName, now.Unix(), "a", "r1", mysqlBody(t, Message{ID: "a"})))
+		mock.ExpectExec("UPDATE `delay_message` SET .* WHERE id = \\?").WillReturnError(errors.New("deadlock"))
+		mock.ExpectRollback()
+
+		requeued, dead, err := store.ExpireReserved(ctx, now, 10, 3)
+		if err == nil || requeued != 0 || dead != 0 {
+			t.Fatalf("ExpireReserved got requeued:%d, dead:%d, error:%v, want error", requeued, dead, err)
+		}
+		if err := mock.ExpectationsWereMet(); err != nil {
+			t.Fatal(err)
+		}
+	})
+}
@@ -0,0 +1,171 @@
+package delay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/zhuud/go-library/svc/codec"
+	"github.com/zhuud/go-library/svc/delay/internal"
+)
+
+type (
+	// TypedMessage 解码后的强类型延迟消息
+	TypedMessage[T any] struct {
+		ID        string
+		Key       string
+		Data      T
+		Timestamp int64
+		Attempts  int
+		// Version 推送时的负载 schema 版本，消费端据此兼容旧格式
+		Version int
+		Headers map[string]string
+	}
+
+	// TypedHandler 强类型消息到期处理回调
+	TypedHandler[T any] func(ctx context.Context, msg *TypedMessage[T]) error
+
+	// TypedOption 强类型队列选项
+	TypedOption func(t *typedConfig)
+
+	typedConfig struct {
+		codec   codec.Codec
+		version int
+	}
+
+	// Typed 在 Delay 之上按 codec 编解码 T，handler 直接拿到 T 而不是 map[string]any
+	Typed[T any] struct {
+		delay   *Delay
+		codec   codec.Codec
+		version int
+	}
+)
+
+// WithCodec 配置负载编解码器，默认 codec.JSON；消费端按消息中记录的编解码器名称解码
+func WithCodec(c codec.Codec) TypedOption {
+	return func(t *typedConfig) {
+		if c != nil {
+			t.codec = c
+		}
+	}
+}
+
+// WithSchemaVersion 配置推送时写入信封的负载 schema 版本
+func WithSchemaVersion(version int) TypedOption {
+	return func(t *typedConfig) {
+		t.version = version
+	}
+}
+
+// NewTyped 基于已有 Delay 创建强类型队列，同一 prefix 只能有一种 T 的消费者
+func NewTyped[T any](d *Delay, opts ...TypedOption) *Typed[T] {
+	config := typedConfig{codec: codec.JSON}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	return &Typed[T]{
+		delay:   d,
+		codec:   config.codec,
+		version: config.version,
+	}
+}
+
+// Push 编码 data 后推送延迟消息
+func (t *Typed[T]) Push(ctx context.Context, key string, data T, delayDuration time.Duration, opts ...PushOption) error {
+	b, err := t.codec.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("delay.Typed.Push %s Marshal error: %w", t.codec.Name(), err)
+	}
+
+	// JSON 负载直接内联，其他编解码器以 base64 字符串保存
+	var payload any = b
+	if t.codec.Name() == codec.NameJSON {
+		payload = json.RawMessage(b)
+	}
+
+	msg := &internal.Message{
+		Key:     key,
+		Data:    payload,
+		Codec:   t.codec.Name(),
+		Version: t.version,
+	}
+	for _, opt := range opts {
+		opt(msg)
+	}
+
+	return t.delay.delayer.PushMessage(ctx, msg, delayDuration)
+}
+
+// Start 启动后台消费，解码失败按 handler 返回错误处理（重试或丢弃）
+func (t *Typed[T]) Start(handler TypedHandler[T]) {
+	if handler == nil {
+		panic("delay.Typed.Start handler cannot be nil")
+	}
+
+	t.delay.Start(func(ctx context.Context, msg *Message) error {
+		data, err := decodeTyped[T](msg)
+		if err != nil {
+			return err
+		}
+
+		return handler(ctx, &TypedMessage[T]{
+			ID:        msg.ID,
+			Key:       msg.Key,
+			Data:      data,
+			Timestamp: msg.Timestamp,
+			Attempts:  msg.Attempts,
+			Version:   msg.Version,
+			Headers:   msg.Headers,
+		})
+	})
+}
+
+// Stop 停止消费并等待退出
+func (t *Typed[T]) Stop() {
+	t.delay.Stop()
+}
+
+// decodeTyped 按消息记录的编解码器解码 Data；未记录编解码器的普通消息按 JSON 转换，兼容 Delay.Push 写入的数据
+func decodeTyped[T any](msg *Message) (T, error) {
+	var data T
+
+	raw, ok := msg.Data.(json.RawMessage)
+	if !ok {
+		b, err := json.Marshal(msg.Data)
+		if err != nil {
+			return data, fmt.Errorf("delay.Typed decode Marshal error: %w", err)
+		}
+		raw = b
+	}
+
+	name := msg.Codec
+	if len(name) == 0 {
+		name = codec.NameJSON
+	}
+	c, ok := codec.Get(name)
+	if !ok {
+		return data, fmt.Errorf("delay.Typed decode unknown codec: %s", name)
+	}
+
+	b := []byte(raw)
+	if name != codec.NameJSON {
+		if err := json.Unmarshal(raw, &b); err != nil {
+			return data, fmt.Errorf("delay.Typed decode %s payload error: %w", name, err)
+		}
+	}
+
+	// T 为指针类型（如 protobuf 消息）时分配新对象直接解码
+	target := any(&data)
+	if rt := reflect.TypeFor[T](); rt.Kind() == reflect.Pointer {
+		data = reflect.New(rt.Elem()).Interface().(T)
+		target = data
+	}
+	if err := c.Unmarshal(b, target); err != nil {
+		return data, fmt.Errorf("delay.Typed decode %s Unmarshal error: %w", name, err)
+	}
+
+	return data, nil
+}
@@ -0,0 +1,60 @@
+package delay
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/zhuud/go-library/svc/codec"
+)
+
+type typedPayload struct {
+	OrderID int64  `json:"order_id" msgpack:"order_id"`
+	Status  string `json:"status" msgpack:"status"`
+}
+
+// roundTrip 模拟消息写入存储再取出
+func roundTrip(t *testing.T, c codec.Codec, data typedPayload) *Message {
+	b, err := c.Marshal(data)
+	if err != nil {
+		t.Fatalf("Marshal error: %v", err)
+	}
+	var payload any = b
+	if c.Name() == codec.NameJSON {
+		payload = json.RawMessage(b)
+	}
+
+	mj, err := json.Marshal(&Message{Key: "k", Data: payload, Codec: c.Name(), Version: 2})
+	if err != nil {
+		t.Fatalf("Marshal message error: %v", err)
+	}
+	var msg Message
+	if err := json.Unmarshal(mj, &msg); err != nil {
+		t.Fatalf("Unmarshal message error: %v", err)
+	}
+	return &msg
+}
+
+func TestDecodeTyped(t *testing.T) {
+	want := typedPayload{OrderID: 1<<62 + 1, Status: "paid"}
+
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack} {
+		t.Run(c.Name(), func(t *testing.T) {
+			msg := roundTrip(t, c, want)
+			got, err := decodeTyped[typedPayload](msg)
+			if err != nil {
+				t.Fatalf("decodeTyped error: %v", err)
+			}
+			if got != want || msg.Version != 2 {
+				t.Fatalf("decodeTyped got %+v, version %d, want %+v, version 2", got, msg.Version, want)
+			}
+		})
+	}
+
+	t.Run("untyped message", func(t *testing.T) {
+		msg := &Message{Key: "k", Data: map[string]any{"order_id": float64(7), "status": "paid"}}
+		got, err := decodeTyped[*typedPayload](msg)
+		if err != nil || got == nil || got.OrderID != 7 || got.Status != "paid" {
+			t.Fatalf("decodeTyped got %+v, %v, want order 7", got, err)
+		}
+	})
+}
@@ -82,7 +82,8 @@ func (dao *BaseDao[M, ID]) Transaction(ctx context.Context, fn func(ctx context.
 	if v != nil {
 		tc, ok := v.(*transactionContext)
 		if ok && tc != nil && atomic.LoadInt32(&tc.done) == 0 {
-			return fn(tc.ctx, tc.db)
+			// 嵌套调用复用外层事务，传入携带事务的 ctx，内层的 DB、TxFromContext 仍能取到该事务
+			return fn(ctx, tc.db)
 		}
 	}
 	txCtx := &transactionContext{ctx: ctx}
@@ -91,12 +92,20 @@ func (dao *BaseDao[M, ID]) Transaction(ctx context.Context, fn func(ctx context.
 		newCtx := context.WithValue(ctx, transactionContextKey, txCtx)
 		return fn(newCtx, tx)
 	})
-	if err == nil {
-		atomic.StoreInt32(&txCtx.done, 1)
-	}
+	// 提交或回滚后事务均已结束，ctx 中残留的事务不能再复用
+	atomic.StoreInt32(&txCtx.done, 1)
 	return err
 }
 
+// TxFromContext 返回 ctx 中由 Transaction 开启且尚未结束的事务，供其他组件在同一事务内写入（如消息 outbox）
+func TxFromContext(ctx context.Context) (*gorm.DB, bool) {
+	tc, ok := ctx.Value(transactionContextKey).(*transactionContext)
+	if !ok || tc == nil || tc.db == nil || atomic.LoadInt32(&tc.done) != 0 {
+		return nil, false
+	}
+	return tc.db.WithContext(ctx), true
+}
+
 func (dao *BaseDao[M, ID]) Create(ctx context.Context, m *M) error {
 	return dao.DB(ctx).Create(m).Error
 }
@@ -0,0 +1,94 @@
+package gorm
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type testUser struct {
+	ID   int64
+	Name string
+}
+
+func newMockDao(t *testing.T) (*BaseDao[testUser, int64], sqlmock.Sqlmock) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &BaseDao[testUser, int64]{db: db, tableName: "user"}, mock
+}
+
+func TestTransactionNested(t *testing.T) {
+	dao, mock := newMockDao(t)
+
+	// 内层复用外层事务，通过 TxFromContext 写入（如 kafka.PushInTx）与外层在同一事务提交
+	mock.ExpectBegin()
+	mock.ExpectExec("INSERT INTO `user`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec("INSERT INTO `kafka_outbox`").WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	err := dao.Transaction(t.Context(), func(ctx context.Context, tx *gorm.DB) error {
+		if err := dao.Create(ctx, &testUser{Name: "a"}); err != nil {
+			return err
+		}
+		return dao.Transaction(ctx, func(ctx context.Context, inner *gorm.DB) error {
+			if inner != tx {
+				t.Fatal("nested transaction not reused")
+			}
+			db, ok := TxFromContext(ctx)
+			if !ok {
+				t.Fatal("nested transaction not found in context")
+			}
+			return db.Table("kafka_outbox").Create(map[string]any{"topic": "orders"}).Error
+		})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestTransactionDoneAfterRollback(t *testing.T) {
+	dao, mock := newMockDao(t)
+
+	// 回滚后 ctx 中残留的事务已结束，不能再取出或复用
+	mock.ExpectBegin()
+	mock.ExpectRollback()
+	var txCtx context.Context
+	err := dao.Transaction(t.Context(), func(ctx context.Context, tx *gorm.DB) error {
+		txCtx = ctx
+		return errors.New("rollback")
+	})
+	if err == nil {
+		t.Fatal("Transaction got nil error, want rollback")
+	}
+	if _, ok := TxFromContext(txCtx); ok {
+		t.Fatal("rolled back transaction found in context")
+	}
+
+	// 使用残留的 ctx 会开启新事务
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+	if err := dao.Transaction(txCtx, func(ctx context.Context, tx *gorm.DB) error {
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -5,3 +5,24 @@ import (
 )
 
 type KafkaConf = internal.KafkaConf
+
+// DefaultCluster 默认集群名称，即 Kafka 配置顶层的 brokers 和认证信息
+const DefaultCluster = internal.DefaultCluster
+
+// SASL 机制，配置在 KafkaConf.Mechanism 中，或通过 WithSASLMechanism 指定
+const (
+	SASLPlain       = internal.SASLPlain
+	SASLScramSHA256 = internal.SASLScramSHA256
+	SASLScramSHA512 = internal.SASLScramSHA512
+)
+
+// SetTopicCluster 指定 topic 使用的集群（Kafka.Clusters 中的名称），优先于配置中的 Topics，
+// 需在该 topic 首次推送或消费前调用，Push、Consume、outbox 和延迟消息都按此选择集群
+func SetTopicCluster(topic, cluster string) {
+	internal.SetTopicCluster(topic, cluster)
+}
+
+// TopicCluster 返回 topic 所在的集群名称，未映射时返回 DefaultCluster
+func TopicCluster(topic string) string {
+	return internal.TopicCluster(topic)
+}
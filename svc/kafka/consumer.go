@@ -2,6 +2,7 @@ package kafka
 
 import (
 	"log"
+	"slices"
 	"time"
 
 	"github.com/segmentio/kafka-go"
@@ -14,6 +15,12 @@ type (
 	ReaderOptionFunc = internal.ReaderOptionFunc
 	IConsumeHandler  = internal.ConsumeHandler
 
+	// MessageHandler 以完整 kafka.Message 消费的接口，ConsumeHandler 同时实现该接口时调用 ConsumeMessage，可读取 headers 等元信息
+	MessageHandler = internal.MessageHandler
+
+	// BatchConsumeHandler 批量消费接口，ConsumeHandler 同时实现该接口时按分区攒批调用 ConsumeBatch，不再调用 Consume
+	BatchConsumeHandler = internal.BatchConsumeHandler
+
 	// ConsumeHandler 消费消息的处理器接口
 	ConsumeHandler interface {
 		IConsumeHandler
@@ -22,12 +29,8 @@ type (
 	}
 )
 
-// Consume 启动 Kafka 消费者服务
+// Consume 启动 Kafka 消费者服务，每个 topic 从其所在集群消费
 func Consume(handler ConsumeHandler, opts ...ReaderOptionFunc) {
-	brokers, err := internal.GetServers()
-	if err != nil {
-		log.Fatalf("kafka.consumer brokers empty error: %v", err)
-	}
 	if handler == nil {
 		log.Fatalf("kafka.consumer handler not set")
 	}
@@ -41,45 +44,78 @@ func Consume(handler ConsumeHandler, opts ...ReaderOptionFunc) {
 	serviceGroup := service.NewServiceGroup()
 	defer serviceGroup.Stop()
 
+	// 同一集群的配置只读取一次
+	clusters := make(map[string]KafkaConf)
+	brokers := make(map[string][]string)
 	for _, topic := range handler.Topics() {
+		clusterName := internal.TopicCluster(topic)
+		cluster, ok := clusters[clusterName]
+		if !ok {
+			var err error
+			cluster, err = internal.GetCluster(clusterName)
+			if err != nil {
+				log.Fatalf("kafka.consumer cluster error: %v", err)
+			}
+			clusters[clusterName] = cluster
+			brokers[clusterName] = cluster.Brokers
+		}
+
 		// 每个 topic 使用独立的消费者组，便于独立管理和监控
 		group := topic + ":" + handler.Name()
 
 		// 创建 Reader，所有配置通过 opts 参数传入
-		reader := newReader(brokers, topic, group, handler, opts...)
+		reader := newReader(cluster, topic, group, handler, opts...)
 		serviceGroup.Add(reader)
+
+		// 登记到运行时控制，支持暂停/恢复和调整消费协程数量
+		unregister := registerConsumer(clusterName, topic, handler.Name(), reader)
+		defer unregister()
 	}
 
 	log.Printf("Starting Mq Server At %v, Topics: %v ...", brokers, handler.Topics())
 	serviceGroup.Start()
 }
 
-// newReader 创建 Reader 实例
-func newReader(brokers []string, topic string, group string, handler ConsumeHandler, opts ...ReaderOptionFunc) *internal.Reader {
-	// 从配置读取认证信息
-	username, password := internal.GetSASL()
-	if len(username) > 0 && len(password) > 0 {
-		opts = append(opts, WithSASL(username, password))
-	}
-	caFile := internal.GetTLS()
-	if len(caFile) > 0 {
-		opts = append(opts, WithTLS(caFile))
+// newReader 创建 Reader 实例，集群配置中的认证信息作为默认值，可被 opts 覆盖
+func newReader(cluster KafkaConf, topic string, group string, handler ConsumeHandler, opts ...ReaderOptionFunc) *internal.Reader {
+	return internal.NewReader(cluster.Brokers, topic, group, handler, slices.Concat([]ReaderOptionFunc{WithReaderAuth(cluster)}, opts)...)
+}
+
+// WithReaderAuth 配置完整的 SASL/TLS 认证信息，默认使用 topic 所在集群的配置，auth.Brokers 不使用
+func WithReaderAuth(auth KafkaConf) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.Auth = auth
+		config.Auth.Brokers = nil
 	}
-	return internal.NewReader(brokers, topic, group, handler, opts...)
 }
 
 // WithSASL 配置 SASL 认证
 func WithSASL(username, password string) ReaderOptionFunc {
 	return func(config *internal.ReaderConf) {
-		config.Username = username
-		config.Password = password
+		config.Auth.Username = username
+		config.Auth.Password = password
 	}
 }
 
-// WithTLS 配置 TLS 证书
+// WithSASLMechanism 配置 SASL 机制：SASLPlain（默认）、SASLScramSHA256、SASLScramSHA512
+func WithSASLMechanism(mechanism string) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.Auth.Mechanism = mechanism
+	}
+}
+
+// WithTLS 配置 TLS 证书，caFile 用于校验服务端证书
 func WithTLS(caFile string) ReaderOptionFunc {
 	return func(config *internal.ReaderConf) {
-		config.CaFile = caFile
+		config.Auth.CaFile = caFile
+	}
+}
+
+// WithMTLS 配置 mTLS 客户端证书和私钥
+func WithMTLS(certFile, keyFile string) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.Auth.CertFile = certFile
+		config.Auth.KeyFile = keyFile
 	}
 }
 
@@ -209,6 +245,29 @@ func WithProcessors(processors int) ReaderOptionFunc {
 	}
 }
 
+// WithOrdered 配置有序消费，同一分区的消息固定由一个消费协程串行处理
+func WithOrdered() ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.Ordered = true
+	}
+}
+
+// WithOrderedByKey 配置按 key 有序消费，同一 key 的消息串行处理，同一分区不同 key 可并行，
+// offset 只提交分区内连续处理完成的部分，不会跳过未完成的消息；不支持批量处理器
+func WithOrderedByKey() ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.OrderedByKey = true
+	}
+}
+
+// WithConsumeBatch 配置批量消费每批最大消息数和最长等待时间，处理器实现 BatchConsumeHandler 时生效
+func WithConsumeBatch(size int, timeout time.Duration) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.ConsumeBatchSize = size
+		config.ConsumeBatchTimeout = timeout
+	}
+}
+
 // WithConsumers 配置消费协程数量
 func WithConsumers(consumers int) ReaderOptionFunc {
 	return func(config *internal.ReaderConf) {
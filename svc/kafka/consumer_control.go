@@ -0,0 +1,279 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zeromicro/go-zero/core/proc"
+	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 消费者运行时控制：按 topic + handler name 暂停/恢复获取消息、调整消费协程数量，用于下游故障时临时限流。
+// 通过 StartConsumerAdmin 暴露 HTTP 管理接口，运维可用 kafka-consumer-ctl 子命令调用：
+//
+//	GET  /kafka/consumers                                      查看所有消费者状态
+//	POST /kafka/consumers/pause?topic=xxx&name=xxx             暂停，name 为空时作用于该 topic 的所有消费者
+//	POST /kafka/consumers/resume?topic=xxx&name=xxx            恢复
+//	POST /kafka/consumers/scale?topic=xxx&name=xxx&consumers=N 调整消费协程数量（有序消费和批量消费不支持）
+
+// DefaultConsumerAdminAddr 默认的消费者管理接口监听地址
+const DefaultConsumerAdminAddr = "127.0.0.1:6480"
+
+type (
+	// ConsumerStatus 消费者运行状态
+	ConsumerStatus struct {
+		Cluster   string `json:"cluster"`
+		Topic     string `json:"topic"`
+		Name      string `json:"name"`
+		Group     string `json:"group"`
+		Paused    bool   `json:"paused"`
+		Consumers int    `json:"consumers"`
+		Scalable  bool   `json:"scalable"`
+	}
+
+	// registeredConsumer Consume 启动的 Reader
+	registeredConsumer struct {
+		cluster string
+		topic   string
+		name    string
+		reader  *internal.Reader
+	}
+
+	// adminResponse 管理接口响应
+	adminResponse struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+		Data any    `json:"data,omitempty"`
+	}
+)
+
+var (
+	consumerRegistry sync.Map // group -> *registeredConsumer
+
+	// errConsumerNotFound 没有匹配的消费者
+	errConsumerNotFound = errors.New("kafka consumer not found")
+)
+
+// registerConsumer 登记 Consume 启动的 Reader，返回注销函数
+func registerConsumer(cluster, topic, name string, reader *internal.Reader) func() {
+	consumerRegistry.Store(reader.Group(), &registeredConsumer{cluster: cluster, topic: topic, name: name, reader: reader})
+	return func() {
+		consumerRegistry.Delete(reader.Group())
+	}
+}
+
+// matchConsumers 返回 topic 下名称为 name 的消费者，name 为空时返回该 topic 的所有消费者
+func matchConsumers(topic, name string) ([]*registeredConsumer, error) {
+	if len(topic) == 0 {
+		return nil, fmt.Errorf("kafka consumer topic not set")
+	}
+	var matched []*registeredConsumer
+	consumerRegistry.Range(func(_, v any) bool {
+		c := v.(*registeredConsumer)
+		if c.topic == topic && (len(name) == 0 || c.name == name) {
+			matched = append(matched, c)
+		}
+		return true
+	})
+	if len(matched) == 0 {
+		return nil, fmt.Errorf("%w, topic:%s, name:%s", errConsumerNotFound, topic, name)
+	}
+	return matched, nil
+}
+
+// PauseConsumer 暂停获取消息（含重试 topic），已获取的消息处理完成，消费组成员身份保持不变
+func PauseConsumer(topic, name string) error {
+	consumers, err := matchConsumers(topic, name)
+	if err != nil {
+		return err
+	}
+	for _, c := range consumers {
+		c.reader.Pause()
+	}
+	return nil
+}
+
+// ResumeConsumer 恢复获取消息
+func ResumeConsumer(topic, name string) error {
+	consumers, err := matchConsumers(topic, name)
+	if err != nil {
+		return err
+	}
+	for _, c := range consumers {
+		c.reader.Resume()
+	}
+	return nil
+}
+
+// ScaleConsumer 调整消费协程数量，有序消费和批量消费不支持
+func ScaleConsumer(topic, name string, consumers int) error {
+	matched, err := matchConsumers(topic, name)
+	if err != nil {
+		return err
+	}
+	var errs []error
+	for _, c := range matched {
+		errs = append(errs, c.reader.SetConsumers(consumers))
+	}
+	return errors.Join(errs...)
+}
+
+// ConsumerStatuses 返回当前进程所有消费者的状态，按消费组排序
+func ConsumerStatuses() []ConsumerStatus {
+	var statuses []ConsumerStatus
+	consumerRegistry.Range(func(_, v any) bool {
+		c := v.(*registeredConsumer)
+		statuses = append(statuses, ConsumerStatus{
+			Cluster:   c.cluster,
+			Topic:     c.topic,
+			Name:      c.name,
+			Group:     c.reader.Group(),
+			Paused:    c.reader.Paused(),
+			Consumers: c.reader.Consumers(),
+			Scalable:  c.reader.Scalable(),
+		})
+		return true
+	})
+	slices.SortFunc(statuses, func(a, b ConsumerStatus) int {
+		return strings.Compare(a.Group, b.Group)
+	})
+	return statuses
+}
+
+// ConsumerAdminHandler 返回消费者管理接口的 http.Handler，可挂载到已有的管理端口
+func ConsumerAdminHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /kafka/consumers", func(w http.ResponseWriter, r *http.Request) {
+		writeAdminResponse(w, http.StatusOK, adminResponse{Msg: "ok", Data: ConsumerStatuses()})
+	})
+	mux.HandleFunc("POST /kafka/consumers/{action}", func(w http.ResponseWriter, r *http.Request) {
+		topic, name := r.FormValue("topic"), r.FormValue("name")
+
+		var err error
+		switch r.PathValue("action") {
+		case "pause":
+			err = PauseConsumer(topic, name)
+		case "resume":
+			err = ResumeConsumer(topic, name)
+		case "scale":
+			consumers, perr := strconv.Atoi(r.FormValue("consumers"))
+			if perr != nil {
+				err = fmt.Errorf("kafka consumer invalid consumers: %w", perr)
+				break
+			}
+			err = ScaleConsumer(topic, name, consumers)
+		default:
+			writeAdminResponse(w, http.StatusNotFound, adminResponse{Code: 1, Msg: "unknown action"})
+			return
+		}
+
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errConsumerNotFound) {
+				status = http.StatusNotFound
+			}
+			writeAdminResponse(w, status, adminResponse{Code: 1, Msg: err.Error()})
+			return
+		}
+		log.Printf("kafka consumer %s by admin, topic:%s, name:%s", r.PathValue("action"), topic, name)
+		writeAdminResponse(w, http.StatusOK, adminResponse{Msg: "ok", Data: ConsumerStatuses()})
+	})
+	return mux
+}
+
+// StartConsumerAdmin 在 addr 启动消费者管理接口（后台运行 + 自动注册关闭钩子），addr 为空时使用 DefaultConsumerAdminAddr。
+// 接口没有鉴权，应只监听内网或本机地址
+func StartConsumerAdmin(addr string) {
+	if len(addr) == 0 {
+		addr = DefaultConsumerAdminAddr
+	}
+	server := &http.Server{
+		Addr:              addr,
+		Handler:           ConsumerAdminHandler(),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	threading.GoSafe(func() {
+		log.Printf("Starting Kafka Consumer Admin At %s ...", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			log.Printf("kafka.StartConsumerAdmin ListenAndServe error: %v", err)
+		}
+	})
+	proc.AddShutdownListener(func() {
+		_ = server.Shutdown(context.Background())
+	})
+}
+
+// NewConsumerCtlCommand 返回调用消费者管理接口的命令，可通过 app.AddCommand 注册，例如：
+//
+//	go run main.go kafka-consumer-ctl pause --topic xxx --name xxx --addr 127.0.0.1:6480
+func NewConsumerCtlCommand() *cobra.Command {
+	var (
+		addr      string
+		topic     string
+		name      string
+		consumers int
+	)
+	cmd := &cobra.Command{
+		Use:       "kafka-consumer-ctl [list|pause|resume|scale]",
+		Short:     "pause, resume or scale kafka consumers of a running process",
+		Args:      cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs: []string{"list", "pause", "resume", "scale"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			endpoint := "http://" + addr + "/kafka/consumers"
+			method := http.MethodGet
+			if action := args[0]; action != "list" {
+				method = http.MethodPost
+				query := url.Values{"topic": {topic}, "name": {name}}
+				if action == "scale" {
+					query.Set("consumers", strconv.Itoa(consumers))
+				}
+				endpoint += "/" + action + "?" + query.Encode()
+			}
+
+			req, err := http.NewRequestWithContext(cmd.Context(), method, endpoint, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return fmt.Errorf("kafka-consumer-ctl request error: %w", err)
+			}
+			defer resp.Body.Close()
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				return fmt.Errorf("kafka-consumer-ctl read response error: %w", err)
+			}
+			cmd.Println(string(body))
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("kafka-consumer-ctl %s failed, status:%d", args[0], resp.StatusCode)
+			}
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&addr, "addr", DefaultConsumerAdminAddr, "the consumer admin address of the running process")
+	cmd.Flags().StringVar(&topic, "topic", "", "the consumer topic")
+	cmd.Flags().StringVar(&name, "name", "", "the consumer handler name, empty means all consumers of the topic")
+	cmd.Flags().IntVar(&consumers, "consumers", 0, "the consumers count for scale")
+	return cmd
+}
+
+// writeAdminResponse 输出 JSON 响应
+func writeAdminResponse(w http.ResponseWriter, status int, resp adminResponse) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(resp)
+}
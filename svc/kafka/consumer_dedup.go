@@ -0,0 +1,82 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"github.com/zhuud/go-library/svc/gorm"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// MessageIdHeader 消息唯一 id 的 header，可通过 WithMessageId 在推送时指定
+const MessageIdHeader = internal.MessageIdHeader
+
+// 去重状态
+const (
+	DedupAcquired   = internal.DedupAcquired
+	DedupProcessing = internal.DedupProcessing
+	DedupDone       = internal.DedupDone
+)
+
+type (
+	// DedupStore 是 internal.DedupStore 的类型别名，可自定义去重存储
+	DedupStore = internal.DedupStore
+	// DedupState 是 internal.DedupState 的类型别名
+	DedupState = internal.DedupState
+	// DedupOptionFunc 是 internal.DedupOptionFunc 的类型别名
+	DedupOptionFunc = internal.DedupOptionFunc
+	// RedisDedupStore 是 internal.RedisDedupStore 的类型别名
+	RedisDedupStore = internal.RedisDedupStore
+	// MySQLDedupStore 是 internal.MySQLDedupStore 的类型别名
+	MySQLDedupStore = internal.MySQLDedupStore
+)
+
+// WithDedup 开启幂等消费，同一消费组内同一消息 id 在去重窗口内只成功处理一次；不支持批量处理器。
+// 消息 id 默认取 MessageIdHeader，未设置时使用 topic/partition/offset（重试消息使用首次消费的源位置）
+func WithDedup(store DedupStore, opts ...DedupOptionFunc) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		dedup := internal.DedupConf{Store: store}
+		for _, opt := range opts {
+			opt(&dedup)
+		}
+		config.Dedup = &dedup
+	}
+}
+
+// WithDedupProcessingTTL 配置处理中状态的占用时长，需大于单条消息的最长处理时间，默认 5 分钟
+func WithDedupProcessingTTL(ttl time.Duration) DedupOptionFunc {
+	return func(config *internal.DedupConf) {
+		config.ProcessingTTL = ttl
+	}
+}
+
+// WithDedupTTL 配置已处理状态的保留时长（去重窗口），默认 24 小时
+func WithDedupTTL(ttl time.Duration) DedupOptionFunc {
+	return func(config *internal.DedupConf) {
+		config.TTL = ttl
+	}
+}
+
+// WithDedupIdFunc 配置消息 id 提取函数
+func WithDedupIdFunc(fn func(kafka.Message) string) DedupOptionFunc {
+	return func(config *internal.DedupConf) {
+		config.IdFunc = fn
+	}
+}
+
+// DedupIdFromEnvelope 从消息信封顶层字段读取 id，字段不存在时回退到默认 id，配合 WithDedupIdFunc 使用
+func DedupIdFromEnvelope(field string) func(kafka.Message) string {
+	return internal.DedupIdFromEnvelope(field)
+}
+
+// NewRedisDedupStore 创建基于 Redis SETNX + TTL 的去重存储
+func NewRedisDedupStore(rds *redis.Redis) *RedisDedupStore {
+	return internal.NewRedisDedupStore(rds)
+}
+
+// NewMySQLDedupStore 创建基于 dbName 库的去重存储，table 为空时使用 kafka_dedup；
+// 过期记录可被重新占用，需定期调用 Cleanup 清理，可调用 Migrate 自动建表
+func NewMySQLDedupStore(dbName, table string) *MySQLDedupStore {
+	return internal.NewMySQLDedupStore(gorm.GetDB(dbName), table)
+}
@@ -0,0 +1,60 @@
+package kafka
+
+import (
+	"time"
+
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+type (
+	// LagAlert 是 internal.LagAlert 的类型别名，积压报警信息
+	LagAlert = internal.LagAlert
+	// AlarmSender 是 internal.AlarmSender 的类型别名，*alarm.Alarm 实现了该接口
+	AlarmSender = internal.AlarmSender
+	// LagMonitorOptionFunc 是 internal.LagMonitorOptionFunc 的类型别名
+	LagMonitorOptionFunc = internal.LagMonitorOptionFunc
+)
+
+// WithLagMonitor 开启消费积压监控：定期查询各分区高水位和消费组已提交 offset，
+// 上报 kafka_consumer_lag{topic,group,partition} 和 kafka_consumer_lag_total{topic,group}，超过阈值时报警。
+// 每个实例都上报指标，只有分配到 0 号分区的实例报警；开启后 Reader 使用唯一的 ClientID 识别本实例
+func WithLagMonitor(opts ...LagMonitorOptionFunc) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		monitor := internal.LagMonitorConf{}
+		for _, opt := range opts {
+			opt(&monitor)
+		}
+		config.LagMonitor = &monitor
+	}
+}
+
+// WithLagInterval 配置积压采集间隔，默认 30 秒
+func WithLagInterval(interval time.Duration) LagMonitorOptionFunc {
+	return func(config *internal.LagMonitorConf) {
+		config.Interval = interval
+	}
+}
+
+// WithLagThreshold 配置报警阈值：总积压超过 maxLag，或单次采集间隔内积压增长超过 maxGrowth，0 表示不检查
+func WithLagThreshold(maxLag, maxGrowth int64) LagMonitorOptionFunc {
+	return func(config *internal.LagMonitorConf) {
+		config.MaxLag = maxLag
+		config.MaxLagGrowth = maxGrowth
+	}
+}
+
+// WithLagAlarm 配置报警发送器（如 *alarm.Alarm）和报警消息构造函数，消息格式取决于发送器（如 alarm.LarkMessage），
+// message 为 nil 时发送文本描述
+func WithLagAlarm(sender AlarmSender, message func(LagAlert) any) LagMonitorOptionFunc {
+	return func(config *internal.LagMonitorConf) {
+		config.Alarm = sender
+		config.AlarmMessage = message
+	}
+}
+
+// WithLagAlarmInterval 配置同一消费组两次报警的最小间隔，默认 10 分钟
+func WithLagAlarmInterval(interval time.Duration) LagMonitorOptionFunc {
+	return func(config *internal.LagMonitorConf) {
+		config.AlarmInterval = interval
+	}
+}
@@ -0,0 +1,45 @@
+package kafka
+
+import (
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 内置消费中间件名称，默认顺序由外到内为 tracing、logging、metrics、recover
+const (
+	MiddlewareTracing = internal.MiddlewareTracing
+	MiddlewareLogging = internal.MiddlewareLogging
+	MiddlewareMetrics = internal.MiddlewareMetrics
+	MiddlewareRecover = internal.MiddlewareRecover
+)
+
+type (
+	// ConsumeFunc 是 internal.ConsumeFunc 的类型别名，单条消息处理函数
+	ConsumeFunc = internal.ConsumeFunc
+	// ConsumeMiddleware 是 internal.ConsumeMiddleware 的类型别名
+	ConsumeMiddleware = internal.ConsumeMiddleware
+)
+
+// WithConsumeMiddleware 添加具名消费中间件，默认按添加顺序位于内置中间件之后（由外到内）；
+// 名称用于 WithConsumeMiddlewareOrder 和 WithoutConsumeMiddleware，不能与其他中间件重名
+func WithConsumeMiddleware(name string, middleware ConsumeMiddleware) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.Middlewares = append(config.Middlewares, internal.NamedConsumeMiddleware{
+			Name:       name,
+			Middleware: middleware,
+		})
+	}
+}
+
+// WithConsumeMiddlewareOrder 指定中间件顺序（由外到内），可包含内置和自定义中间件名称，未列出的中间件不启用
+func WithConsumeMiddlewareOrder(names ...string) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.MiddlewareOrder = names
+	}
+}
+
+// WithoutConsumeMiddleware 禁用指定名称的中间件；禁用 recover 后处理器 panic 会导致消费协程退出
+func WithoutConsumeMiddleware(names ...string) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.DisabledMiddlewares = append(config.DisabledMiddlewares, names...)
+	}
+}
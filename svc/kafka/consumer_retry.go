@@ -0,0 +1,95 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 消费失败重试：失败消息按本次退避时长写入 <topic>.retry.<group>.<退避时长>（如 .1s、.2s），到期后再次消费，
+// 每个退避时长一个重试 topic，长退避的消息不会挡住短退避的消息；
+// 重试用尽后写入 <topic>.dlq.<group> 并携带错误信息，可通过 ReplayDeadLetter 重放回源 topic。
+// 其中 group 为 Consume 使用的消费组 <topic>:<handler name>，非本地环境需按 RetryTopics 预先创建重试 topic。
+
+// 重试与死信消息的 header
+const (
+	RetryAttemptHeader    = internal.RetryAttemptHeader
+	RetryDueHeader        = internal.RetryDueHeader
+	OriginTopicHeader     = internal.OriginTopicHeader
+	OriginPartitionHeader = internal.OriginPartitionHeader
+	OriginOffsetHeader    = internal.OriginOffsetHeader
+	DeadErrorHeader       = internal.DeadErrorHeader
+	DeadFailedAtHeader    = internal.DeadFailedAtHeader
+	DeadGroupHeader       = internal.DeadGroupHeader
+)
+
+// WithRetry 配置消费失败后的最大重试次数和首次退避时间（之后每次翻倍），重试用尽后写入死信 topic
+func WithRetry(attempts int, backoff time.Duration) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.RetryAttempts = attempts
+		config.RetryBackoff = backoff
+	}
+}
+
+// WithRetryMaxBackoff 配置重试退避时间上限
+func WithRetryMaxBackoff(max time.Duration) ReaderOptionFunc {
+	return func(config *internal.ReaderConf) {
+		config.RetryMaxBackoff = max
+	}
+}
+
+// RetryTopic 返回 topic 在消费者 name 下的重试 topic 前缀
+func RetryTopic(topic, name string) string {
+	return internal.RetryTopic(topic, topic+":"+name)
+}
+
+// RetryTopics 按重试配置（WithRetry、WithRetryMaxBackoff）返回 topic 在消费者 name 下的全部重试 topic
+func RetryTopics(topic, name string, opts ...ReaderOptionFunc) []string {
+	return internal.RetryTopics(topic, topic+":"+name, opts...)
+}
+
+// DeadLetterTopic 返回 topic 在消费者 name 下的死信 topic
+func DeadLetterTopic(topic, name string) string {
+	return internal.DeadLetterTopic(topic, topic+":"+name)
+}
+
+// ReplayDeadLetter 把 topic 在消费者 name 下的死信消息重新投递回 topic，返回重放条数，limit <= 0 表示全部重放
+func ReplayDeadLetter(ctx context.Context, topic, name string, limit int) (int, error) {
+	if len(topic) == 0 || len(name) == 0 {
+		return 0, fmt.Errorf("kafka.ReplayDeadLetter topic or name not set")
+	}
+	// 死信 topic 与源 topic 在同一集群
+	cluster, err := internal.RouteTopic(topic)
+	if err != nil {
+		return 0, err
+	}
+	return internal.ReplayDeadLetter(ctx, cluster.Brokers, topic, topic+":"+name, limit, WithReaderAuth(cluster))
+}
+
+// NewReplayDeadLetterCommand 创建重放死信消息的命令，通过 app.AddCommand 注册后执行：
+// go run main.go -f etc/config.yaml kafka-dlq-replay --topic xxx --name xxx [--limit 100]
+func NewReplayDeadLetterCommand() *cobra.Command {
+	var (
+		topic string
+		name  string
+		limit int
+	)
+	cmd := &cobra.Command{
+		Use:   "kafka-dlq-replay",
+		Short: "replay kafka dead letter messages back to the source topic",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			replayed, err := ReplayDeadLetter(cmd.Context(), topic, name, limit)
+			cmd.Printf("kafka dead letter replayed %d messages, from:%s, to:%s\n", replayed, DeadLetterTopic(topic, name), topic)
+			return err
+		},
+	}
+	cmd.Flags().StringVar(&topic, "topic", "", "the source topic")
+	cmd.Flags().StringVar(&name, "name", "", "the consumer handler name")
+	cmd.Flags().IntVar(&limit, "limit", 0, "max messages to replay, 0 means all")
+	_ = cmd.MarkFlagRequired("topic")
+	_ = cmd.MarkFlagRequired("name")
+	return cmd
+}
@@ -0,0 +1,150 @@
+package internal
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/segmentio/kafka-go/sasl"
+	"github.com/segmentio/kafka-go/sasl/plain"
+	"github.com/segmentio/kafka-go/sasl/scram"
+)
+
+const (
+	// SASLPlain SASL PLAIN 机制
+	SASLPlain = "PLAIN"
+	// SASLScramSHA256 SASL SCRAM-SHA-256 机制
+	SASLScramSHA256 = "SCRAM-SHA-256"
+	// SASLScramSHA512 SASL SCRAM-SHA-512 机制
+	SASLScramSHA512 = "SCRAM-SHA-512"
+
+	// defaultDialTimeout 默认建立连接的超时时间，与 kafka.DefaultDialer 一致
+	defaultDialTimeout = 10 * time.Second
+)
+
+// HasSASL 是否配置了 SASL 认证
+func (c KafkaConf) HasSASL() bool {
+	return len(c.Username) > 0 && len(c.Password) > 0
+}
+
+// HasTLS 是否开启 TLS
+func (c KafkaConf) HasTLS() bool {
+	return c.TLS || len(c.CaFile) > 0 || len(c.CertFile) > 0 || len(c.KeyFile) > 0
+}
+
+// HasAuth 是否配置了 SASL 或 TLS
+func (c KafkaConf) HasAuth() bool {
+	return c.HasSASL() || c.HasTLS()
+}
+
+// NewDialer 根据认证配置创建 Reader 使用的 Dialer，未配置认证时返回 nil 使用默认 Dialer
+func NewDialer(c KafkaConf) (*kafka.Dialer, error) {
+	if !c.HasAuth() {
+		return nil, nil
+	}
+	mechanism, tlsConfig, err := newAuth(c)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Dialer{
+		Timeout:       defaultDialTimeout,
+		DualStack:     true,
+		SASLMechanism: mechanism,
+		TLS:           tlsConfig,
+	}, nil
+}
+
+// NewTransport 根据认证配置创建 Writer 和 Client 使用的 Transport，未配置认证时返回 nil 使用默认 Transport
+func NewTransport(c KafkaConf) (kafka.RoundTripper, error) {
+	if !c.HasAuth() {
+		return nil, nil
+	}
+	mechanism, tlsConfig, err := newAuth(c)
+	if err != nil {
+		return nil, err
+	}
+	return &kafka.Transport{
+		SASL: mechanism,
+		TLS:  tlsConfig,
+	}, nil
+}
+
+// newAuth 创建 SASL 机制和 TLS 配置，未配置时对应返回 nil
+func newAuth(c KafkaConf) (sasl.Mechanism, *tls.Config, error) {
+	mechanism, err := newSASLMechanism(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig, err := newTLSConfig(c)
+	if err != nil {
+		return nil, nil, err
+	}
+	return mechanism, tlsConfig, nil
+}
+
+// newSASLMechanism 按 Mechanism 创建 SASL 机制，未配置用户名密码时返回 nil
+func newSASLMechanism(c KafkaConf) (sasl.Mechanism, error) {
+	if !c.HasSASL() {
+		return nil, nil
+	}
+
+	switch strings.ToUpper(c.Mechanism) {
+	case "", SASLPlain:
+		return plain.Mechanism{Username: c.Username, Password: c.Password}, nil
+	case SASLScramSHA256:
+		mechanism, err := scram.Mechanism(scram.SHA256, c.Username, c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.newSASLMechanism scram error: %w", err)
+		}
+		return mechanism, nil
+	case SASLScramSHA512:
+		mechanism, err := scram.Mechanism(scram.SHA512, c.Username, c.Password)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.newSASLMechanism scram error: %w", err)
+		}
+		return mechanism, nil
+	default:
+		return nil, fmt.Errorf("kafka.newSASLMechanism unsupported mechanism: %s", c.Mechanism)
+	}
+}
+
+// newTLSConfig 创建校验服务端证书的 TLS 配置，配置了客户端证书时启用 mTLS，未开启 TLS 时返回 nil
+func newTLSConfig(c KafkaConf) (*tls.Config, error) {
+	if !c.HasTLS() {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		ServerName: c.ServerName,
+	}
+
+	if len(c.CaFile) > 0 {
+		caCert, err := os.ReadFile(c.CaFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.newTLSConfig read CA file error: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(caCert) {
+			return nil, fmt.Errorf("kafka.newTLSConfig parse CA file %s failed", c.CaFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if len(c.CertFile) > 0 || len(c.KeyFile) > 0 {
+		if len(c.CertFile) == 0 || len(c.KeyFile) == 0 {
+			return nil, fmt.Errorf("kafka.newTLSConfig cert_file and key_file must be set together")
+		}
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("kafka.newTLSConfig load client certificate error: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	return tlsConfig, nil
+}
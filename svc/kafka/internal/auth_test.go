@@ -0,0 +1,115 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestNewSASLMechanism(t *testing.T) {
+	cases := map[string]string{
+		"":              SASLPlain,
+		"plain":         SASLPlain,
+		SASLScramSHA256: SASLScramSHA256,
+		SASLScramSHA512: SASLScramSHA512,
+	}
+	for mechanism, want := range cases {
+		m, err := newSASLMechanism(KafkaConf{Mechanism: mechanism, Username: "u", Password: "p"})
+		if err != nil || m.Name() != want {
+			t.Fatalf("mechanism %q got %v, error:%v, want %s", mechanism, m, err, want)
+		}
+	}
+
+	if _, err := newSASLMechanism(KafkaConf{Mechanism: "GSSAPI", Username: "u", Password: "p"}); err == nil {
+		t.Fatalf("unsupported mechanism should fail")
+	}
+	if m, err := newSASLMechanism(KafkaConf{Mechanism: SASLScramSHA512}); m != nil || err != nil {
+		t.Fatalf("no credentials got %v, error:%v, want nil", m, err)
+	}
+}
+
+func TestNewTransport(t *testing.T) {
+	if transport, err := NewTransport(KafkaConf{}); transport != nil || err != nil {
+		t.Fatalf("no auth got %v, error:%v, want nil", transport, err)
+	}
+
+	dir := t.TempDir()
+	caFile, certFile, keyFile := writeTestCert(t, dir)
+
+	transport, err := NewTransport(KafkaConf{
+		Mechanism: SASLScramSHA256,
+		Username:  "u",
+		Password:  "p",
+		CaFile:    caFile,
+		CertFile:  certFile,
+		KeyFile:   keyFile,
+	})
+	if err != nil {
+		t.Fatalf("NewTransport error: %v", err)
+	}
+	tlsConfig := transport.(*kafka.Transport).TLS
+	if tlsConfig.InsecureSkipVerify || tlsConfig.RootCAs == nil || len(tlsConfig.Certificates) != 1 {
+		t.Fatalf("unexpected tls config: %+v", tlsConfig)
+	}
+
+	// 只开启 TLS 时使用系统根证书
+	dialer, err := NewDialer(KafkaConf{TLS: true})
+	if err != nil || dialer.TLS == nil || dialer.TLS.RootCAs != nil || dialer.SASLMechanism != nil {
+		t.Fatalf("tls only got %+v, error:%v", dialer, err)
+	}
+
+	if _, err := NewDialer(KafkaConf{CertFile: certFile}); err == nil {
+		t.Fatalf("cert without key should fail")
+	}
+	if _, err := NewDialer(KafkaConf{CaFile: keyFile}); err == nil {
+		t.Fatalf("invalid CA file should fail")
+	}
+}
+
+// writeTestCert 生成自签名证书，同时作为 CA 和客户端证书
+func writeTestCert(t *testing.T, dir string) (caFile, certFile, keyFile string) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "kafka-test"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	certFile = filepath.Join(dir, "cert.pem")
+	keyFile = filepath.Join(dir, "key.pem")
+	certPem := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := os.WriteFile(certFile, certPem, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, certFile, keyFile
+}
@@ -0,0 +1,43 @@
+package internal
+
+import (
+	"slices"
+	"strconv"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// PartitionHeader 指定写入分区的 header，Writer 写入前将其移除并转交 partitionBalancer，不会存储到 broker
+const PartitionHeader = "x-partition"
+
+type (
+	// partitionChoice 指定的写入分区，由 Writer 放入 kafka.Message.WriterData
+	partitionChoice int
+
+	// partitionBalancer 优先使用指定的分区，未指定或分区不存在时交给原平衡器
+	partitionBalancer struct {
+		balancer kafka.Balancer
+	}
+)
+
+// Balance 实现 kafka.Balancer
+func (b partitionBalancer) Balance(msg kafka.Message, partitions ...int) int {
+	if partition, ok := msg.WriterData.(partitionChoice); ok && slices.Contains(partitions, int(partition)) {
+		return int(partition)
+	}
+	return b.balancer.Balance(msg, partitions...)
+}
+
+// choosePartition 移除 PartitionHeader，把其中的分区放入 WriterData 交给 partitionBalancer，不修改调用方的 headers
+func choosePartition(msg *kafka.Message) {
+	m := NewMessage(msg)
+	v := m.GetHeader(PartitionHeader)
+	if len(v) == 0 {
+		return
+	}
+	if partition, err := strconv.Atoi(v); err == nil {
+		msg.WriterData = partitionChoice(partition)
+	}
+	msg.Headers = slices.Clone(msg.Headers)
+	m.DelHeader(PartitionHeader)
+}
@@ -0,0 +1,46 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestPartitionBalancer(t *testing.T) {
+	b := partitionBalancer{balancer: &kafka.Hash{}}
+	partitions := []int{0, 1, 2, 3}
+
+	// 指定分区的 header 写入前移除，不修改调用方的 headers
+	headers := []kafka.Header{{Key: PartitionHeader, Value: []byte("2")}, {Key: "trace", Value: []byte("t")}}
+	msg := kafka.Message{Key: []byte("order-1"), Headers: headers}
+	choosePartition(&msg)
+	if len(NewMessage(&msg).GetHeader(PartitionHeader)) > 0 || NewMessage(&msg).GetHeader("trace") != "t" {
+		t.Fatalf("headers after choosePartition: %v", msg.Headers)
+	}
+	if string(headers[0].Value) != "2" || len(headers) != 2 {
+		t.Fatalf("caller headers modified: %v", headers)
+	}
+	if got := b.Balance(msg, partitions...); got != 2 {
+		t.Fatalf("Balance got %d, want chosen partition 2", got)
+	}
+
+	// 分区不存在时回退到原平衡器
+	msg.WriterData = partitionChoice(9)
+	if got, want := b.Balance(msg, partitions...), (&kafka.Hash{}).Balance(msg, partitions...); got != want {
+		t.Fatalf("Balance got %d, want fallback %d", got, want)
+	}
+}
+
+func TestCalculateDurationCustomKey(t *testing.T) {
+	now := time.Now()
+	value, _ := json.Marshal(map[string]any{"timestamp": now.Add(-time.Minute).Unix()})
+
+	for _, key := range []string{"order-1", "12345"} {
+		duration := calculateDuration(kafka.Message{Key: []byte(key), Value: value}, now)
+		if duration < time.Minute-time.Second || duration > time.Minute+time.Second {
+			t.Errorf("calculateDuration key %s got %v, want envelope timestamp fallback", key, duration)
+		}
+	}
+}
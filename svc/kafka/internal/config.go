@@ -3,6 +3,8 @@ package internal
 import (
 	"fmt"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/zhuud/go-library/svc/conf"
@@ -15,48 +17,157 @@ const (
 	defaultWriterSlowThreshold = time.Second * 2
 )
 
-type KafkaConf struct {
-	Brokers  []string `json:"brokers"`
-	Username string   `json:"username"`
-	Password string   `json:"password"`
-	CaFile   string   `json:"ca_file"`
+// DefaultCluster 默认集群名称，未映射到命名集群的 topic 使用默认集群
+const DefaultCluster = "default"
+
+type (
+	// KafkaConf Kafka 连接配置，认证部分同时用于 Reader 和 Writer。
+	// 顶层为默认集群，Clusters 配置命名集群，命名集群的 Topics 列出使用该集群的 topic，例如：
+	//
+	//	Kafka:
+	//	  Brokers: [127.0.0.1:9092]
+	//	  Clusters:
+	//	    log:
+	//	      Brokers: [127.0.0.1:9192]
+	//	      Mechanism: SCRAM-SHA-512
+	//	      Username: xxx
+	//	      Password: xxx
+	//	      Topics: [app-log, access-log]
+	KafkaConf struct {
+		Brokers    []string             `json:"brokers"`
+		Mechanism  string               `json:"mechanism"`   // SASL 机制：PLAIN（默认）、SCRAM-SHA-256、SCRAM-SHA-512
+		Username   string               `json:"username"`    // SASL 用户名
+		Password   string               `json:"password"`    // SASL 密码
+		TLS        bool                 `json:"tls"`         // 是否开启 TLS，配置了证书文件时自动开启，未配置 CaFile 时使用系统根证书校验服务端
+		CaFile     string               `json:"ca_file"`     // 校验服务端证书的 CA 证书文件路径
+		CertFile   string               `json:"cert_file"`   // 客户端证书文件路径（mTLS）
+		KeyFile    string               `json:"key_file"`    // 客户端私钥文件路径（mTLS）
+		ServerName string               `json:"server_name"` // 校验服务端证书使用的主机名，默认取 broker 地址
+		Topics     []string             `json:"topics"`      // 使用该集群的 topic，仅命名集群有效
+		Clusters   map[string]KafkaConf `json:"clusters"`    // 命名集群，集群名不区分大小写，仅顶层有效
+	}
+
+	// TopicRouter 返回 topic 所在集群的连接配置，一个 Writer 组写入多个 topic 时按 topic 选择集群
+	TopicRouter func(topic string) (KafkaConf, error)
+)
+
+// topicClusterRefreshInterval 配置中 topic 集群映射的缓存时间，配置变更在一个间隔内生效
+const topicClusterRefreshInterval = 10 * time.Second
+
+// topicClusterTable 从配置构建的 topic 集群映射
+type topicClusterTable struct {
+	clusters map[string]string // topic -> 集群名（小写）
+	expireAt time.Time
 }
 
-// GetServers 获取 Kafka 服务器地址列表
-// 优先从 qconf 配置中获取，如果不存在则从 KafkaConf 配置中获取
-func GetServers() ([]string, error) {
-	servers := make([]string, 0)
+var (
+	// topicClusters 代码中指定的 topic 集群映射，优先于配置
+	topicClusters sync.Map // map[string]string
+	// topicClusterCache 配置中的 topic 集群映射，过期后重新构建
+	topicClusterCache atomic.Pointer[topicClusterTable]
+)
 
-	var clusters map[string]struct {
-		Host string `json:"host"`
+// SetTopicCluster 指定 topic 使用的集群，优先于配置中的 Topics
+func SetTopicCluster(topic, cluster string) {
+	topicClusters.Store(topic, strings.ToLower(cluster))
+}
+
+// TopicCluster 返回 topic 所在的集群名称，未映射时返回 DefaultCluster
+func TopicCluster(topic string) string {
+	if cluster, ok := topicClusters.Load(topic); ok {
+		return cluster.(string)
 	}
-	_ = conf.GetUnmarshal(fmt.Sprintf("/qconf/web-config/%s", "kafka_cluster"), &clusters)
-	if cluster, ok := clusters["tobase"]; ok {
-		servers = strings.Split(cluster.Host, ",")
+	if cluster, ok := loadTopicClusters()[topic]; ok {
+		return cluster
 	}
-	if len(servers) == 0 {
-		c := KafkaConf{}
-		_ = conf.GetUnmarshal("Kafka", &c)
-		servers = c.Brokers
+	return DefaultCluster
+}
+
+// loadTopicClusters 返回配置中的 topic 集群映射，缓存过期后从配置重新构建，避免每次推送都读取解析配置
+func loadTopicClusters() map[string]string {
+	now := time.Now()
+	if table := topicClusterCache.Load(); table != nil && now.Before(table.expireAt) {
+		return table.clusters
 	}
-	if len(servers) == 0 {
-		return servers, fmt.Errorf("kafka.GetServers not set address, please set config Kafka")
+
+	clusters := make(map[string]string)
+	for name, cluster := range getKafkaConf().Clusters {
+		for _, topic := range cluster.Topics {
+			clusters[topic] = strings.ToLower(name)
+		}
 	}
-	return servers, nil
+	topicClusterCache.Store(&topicClusterTable{clusters: clusters, expireAt: now.Add(topicClusterRefreshInterval)})
+	return clusters
 }
 
-// GetSASL 获取 Kafka SASL 认证信息
-// 优先从 qconf 配置中获取，如果不存在则从 KafkaConf 配置中获取
-func GetSASL() (username, password string) {
-	c := KafkaConf{}
-	_ = conf.GetUnmarshal("Kafka", &c)
-	return c.Username, c.Password
+// GetCluster 获取集群的连接配置（brokers 和认证），Topics 和 Clusters 不返回。
+// brokers 优先从 qconf 配置中获取（默认集群对应 tobase），如果不存在则从 KafkaConf 配置中获取
+func GetCluster(name string) (KafkaConf, error) {
+	name = strings.ToLower(name)
+	if len(name) == 0 {
+		name = DefaultCluster
+	}
+
+	root := getKafkaConf()
+	c, qconfName := root, "tobase"
+	if name != DefaultCluster {
+		var ok bool
+		if c, ok = lookupCluster(root.Clusters, name); !ok && len(qconfHosts(name)) == 0 {
+			return KafkaConf{}, fmt.Errorf("kafka.GetCluster cluster %s not found, please set config Kafka.Clusters", name)
+		}
+		qconfName = name
+	}
+	if hosts := qconfHosts(qconfName); len(hosts) > 0 {
+		c.Brokers = hosts
+	}
+	c.Topics, c.Clusters = nil, nil
+
+	if len(c.Brokers) == 0 {
+		return c, fmt.Errorf("kafka.GetCluster cluster %s not set address, please set config Kafka", name)
+	}
+	return c, nil
 }
 
-// GetTLS 获取 Kafka TLS CA 证书文件路径
+// RouteTopic 返回 topic 所在集群的连接配置，实现 TopicRouter
+func RouteTopic(topic string) (KafkaConf, error) {
+	return GetCluster(TopicCluster(topic))
+}
+
+// GetServers 获取默认集群的 Kafka 服务器地址列表
 // 优先从 qconf 配置中获取，如果不存在则从 KafkaConf 配置中获取
-func GetTLS() string {
+func GetServers() ([]string, error) {
+	c, err := GetCluster(DefaultCluster)
+	if err != nil {
+		return nil, err
+	}
+	return c.Brokers, nil
+}
+
+// getKafkaConf 读取 Kafka 配置
+func getKafkaConf() KafkaConf {
 	c := KafkaConf{}
 	_ = conf.GetUnmarshal("Kafka", &c)
-	return c.CaFile
+	return c
+}
+
+// lookupCluster 按名称查找命名集群，配置加载后 map key 可能被转为小写，比较时不区分大小写
+func lookupCluster(clusters map[string]KafkaConf, name string) (KafkaConf, bool) {
+	for k, c := range clusters {
+		if strings.ToLower(k) == name {
+			return c, true
+		}
+	}
+	return KafkaConf{}, false
+}
+
+// qconfHosts 从 qconf 的 kafka_cluster 配置中获取集群地址
+func qconfHosts(name string) []string {
+	var clusters map[string]struct {
+		Host string `json:"host"`
+	}
+	_ = conf.GetUnmarshal(fmt.Sprintf("/qconf/web-config/%s", "kafka_cluster"), &clusters)
+	if cluster, ok := clusters[name]; ok && len(cluster.Host) > 0 {
+		return strings.Split(cluster.Host, ",")
+	}
+	return nil
 }
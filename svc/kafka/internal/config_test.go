@@ -0,0 +1,88 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/zhuud/go-library/svc/conf"
+)
+
+func TestGetCluster(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	content := `
+Kafka:
+  Brokers: [127.0.0.1:9092]
+  Username: biz
+  Password: biz-secret
+  Clusters:
+    LogCluster:
+      Brokers: [127.0.0.1:9192, 127.0.0.1:9193]
+      Mechanism: SCRAM-SHA-512
+      Username: log
+      Password: log-secret
+      Topics: [AppLog]
+`
+	if err := os.WriteFile(file, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.SetUp(conf.WithFilePath(file)); err != nil {
+		t.Fatal(err)
+	}
+	topicClusterCache.Store(nil)
+
+	if got := TopicCluster("AppLog"); got != "logcluster" {
+		t.Fatalf("TopicCluster got %s, want logcluster", got)
+	}
+	if got := TopicCluster("order"); got != DefaultCluster {
+		t.Fatalf("TopicCluster got %s, want %s", got, DefaultCluster)
+	}
+
+	c, err := RouteTopic("AppLog")
+	if err != nil {
+		t.Fatalf("RouteTopic error: %v", err)
+	}
+	if !slices.Equal(c.Brokers, []string{"127.0.0.1:9192", "127.0.0.1:9193"}) || c.Username != "log" || c.Mechanism != SASLScramSHA512 || c.Topics != nil {
+		t.Fatalf("unexpected log cluster: %+v", c)
+	}
+
+	c, err = GetCluster(DefaultCluster)
+	if err != nil {
+		t.Fatalf("GetCluster error: %v", err)
+	}
+	if !slices.Equal(c.Brokers, []string{"127.0.0.1:9092"}) || c.Username != "biz" || c.Clusters != nil {
+		t.Fatalf("unexpected default cluster: %+v", c)
+	}
+
+	if _, err := GetCluster("missing"); err == nil {
+		t.Fatalf("missing cluster should fail")
+	}
+
+	// 代码中指定的映射优先于配置
+	SetTopicCluster("order", "LogCluster")
+	defer topicClusters.Delete("order")
+	if got := TopicCluster("order"); got != "logcluster" {
+		t.Fatalf("TopicCluster got %s, want logcluster", got)
+	}
+}
+
+func TestTopicClusterCache(t *testing.T) {
+	defer topicClusterCache.Store(nil)
+
+	// 缓存未过期时直接使用，不读取配置
+	topicClusterCache.Store(&topicClusterTable{clusters: map[string]string{"cached": "logcluster"}, expireAt: time.Now().Add(time.Minute)})
+	if got := TopicCluster("cached"); got != "logcluster" {
+		t.Fatalf("TopicCluster got %s, want cached logcluster", got)
+	}
+
+	// 过期后从配置重新构建
+	topicClusterCache.Store(&topicClusterTable{clusters: map[string]string{"cached": "logcluster"}, expireAt: time.Now().Add(-time.Second)})
+	if got := TopicCluster("cached"); got != DefaultCluster {
+		t.Fatalf("TopicCluster got %s, want %s after refresh", got, DefaultCluster)
+	}
+	if table := topicClusterCache.Load(); table == nil || !table.expireAt.After(time.Now()) {
+		t.Fatalf("cache not refreshed: %+v", table)
+	}
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/logx"
+)
+
+// 幂等消费：重平衡、提交间隔内的重启都会导致消息重复投递，开启去重后同一消费组内同一消息 id 只成功处理一次。
+// 处理前在去重存储中占用 id（processing），成功后标记为 done；失败时释放占用，由重试或重新投递再次处理。
+// 其他消费者正在处理同一 id 时等待其完成，占用超时（进程崩溃等）后可重新占用。
+
+const (
+	// MessageIdHeader 消息唯一 id 的 header，去重时优先使用
+	MessageIdHeader = "x-message-id"
+
+	// defaultDedupProcessingTTL 默认处理中状态的占用时长，需大于单条消息的最长处理时间
+	defaultDedupProcessingTTL = 5 * time.Minute
+	// defaultDedupTTL 默认已处理状态的保留时长，即去重窗口
+	defaultDedupTTL = 24 * time.Hour
+	// dedupWaitMin/dedupWaitMax 等待其他消费者处理完成的轮询间隔
+	dedupWaitMin = 100 * time.Millisecond
+	dedupWaitMax = 2 * time.Second
+)
+
+// DedupState 去重存储中消息 id 的状态
+type DedupState int
+
+const (
+	// DedupAcquired 占用成功，由当前消费者处理
+	DedupAcquired DedupState = iota
+	// DedupProcessing 其他消费者正在处理
+	DedupProcessing
+	// DedupDone 已处理完成
+	DedupDone
+)
+
+type (
+	// DedupStore 去重存储
+	DedupStore interface {
+		// Acquire 尝试以 token 占用 key，占用 ttl 后过期；已被占用或已完成时返回对应状态
+		Acquire(ctx context.Context, key, token string, ttl time.Duration) (DedupState, error)
+		// Done 标记 key 已处理完成，ttl 内重复投递的消息将被跳过
+		Done(ctx context.Context, key, token string, ttl time.Duration) error
+		// Release 释放 token 持有的占用，已被其他 token 占用或已完成时不做处理
+		Release(ctx context.Context, key, token string) error
+	}
+
+	DedupOptionFunc func(config *DedupConf)
+
+	// DedupConf 去重配置
+	DedupConf struct {
+		Store         DedupStore                 // 去重存储
+		ProcessingTTL time.Duration              // 处理中状态的占用时长，默认 5 分钟
+		TTL           time.Duration              // 已处理状态的保留时长，默认 24 小时
+		IdFunc        func(kafka.Message) string // 消息 id 提取函数，默认 DefaultDedupId
+	}
+
+	// dedupHandler 为 MessageHandler 增加去重
+	dedupHandler struct {
+		handler MessageHandler
+		group   string
+		config  DedupConf
+		done    <-chan struct{}
+	}
+)
+
+// DefaultDedupId 默认的消息 id：优先使用 MessageIdHeader；
+// 重试消息使用首次消费时的源 topic/partition/offset，其余使用消息自身的 topic/partition/offset
+func DefaultDedupId(msg kafka.Message) string {
+	m := NewMessage(&msg)
+	if id := m.GetHeader(MessageIdHeader); len(id) > 0 {
+		return id
+	}
+	if topic := m.GetHeader(OriginTopicHeader); len(topic) > 0 {
+		return fmt.Sprintf("%s/%s/%s", topic, m.GetHeader(OriginPartitionHeader), m.GetHeader(OriginOffsetHeader))
+	}
+	return fmt.Sprintf("%s/%d/%d", msg.Topic, msg.Partition, msg.Offset)
+}
+
+// DedupIdFromEnvelope 返回从消息信封顶层字段读取 id 的提取函数，字段不存在时回退到 DefaultDedupId
+func DedupIdFromEnvelope(field string) func(kafka.Message) string {
+	return func(msg kafka.Message) string {
+		var envelope map[string]json.RawMessage
+		if err := json.Unmarshal(msg.Value, &envelope); err == nil {
+			if raw, ok := envelope[field]; ok {
+				var id string
+				if err := json.Unmarshal(raw, &id); err == nil && len(id) > 0 {
+					return id
+				}
+				if s := string(raw); len(s) > 0 && s != "null" {
+					return s
+				}
+			}
+		}
+		return DefaultDedupId(msg)
+	}
+}
+
+// newDedupHandler 创建去重处理器，done 关闭时中断等待
+func newDedupHandler(handler MessageHandler, group string, config DedupConf, done <-chan struct{}) *dedupHandler {
+	if config.ProcessingTTL <= 0 {
+		config.ProcessingTTL = defaultDedupProcessingTTL
+	}
+	if config.TTL <= 0 {
+		config.TTL = defaultDedupTTL
+	}
+	if config.IdFunc == nil {
+		config.IdFunc = DefaultDedupId
+	}
+	return &dedupHandler{
+		handler: handler,
+		group:   group,
+		config:  config,
+		done:    done,
+	}
+}
+
+// ConsumeMessage 实现 MessageHandler，去重存储异常时不去重直接处理，避免消息丢失
+func (h *dedupHandler) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	key := h.group + ":" + h.config.IdFunc(msg)
+	token := uuid.NewString()
+
+	acquired, err := h.acquire(ctx, key, token)
+	if err != nil {
+		if errors.Is(err, errConsumeStopped) {
+			return err
+		}
+		logx.WithContext(ctx).Errorf("kafka.dedup acquire failed, process without dedup, key:%s, error:%v", key, err)
+		return h.handler.ConsumeMessage(ctx, msg)
+	}
+	if !acquired {
+		logx.WithContext(ctx).Infof("kafka.dedup skip duplicate message, key:%s, partition:%d, offset:%d", key, msg.Partition, msg.Offset)
+		return nil
+	}
+
+	if err := h.handler.ConsumeMessage(ctx, msg); err != nil {
+		// 失败时释放占用，重试或重新投递时可再次处理
+		if rerr := h.config.Store.Release(ctx, key, token); rerr != nil {
+			logx.WithContext(ctx).Errorf("kafka.dedup release failed, key:%s, error:%v", key, rerr)
+		}
+		return err
+	}
+
+	if err := h.config.Store.Done(ctx, key, token, h.config.TTL); err != nil {
+		logx.WithContext(ctx).Errorf("kafka.dedup mark done failed, key:%s, error:%v", key, err)
+	}
+	return nil
+}
+
+// acquire 占用 key，其他消费者处理中时等待其完成或占用过期；返回 false 表示已处理完成
+func (h *dedupHandler) acquire(ctx context.Context, key, token string) (bool, error) {
+	wait := dedupWaitMin
+	for {
+		state, err := h.config.Store.Acquire(ctx, key, token, h.config.ProcessingTTL)
+		if err != nil {
+			return false, err
+		}
+		switch state {
+		case DedupAcquired:
+			return true, nil
+		case DedupDone:
+			return false, nil
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-h.done:
+			timer.Stop()
+			return false, errConsumeStopped
+		case <-timer.C:
+		}
+		wait = min(wait*2, dedupWaitMax)
+	}
+}
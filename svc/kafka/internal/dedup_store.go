@@ -0,0 +1,236 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/stores/redis"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultDedupTable 默认 MySQL 去重表名
+	DefaultDedupTable = "kafka_dedup"
+
+	// dedupRedisPrefix Redis 去重 key 前缀
+	dedupRedisPrefix = "kafka:dedup:"
+	// dedupRedisDone Redis 中已处理完成的值，处理中的值为 processing:<token>
+	dedupRedisDone       = "done"
+	dedupRedisProcessing = "processing:"
+
+	dedupStatusProcessing = 0
+	dedupStatusDone       = 1
+	// dedupMaxKeyLength MySQL 去重 key 最大长度，超过时使用 sha256
+	dedupMaxKeyLength = 255
+	// dedupCleanupChunk 每次删除的最大行数，避免大事务
+	dedupCleanupChunk = 1000
+)
+
+// dedupRedisRelease 仅释放自己持有的处理中占用
+const dedupRedisRelease = `
+if redis.call("get", KEYS[1]) == ARGV[1] then
+	return redis.call("del", KEYS[1])
+else
+	return 0
+end`
+
+type (
+	// RedisDedupStore 基于 Redis SETNX + TTL 的去重存储
+	RedisDedupStore struct {
+		redis *redis.Redis
+	}
+
+	// MySQLDedupStore 基于 MySQL 唯一主键的去重存储，过期记录可被重新占用，需定期调用 Cleanup 清理
+	//
+	//	CREATE TABLE `kafka_dedup` (
+	//	  `id` varchar(255) NOT NULL,
+	//	  `status` tinyint NOT NULL DEFAULT 0,
+	//	  `token` varchar(64) NOT NULL DEFAULT '',
+	//	  `expire_at` datetime(3) NOT NULL,
+	//	  `updated_at` datetime(3) NOT NULL,
+	//	  PRIMARY KEY (`id`),
+	//	  KEY `idx_expire_at` (`expire_at`)
+	//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+	MySQLDedupStore struct {
+		db    *gorm.DB
+		table string
+	}
+
+	// DedupRecord MySQL 去重表结构
+	DedupRecord struct {
+		Id        string    `gorm:"column:id;type:varchar(255);primaryKey"`
+		Status    int       `gorm:"column:status;type:tinyint;not null;default:0"`
+		Token     string    `gorm:"column:token;type:varchar(64);not null;default:''"`
+		ExpireAt  time.Time `gorm:"column:expire_at;not null;index:idx_expire_at"`
+		UpdatedAt time.Time `gorm:"column:updated_at;not null"`
+	}
+)
+
+// NewRedisDedupStore 创建 Redis 去重存储
+func NewRedisDedupStore(rds *redis.Redis) *RedisDedupStore {
+	return &RedisDedupStore{redis: rds}
+}
+
+// Acquire 实现 DedupStore
+func (s *RedisDedupStore) Acquire(ctx context.Context, key, token string, ttl time.Duration) (DedupState, error) {
+	key = dedupRedisPrefix + key
+	ok, err := s.redis.SetnxExCtx(ctx, key, dedupRedisProcessing+token, ttlSeconds(ttl))
+	if err != nil {
+		return DedupProcessing, fmt.Errorf("kafka.RedisDedupStore.Acquire SetnxExCtx error: %w", err)
+	}
+	if ok {
+		return DedupAcquired, nil
+	}
+
+	v, err := s.redis.GetCtx(ctx, key)
+	if err != nil {
+		return DedupProcessing, fmt.Errorf("kafka.RedisDedupStore.Acquire GetCtx error: %w", err)
+	}
+	if v == dedupRedisDone {
+		return DedupDone, nil
+	}
+	// 处理中，或在两次调用之间刚好过期，由调用方稍后重试
+	return DedupProcessing, nil
+}
+
+// Done 实现 DedupStore
+func (s *RedisDedupStore) Done(ctx context.Context, key, token string, ttl time.Duration) error {
+	if err := s.redis.SetexCtx(ctx, dedupRedisPrefix+key, dedupRedisDone, ttlSeconds(ttl)); err != nil {
+		return fmt.Errorf("kafka.RedisDedupStore.Done SetexCtx error: %w", err)
+	}
+	return nil
+}
+
+// Release 实现 DedupStore
+func (s *RedisDedupStore) Release(ctx context.Context, key, token string) error {
+	if _, err := s.redis.EvalCtx(ctx, dedupRedisRelease, []string{dedupRedisPrefix + key}, dedupRedisProcessing+token); err != nil {
+		return fmt.Errorf("kafka.RedisDedupStore.Release EvalCtx error: %w", err)
+	}
+	return nil
+}
+
+// NewMySQLDedupStore 创建 MySQL 去重存储，table 为空时使用 kafka_dedup
+func NewMySQLDedupStore(db *gorm.DB, table string) *MySQLDedupStore {
+	if len(table) == 0 {
+		table = DefaultDedupTable
+	}
+	return &MySQLDedupStore{db: db, table: table}
+}
+
+// Migrate 自动创建/更新去重表
+func (s *MySQLDedupStore) Migrate(ctx context.Context) error {
+	return s.db.WithContext(ctx).Table(s.table).AutoMigrate(&DedupRecord{})
+}
+
+// Acquire 实现 DedupStore
+func (s *MySQLDedupStore) Acquire(ctx context.Context, key, token string, ttl time.Duration) (DedupState, error) {
+	key = dedupRecordKey(key)
+	now := time.Now()
+	db := s.db.WithContext(ctx).Table(s.table)
+
+	ret := db.Clauses(clause.Insert{Modifier: "IGNORE"}).Create(&DedupRecord{
+		Id:        key,
+		Status:    dedupStatusProcessing,
+		Token:     token,
+		ExpireAt:  now.Add(ttl),
+		UpdatedAt: now,
+	})
+	if ret.Error != nil {
+		return DedupProcessing, fmt.Errorf("kafka.MySQLDedupStore.Acquire insert error: %w", ret.Error)
+	}
+	if ret.RowsAffected == 1 {
+		return DedupAcquired, nil
+	}
+
+	// 处理超时或去重窗口已过的记录可重新占用
+	ret = s.db.WithContext(ctx).Table(s.table).Where("id = ? AND expire_at < ?", key, now).Updates(map[string]any{
+		"status":     dedupStatusProcessing,
+		"token":      token,
+		"expire_at":  now.Add(ttl),
+		"updated_at": now,
+	})
+	if ret.Error != nil {
+		return DedupProcessing, fmt.Errorf("kafka.MySQLDedupStore.Acquire takeover error: %w", ret.Error)
+	}
+	if ret.RowsAffected == 1 {
+		return DedupAcquired, nil
+	}
+
+	var record DedupRecord
+	err := s.db.WithContext(ctx).Table(s.table).Where("id = ?", key).Take(&record).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return DedupProcessing, nil
+	}
+	if err != nil {
+		return DedupProcessing, fmt.Errorf("kafka.MySQLDedupStore.Acquire query error: %w", err)
+	}
+	if record.Status == dedupStatusDone {
+		return DedupDone, nil
+	}
+	return DedupProcessing, nil
+}
+
+// Done 实现 DedupStore
+func (s *MySQLDedupStore) Done(ctx context.Context, key, token string, ttl time.Duration) error {
+	now := time.Now()
+	err := s.db.WithContext(ctx).Table(s.table).Clauses(clause.OnConflict{
+		DoUpdates: clause.AssignmentColumns([]string{"status", "token", "expire_at", "updated_at"}),
+	}).Create(&DedupRecord{
+		Id:        dedupRecordKey(key),
+		Status:    dedupStatusDone,
+		Token:     token,
+		ExpireAt:  now.Add(ttl),
+		UpdatedAt: now,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("kafka.MySQLDedupStore.Done error: %w", err)
+	}
+	return nil
+}
+
+// Release 实现 DedupStore
+func (s *MySQLDedupStore) Release(ctx context.Context, key, token string) error {
+	err := s.db.WithContext(ctx).Table(s.table).
+		Where("id = ? AND token = ? AND status = ?", dedupRecordKey(key), token, dedupStatusProcessing).
+		Delete(&DedupRecord{}).Error
+	if err != nil {
+		return fmt.Errorf("kafka.MySQLDedupStore.Release error: %w", err)
+	}
+	return nil
+}
+
+// Cleanup 分批删除已过期的记录
+func (s *MySQLDedupStore) Cleanup(ctx context.Context) error {
+	for {
+		ret := s.db.WithContext(ctx).Table(s.table).
+			Where("expire_at < ?", time.Now()).
+			Limit(dedupCleanupChunk).
+			Delete(&DedupRecord{})
+		if ret.Error != nil {
+			return fmt.Errorf("kafka.MySQLDedupStore.Cleanup error: %w", ret.Error)
+		}
+		if ret.RowsAffected < dedupCleanupChunk {
+			return nil
+		}
+	}
+}
+
+// dedupRecordKey 超过主键长度的 key 使用 sha256
+func dedupRecordKey(key string) string {
+	if len(key) <= dedupMaxKeyLength {
+		return key
+	}
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// ttlSeconds 转换为秒，不足一秒按一秒
+func ttlSeconds(ttl time.Duration) int {
+	return max(int(math.Ceil(ttl.Seconds())), 1)
+}
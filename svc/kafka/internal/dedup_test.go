@@ -0,0 +1,156 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// memoryDedupStore 测试用内存去重存储，不处理过期
+type memoryDedupStore struct {
+	mu     sync.Mutex
+	tokens map[string]string
+	done   map[string]bool
+}
+
+func newMemoryDedupStore() *memoryDedupStore {
+	return &memoryDedupStore{tokens: make(map[string]string), done: make(map[string]bool)}
+}
+
+func (s *memoryDedupStore) Acquire(_ context.Context, key, token string, _ time.Duration) (DedupState, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.done[key] {
+		return DedupDone, nil
+	}
+	if _, ok := s.tokens[key]; ok {
+		return DedupProcessing, nil
+	}
+	s.tokens[key] = token
+	return DedupAcquired, nil
+}
+
+func (s *memoryDedupStore) Done(_ context.Context, key, _ string, _ time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.tokens, key)
+	s.done[key] = true
+	return nil
+}
+
+func (s *memoryDedupStore) Release(_ context.Context, key, token string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.tokens[key] == token {
+		delete(s.tokens, key)
+	}
+	return nil
+}
+
+type countingHandler struct {
+	mu    sync.Mutex
+	calls int
+	err   error
+	block chan struct{}
+}
+
+func (h *countingHandler) ConsumeMessage(context.Context, kafka.Message) error {
+	if h.block != nil {
+		<-h.block
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.calls++
+	return h.err
+}
+
+func (h *countingHandler) count() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return h.calls
+}
+
+func TestDedupHandler(t *testing.T) {
+	ctx := context.Background()
+	msg := kafka.Message{Topic: "orders", Partition: 1, Offset: 7}
+	store := newMemoryDedupStore()
+
+	// 失败时释放占用，重新投递可再次处理
+	failing := &countingHandler{err: errors.New("boom")}
+	h := newDedupHandler(failing, "orders:svc", DedupConf{Store: store}, make(chan struct{}))
+	if err := h.ConsumeMessage(ctx, msg); err == nil {
+		t.Fatalf("handler error not returned")
+	}
+
+	handler := &countingHandler{}
+	h = newDedupHandler(handler, "orders:svc", DedupConf{Store: store}, make(chan struct{}))
+	for i := 0; i < 3; i++ {
+		if err := h.ConsumeMessage(ctx, msg); err != nil {
+			t.Fatalf("consume error: %v", err)
+		}
+	}
+	if handler.count() != 1 {
+		t.Fatalf("handler called %d times, want 1", handler.count())
+	}
+
+	// 重试消息按源位置去重
+	retried := kafka.Message{Topic: "orders.retry.orders-svc", Partition: 0, Offset: 3}
+	m := NewMessage(&retried)
+	m.SetHeader(OriginTopicHeader, "orders")
+	m.SetHeader(OriginPartitionHeader, "1")
+	m.SetHeader(OriginOffsetHeader, "7")
+	if err := h.ConsumeMessage(ctx, retried); err != nil || handler.count() != 1 {
+		t.Fatalf("retried message processed again, calls:%d, error:%v", handler.count(), err)
+	}
+}
+
+func TestDedupHandlerWaitProcessing(t *testing.T) {
+	ctx := context.Background()
+	msg := kafka.Message{Topic: "orders", Partition: 0, Offset: 1}
+	store := newMemoryDedupStore()
+
+	first := &countingHandler{block: make(chan struct{})}
+	second := &countingHandler{}
+	done := make(chan struct{})
+	h1 := newDedupHandler(first, "g", DedupConf{Store: store}, done)
+	h2 := newDedupHandler(second, "g", DedupConf{Store: store}, done)
+
+	errs := make(chan error, 2)
+	go func() { errs <- h1.ConsumeMessage(ctx, msg) }()
+	time.Sleep(20 * time.Millisecond)
+	go func() { errs <- h2.ConsumeMessage(ctx, msg) }()
+
+	// 第二个消费者等待第一个完成后跳过
+	time.Sleep(50 * time.Millisecond)
+	close(first.block)
+	for i := 0; i < 2; i++ {
+		if err := <-errs; err != nil {
+			t.Fatalf("consume error: %v", err)
+		}
+	}
+	if first.count() != 1 || second.count() != 0 {
+		t.Fatalf("calls first:%d second:%d, want 1 0", first.count(), second.count())
+	}
+
+	// 停止时中断等待
+	store.tokens["g:stuck"] = "other"
+	stuck := newDedupHandler(second, "g", DedupConf{Store: store, IdFunc: func(kafka.Message) string { return "stuck" }}, done)
+	close(done)
+	if err := stuck.ConsumeMessage(ctx, msg); !errors.Is(err, errConsumeStopped) {
+		t.Fatalf("got %v, want errConsumeStopped", err)
+	}
+}
+
+func TestDedupIdFromEnvelope(t *testing.T) {
+	fn := DedupIdFromEnvelope("msgid")
+	if id := fn(kafka.Message{Value: []byte(`{"msgid":"abc","data":{}}`)}); id != "abc" {
+		t.Fatalf("got %s, want abc", id)
+	}
+	if id := fn(kafka.Message{Topic: "t", Partition: 2, Offset: 9, Value: []byte(`{"data":{}}`)}); id != "t/2/9" {
+		t.Fatalf("got %s, want t/2/9", id)
+	}
+}
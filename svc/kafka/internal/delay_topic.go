@@ -0,0 +1,234 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// DelayTargetHeader 延迟消息到期后投递的目标 topic
+	DelayTargetHeader = "x-delay-target"
+	// DelayDueHeader 延迟消息到期时间，Unix 毫秒
+	DelayDueHeader = "x-delay-due"
+	// DelayForwardedAtHeader 延迟消息到期投递的时间，Unix 纳秒，下游流转耗时从该时刻开始计算
+	DelayForwardedAtHeader = "x-delay-forwarded-at"
+	// DelayForwarderGroup 延迟转发消费者名称，消费组为 <tier topic>:delay-forwarder
+	DelayForwarderGroup = "delay-forwarder"
+
+	// defaultForwardRetryBackoff 转发写入失败后原地重试的首次退避时间
+	defaultForwardRetryBackoff = time.Second
+	// defaultForwardRetryMaxBackoff 转发原地重试的退避时间上限
+	defaultForwardRetryMaxBackoff = 30 * time.Second
+)
+
+// DefaultDelayTiers 默认延迟分级 topic
+var DefaultDelayTiers = []DelayTier{
+	{Topic: "delay-5s", Delay: 5 * time.Second},
+	{Topic: "delay-1m", Delay: time.Minute},
+	{Topic: "delay-10m", Delay: 10 * time.Minute},
+	{Topic: "delay-1h", Delay: time.Hour},
+}
+
+type (
+	// DelayTier 延迟分级 topic，消息在该 topic 中最多停留 Delay 后被转发
+	DelayTier struct {
+		Topic string
+		Delay time.Duration
+	}
+
+	// DelayForwarder 消费一个延迟分级 topic，按分区依次等待消息到期后转发：
+	// 到期则投递到目标 topic，未到期（超过本级时长）则转入剩余时长对应的分级 topic。
+	// 同一分区的消息按写入时间有序，等待队头即相当于暂停该分区，最长暂停时间不超过本级时长；
+	// 每个分区独立拉取，一个分区等待不影响其他分区。
+	DelayForwarder struct {
+		tier       DelayTier
+		tiers      []DelayTier
+		brokers    []string
+		router     TopicRouter
+		writerOpts []WriterOptionFunc
+		reader     *Reader
+		logger     *eventLogger
+		done       chan struct{}
+		stopOnce   sync.Once
+		mu         sync.Mutex
+		writers    map[string]*Writer
+	}
+)
+
+// SortDelayTiers 按延迟时长升序返回分级 topic 副本
+func SortDelayTiers(tiers []DelayTier) []DelayTier {
+	sorted := append([]DelayTier(nil), tiers...)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Delay < sorted[j].Delay
+	})
+	return sorted
+}
+
+// SelectDelayTier 选择不超过 d 的最大分级，d 小于最小分级时使用最小分级；tiers 需按延迟升序排列
+func SelectDelayTier(tiers []DelayTier, d time.Duration) DelayTier {
+	selected := tiers[0]
+	for _, tier := range tiers[1:] {
+		if tier.Delay > d {
+			break
+		}
+		selected = tier
+	}
+	return selected
+}
+
+// SetDelayHeaders 设置延迟消息的目标 topic 和到期时间
+func SetDelayHeaders(msg *kafka.Message, target string, due time.Time) {
+	m := NewMessage(msg)
+	m.SetHeader(DelayTargetHeader, target)
+	m.SetHeader(DelayDueHeader, strconv.FormatInt(due.UnixMilli(), 10))
+}
+
+// NewDelayForwarder 创建延迟分级 topic 的转发消费者，tiers 需按延迟升序排列。
+// 转发使用同步 Writer，确保写入成功后才提交 offset；router 不为 nil 时按目标 topic 选择集群。
+func NewDelayForwarder(brokers []string, tier DelayTier, tiers []DelayTier, readerOpts []ReaderOptionFunc, writerOpts []WriterOptionFunc, router TopicRouter) *DelayForwarder {
+	async := false
+	f := &DelayForwarder{
+		tier:    tier,
+		tiers:   tiers,
+		brokers: brokers,
+		router:  router,
+		writerOpts: append(append([]WriterOptionFunc(nil), writerOpts...), func(config *WriterConf) {
+			config.Async = &async
+		}),
+		done:    make(chan struct{}),
+		writers: make(map[string]*Writer),
+	}
+
+	group := tier.Topic + ":" + DelayForwarderGroup
+	readerOpts = append(append([]ReaderOptionFunc(nil), readerOpts...), func(config *ReaderConf) {
+		config.partitionFetch = true
+	})
+	f.reader = NewMessageReader(brokers, tier.Topic, group, f, readerOpts...)
+	f.logger = newReaderEventLogger(tier.Topic, group)
+
+	return f
+}
+
+// Start 启动转发，阻塞直到 Stop
+func (f *DelayForwarder) Start() {
+	f.reader.Start()
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for topic, writer := range f.writers {
+		if err := writer.Close(); err != nil {
+			f.logger.Errorf(context.Background(), "delay forwarder writer close error, topic:%s, error:%v", topic, err)
+		}
+	}
+}
+
+// Stop 停止转发。先关闭 Reader 再唤醒等待中的消息，被唤醒的消息不会再提交 offset，重启后重新转发
+func (f *DelayForwarder) Stop() {
+	f.stopOnce.Do(func() {
+		f.reader.Stop()
+		close(f.done)
+	})
+}
+
+// ConsumeMessage 实现 MessageHandler，等待消息到期后转发。
+// 写入失败时原地退避重试直到成功；转发停止或分区被重新分配时返回 errConsumeStopped，不提交 offset，之后重新转发
+func (f *DelayForwarder) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	m := NewMessage(&msg)
+	target := m.GetHeader(DelayTargetHeader)
+	dueMs, err := strconv.ParseInt(m.GetHeader(DelayDueHeader), 10, 64)
+	if len(target) == 0 || err != nil {
+		return fmt.Errorf("kafka.delayForwarder invalid delay headers, target:%s, due:%s", target, m.GetHeader(DelayDueHeader))
+	}
+	due := time.UnixMilli(dueMs)
+
+	// 最多在本级停留 tier.Delay，超出部分转入下一跳
+	wake := due
+	if hop := msg.Time.Add(f.tier.Delay); !msg.Time.IsZero() && hop.Before(wake) {
+		wake = hop
+	}
+	if wait := time.Until(wake); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-f.done:
+			timer.Stop()
+			return errConsumeStopped
+		case <-ctx.Done():
+			timer.Stop()
+			return errConsumeStopped
+		}
+	}
+
+	out := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: append([]kafka.Header(nil), msg.Headers...),
+	}
+	topic := target
+	if remaining := time.Until(due); remaining > 0 {
+		topic = SelectDelayTier(f.tiers, remaining).Topic
+	} else {
+		// 到期投递时保留原始 key，记录转发时间，使下游流转耗时从转发时刻开始计算
+		om := NewMessage(&out)
+		om.DelHeader(DelayTargetHeader)
+		om.DelHeader(DelayDueHeader)
+		om.SetHeader(DelayForwardedAtHeader, strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+
+	backoff := defaultForwardRetryBackoff
+	for {
+		err := f.forward(ctx, topic, out)
+		if err == nil || errors.Is(err, errConsumeStopped) {
+			return err
+		}
+		f.logger.Errorf(ctx, "delay forwarder write failed, topic:%s, partition:%d, offset:%d, retry after:%v, error:%v",
+			topic, msg.Partition, msg.Offset, backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-f.done:
+			return errConsumeStopped
+		case <-ctx.Done():
+			return errConsumeStopped
+		}
+		backoff = min(backoff*2, defaultForwardRetryMaxBackoff)
+	}
+}
+
+// forward 把消息写入 topic
+func (f *DelayForwarder) forward(ctx context.Context, topic string, msg kafka.Message) error {
+	writer, err := f.writer(topic)
+	if err != nil {
+		return err
+	}
+	return writer.WriteMessage(ctx, msg)
+}
+
+// writer 获取目标 topic 的 Writer，按 topic 缓存复用
+func (f *DelayForwarder) writer(topic string) (*Writer, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	select {
+	case <-f.done:
+		return nil, errConsumeStopped
+	default:
+	}
+
+	if writer, ok := f.writers[topic]; ok {
+		return writer, nil
+	}
+	writer, err := newRoutedWriter(f.brokers, topic, f.router, f.writerOpts)
+	if err != nil {
+		return nil, err
+	}
+	f.writers[topic] = writer
+	return writer, nil
+}
@@ -0,0 +1,101 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeWriter 前 fails 次写入失败，之后记录写入的消息
+type fakeWriter struct {
+	mu    sync.Mutex
+	fails int
+	msgs  []kafka.Message
+}
+
+func (w *fakeWriter) Close() error {
+	return nil
+}
+
+func (w *fakeWriter) WriteMessages(ctx context.Context, msgs ...kafka.Message) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.fails != 0 {
+		w.fails--
+		return errors.New("broker unavailable")
+	}
+	w.msgs = append(w.msgs, msgs...)
+	return nil
+}
+
+func (w *fakeWriter) written() int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.msgs)
+}
+
+func TestSelectDelayTier(t *testing.T) {
+	tiers := SortDelayTiers([]DelayTier{
+		{Topic: "delay-1h", Delay: time.Hour},
+		{Topic: "delay-5s", Delay: 5 * time.Second},
+		{Topic: "delay-10m", Delay: 10 * time.Minute},
+		{Topic: "delay-1m", Delay: time.Minute},
+	})
+
+	cases := []struct {
+		delay time.Duration
+		want  string
+	}{
+		{time.Second, "delay-5s"},
+		{5 * time.Second, "delay-5s"},
+		{59 * time.Second, "delay-5s"},
+		{time.Minute, "delay-1m"},
+		{30 * time.Minute, "delay-10m"},
+		{3 * time.Hour, "delay-1h"},
+	}
+	for _, c := range cases {
+		if got := SelectDelayTier(tiers, c.delay).Topic; got != c.want {
+			t.Errorf("SelectDelayTier(%v) got %s, want %s", c.delay, got, c.want)
+		}
+	}
+}
+
+func TestDelayForwarderWriteFailure(t *testing.T) {
+	newForwarder := func(w *fakeWriter) *DelayForwarder {
+		f := NewDelayForwarder([]string{"127.0.0.1:1"}, DefaultDelayTiers[0], DefaultDelayTiers, nil, nil, nil)
+		writer := NewWriter([]string{"127.0.0.1:1"}, "orders")
+		writer.writer = w
+		f.writers["orders"] = writer
+		return f
+	}
+	newMsg := func() kafka.Message {
+		msg := kafka.Message{Topic: DefaultDelayTiers[0].Topic, Value: []byte("v")}
+		SetDelayHeaders(&msg, "orders", time.Now().Add(-time.Second))
+		return msg
+	}
+
+	// 写入失败后原地重试直到成功
+	w := &fakeWriter{fails: 1}
+	f := newForwarder(w)
+	if err := f.ConsumeMessage(context.Background(), newMsg()); err != nil {
+		t.Fatalf("ConsumeMessage error: %v", err)
+	}
+	if w.written() != 1 {
+		t.Fatalf("written %d messages, want 1", w.written())
+	}
+
+	// 一直失败时停止转发返回 errConsumeStopped，不提交 offset
+	w = &fakeWriter{fails: -1}
+	f = newForwarder(w)
+	time.AfterFunc(time.Millisecond*100, f.Stop)
+	if err := f.ConsumeMessage(context.Background(), newMsg()); !errors.Is(err, errConsumeStopped) {
+		t.Fatalf("got %v, want errConsumeStopped", err)
+	}
+	if w.written() != 0 {
+		t.Fatalf("written %d messages, want 0", w.written())
+	}
+}
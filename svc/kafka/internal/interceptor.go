@@ -0,0 +1,221 @@
+package internal
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	// ContentEncodingHeader 消息 value 的压缩算法，由 CompressInterceptor 设置，Reader 获取消息后自动解压
+	ContentEncodingHeader = "x-content-encoding"
+	// CodecHeader 负载编解码器名称，非 JSON 负载不做 schema 校验和脱敏
+	CodecHeader = "x-codec"
+
+	contentEncodingGzip = "gzip"
+	// redactedValue 脱敏后的值
+	redactedValue = "***"
+)
+
+var (
+	// ErrMessageTooLarge 消息超过大小限制
+	ErrMessageTooLarge = errors.New("kafka.writer message too large")
+	// ErrSchemaValidation 消息不符合 JSON schema
+	ErrSchemaValidation = errors.New("kafka.writer schema validation failed")
+)
+
+// ProduceInterceptor 生产拦截器，写入前按配置顺序调用，可修改消息，返回错误时拒绝发送。
+// 拦截器在注入 trace headers 之后、打印日志之前执行，脱敏后的消息不会出现在日志中
+type ProduceInterceptor func(ctx context.Context, msg *kafka.Message) error
+
+// SizeLimitInterceptor 拒绝超过 limit 字节的消息（按 kafka-go 计算方式估算，包含 key、value 和 headers）。
+// Writer 总是在所有拦截器之后按 BatchBytes 检查一次，避免异步模式下超限消息只能在回调中发现
+func SizeLimitInterceptor(limit int64) ProduceInterceptor {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		if size := messageSize(msg); size > limit {
+			return fmt.Errorf("%w: size %d exceeds limit %d", ErrMessageTooLarge, size, limit)
+		}
+		return nil
+	}
+}
+
+// JSONSchemaInterceptor 按 JSON schema 校验消息负载（信封中的 data 字段，非信封消息校验整个 value），
+// 支持 type、properties、required、additionalProperties、items、enum、minimum、maximum、minLength、maxLength、minItems、maxItems、pattern，
+// 其他校验关键字（如 $ref、oneOf、format）返回错误
+func JSONSchemaInterceptor(schema []byte) (ProduceInterceptor, error) {
+	s, err := compileJSONSchema(schema)
+	if err != nil {
+		return nil, err
+	}
+	return func(ctx context.Context, msg *kafka.Message) error {
+		if !isJSONPayload(msg) {
+			return nil
+		}
+		var value any
+		if err := unmarshalJSON(msg.Value, &value); err != nil {
+			return fmt.Errorf("%w: %v", ErrSchemaValidation, err)
+		}
+		if err := s.validate(payloadOf(value), "$"); err != nil {
+			return fmt.Errorf("%w: %v", ErrSchemaValidation, err)
+		}
+		return nil
+	}, nil
+}
+
+// RedactInterceptor 把负载中指定字段的值替换为 ***，字段路径相对信封中的 data，使用 . 分隔（如 user.phone），
+// 路径经过数组时对每个元素生效；value 不是 JSON 时不做处理
+func RedactInterceptor(fields ...string) ProduceInterceptor {
+	paths := make([][]string, 0, len(fields))
+	for _, field := range fields {
+		paths = append(paths, strings.Split(field, "."))
+	}
+	return func(ctx context.Context, msg *kafka.Message) error {
+		if len(paths) == 0 || !isJSONPayload(msg) {
+			return nil
+		}
+		var value any
+		if err := unmarshalJSON(msg.Value, &value); err != nil {
+			return nil
+		}
+
+		redacted := false
+		payload := payloadOf(value)
+		for _, path := range paths {
+			redacted = redactPath(payload, path) || redacted
+		}
+		if !redacted {
+			return nil
+		}
+
+		b, err := json.Marshal(value)
+		if err != nil {
+			return fmt.Errorf("kafka.writer redact Marshal error: %w", err)
+		}
+		msg.Value = b
+		return nil
+	}
+}
+
+// CompressInterceptor 使用 gzip 压缩超过 threshold 字节的 value 并设置 ContentEncodingHeader，
+// 应放在其他拦截器之后；消费端 Reader 自动解压，其他语言的消费者需自行按 header 解压
+func CompressInterceptor(threshold int) ProduceInterceptor {
+	return func(ctx context.Context, msg *kafka.Message) error {
+		m := NewMessage(msg)
+		if len(msg.Value) <= threshold || len(m.GetHeader(ContentEncodingHeader)) > 0 {
+			return nil
+		}
+
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		if _, err := zw.Write(msg.Value); err != nil {
+			return fmt.Errorf("kafka.writer compress Write error: %w", err)
+		}
+		if err := zw.Close(); err != nil {
+			return fmt.Errorf("kafka.writer compress Close error: %w", err)
+		}
+		if buf.Len() >= len(msg.Value) {
+			return nil
+		}
+
+		msg.Value = buf.Bytes()
+		m.SetHeader(ContentEncodingHeader, contentEncodingGzip)
+		return nil
+	}
+}
+
+// decompressMessage 按 ContentEncodingHeader 解压 value 并移除该 header，解压后超过 limit 字节时返回错误，避免压缩炸弹耗尽内存
+func decompressMessage(msg *kafka.Message, limit int64) error {
+	m := NewMessage(msg)
+	encoding := m.GetHeader(ContentEncodingHeader)
+	if len(encoding) == 0 {
+		return nil
+	}
+	if encoding != contentEncodingGzip {
+		return fmt.Errorf("kafka.reader unknown content encoding: %s", encoding)
+	}
+
+	zr, err := gzip.NewReader(bytes.NewReader(msg.Value))
+	if err != nil {
+		return fmt.Errorf("kafka.reader decompress NewReader error: %w", err)
+	}
+	defer zr.Close()
+	value, err := io.ReadAll(io.LimitReader(zr, limit+1))
+	if err != nil {
+		return fmt.Errorf("kafka.reader decompress ReadAll error: %w", err)
+	}
+	if int64(len(value)) > limit {
+		return fmt.Errorf("%w: decompressed size exceeds limit %d", ErrMessageTooLarge, limit)
+	}
+
+	msg.Value = value
+	m.DelHeader(ContentEncodingHeader)
+	return nil
+}
+
+// messageSize 按 kafka-go 的方式估算消息大小，varint 长度按最大值计算
+func messageSize(msg *kafka.Message) int64 {
+	// crc + magic + attributes + timestamp + key/value 长度
+	size := int64(4+1+1+8+4+4) + int64(len(msg.Key)) + int64(len(msg.Value))
+	size += 5
+	for _, h := range msg.Headers {
+		size += int64(5+len(h.Key)) + int64(5+len(h.Value))
+	}
+	return size
+}
+
+// isJSONPayload 未压缩且未使用非 JSON 编解码器的消息
+func isJSONPayload(msg *kafka.Message) bool {
+	m := NewMessage(msg)
+	if len(m.GetHeader(ContentEncodingHeader)) > 0 {
+		return false
+	}
+	codec := m.GetHeader(CodecHeader)
+	return len(codec) == 0 || codec == "json"
+}
+
+// payloadOf 返回信封中的 data，非信封消息返回自身
+func payloadOf(value any) any {
+	if envelope, ok := value.(map[string]any); ok {
+		if data, ok := envelope["data"]; ok {
+			return data
+		}
+	}
+	return value
+}
+
+// redactPath 脱敏路径上的字段，返回是否有字段被修改
+func redactPath(value any, path []string) bool {
+	switch v := value.(type) {
+	case map[string]any:
+		child, ok := v[path[0]]
+		if !ok {
+			return false
+		}
+		if len(path) == 1 {
+			v[path[0]] = redactedValue
+			return true
+		}
+		return redactPath(child, path[1:])
+	case []any:
+		redacted := false
+		for _, item := range v {
+			redacted = redactPath(item, path) || redacted
+		}
+		return redacted
+	}
+	return false
+}
+
+// unmarshalJSON 解析 JSON，数字保留为 json.Number 避免精度丢失
+func unmarshalJSON(data []byte, v any) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
@@ -0,0 +1,102 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestJSONSchemaInterceptor(t *testing.T) {
+	intercept, err := JSONSchemaInterceptor([]byte(`{
+		"type": "object",
+		"required": ["id", "status"],
+		"properties": {
+			"id": {"type": "integer", "minimum": 1},
+			"status": {"enum": ["paid", "refund"]},
+			"items": {"type": "array", "minItems": 1, "items": {"type": "string", "maxLength": 3}}
+		}
+	}`))
+	if err != nil {
+		t.Fatalf("compile error: %v", err)
+	}
+
+	tests := []struct {
+		value string
+		ok    bool
+	}{
+		{`{"from":"x","data":{"id":1,"status":"paid","items":["a"]}}`, true},
+		{`{"from":"x","data":{"id":0,"status":"paid"}}`, false},
+		{`{"from":"x","data":{"id":1.5,"status":"paid"}}`, false},
+		{`{"from":"x","data":{"id":1,"status":"new"}}`, false},
+		{`{"from":"x","data":{"id":1}}`, false},
+		{`{"from":"x","data":{"id":1,"status":"paid","items":["abcd"]}}`, false},
+		{`{"id":2,"status":"refund"}`, true},
+	}
+	for _, tt := range tests {
+		msg := kafka.Message{Value: []byte(tt.value)}
+		err := intercept(context.Background(), &msg)
+		if tt.ok != (err == nil) {
+			t.Fatalf("%s got %v, want ok %v", tt.value, err, tt.ok)
+		}
+		if err != nil && !errors.Is(err, ErrSchemaValidation) {
+			t.Fatalf("%s got %v, want ErrSchemaValidation", tt.value, err)
+		}
+	}
+
+	// 不支持的关键字在编译时拒绝，避免校验被静默跳过
+	for _, schema := range []string{
+		`{"oneOf": [{"type": "string"}]}`,
+		`{"type": "object", "properties": {"id": {"$ref": "#/definitions/id"}}}`,
+		`{"type": "array", "items": {"type": "string", "format": "email"}}`,
+	} {
+		if _, err := JSONSchemaInterceptor([]byte(schema)); err == nil {
+			t.Fatalf("%s compiled, want unsupported keyword error", schema)
+		}
+	}
+}
+
+func TestRedactInterceptor(t *testing.T) {
+	msg := kafka.Message{Value: []byte(`{"from":"x","data":{"id":12345678901234567,"user":{"phone":"138"},"items":[{"card":"1"},{"card":"2"}]}}`)}
+	if err := RedactInterceptor("user.phone", "items.card", "missing.field")(context.Background(), &msg); err != nil {
+		t.Fatalf("redact error: %v", err)
+	}
+	want := `{"data":{"id":12345678901234567,"items":[{"card":"***"},{"card":"***"}],"user":{"phone":"***"}},"from":"x"}`
+	if string(msg.Value) != want {
+		t.Fatalf("got %s, want %s", msg.Value, want)
+	}
+}
+
+func TestCompressInterceptor(t *testing.T) {
+	value := strings.Repeat(`{"data":"compressible"}`, 100)
+	msg := kafka.Message{Value: []byte(value)}
+	if err := CompressInterceptor(1024)(context.Background(), &msg); err != nil {
+		t.Fatalf("compress error: %v", err)
+	}
+	if NewMessage(&msg).GetHeader(ContentEncodingHeader) != contentEncodingGzip || len(msg.Value) >= len(value) {
+		t.Fatalf("value not compressed, size:%d", len(msg.Value))
+	}
+
+	// 解压后超过限制时拒绝，保留原始消息
+	if err := decompressMessage(&msg, int64(len(value)-1)); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("decompress over limit got %v, want ErrMessageTooLarge", err)
+	}
+	if err := decompressMessage(&msg, int64(len(value))); err != nil {
+		t.Fatalf("decompress error: %v", err)
+	}
+	if string(msg.Value) != value || len(NewMessage(&msg).GetHeader(ContentEncodingHeader)) > 0 {
+		t.Fatalf("decompressed value mismatch")
+	}
+}
+
+func TestSizeLimitInterceptor(t *testing.T) {
+	msg := kafka.Message{Key: []byte("k"), Value: make([]byte, 100)}
+	if err := SizeLimitInterceptor(1024)(context.Background(), &msg); err != nil {
+		t.Fatalf("got %v, want nil", err)
+	}
+	if err := SizeLimitInterceptor(100)(context.Background(), &msg); !errors.Is(err, ErrMessageTooLarge) {
+		t.Fatalf("got %v, want ErrMessageTooLarge", err)
+	}
+}
@@ -0,0 +1,243 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"regexp"
+	"slices"
+	"unicode/utf8"
+)
+
+// jsonSchemaKeywords 支持的校验关键字，以及不影响校验的注解关键字
+var jsonSchemaKeywords = map[string]bool{
+	"type": true, "properties": true, "required": true, "additionalProperties": true, "items": true, "enum": true,
+	"minimum": true, "maximum": true, "minLength": true, "maxLength": true, "minItems": true, "maxItems": true, "pattern": true,
+	"$schema": true, "$id": true, "$comment": true, "title": true, "description": true, "default": true, "examples": true,
+}
+
+// jsonSchema JSON schema 的常用子集，用于生产前校验消息负载
+type jsonSchema struct {
+	Type                 any                    `json:"type"`
+	Properties           map[string]*jsonSchema `json:"properties"`
+	Required             []string               `json:"required"`
+	AdditionalProperties *bool                  `json:"additionalProperties"`
+	Items                *jsonSchema            `json:"items"`
+	Enum                 []any                  `json:"enum"`
+	Minimum              *float64               `json:"minimum"`
+	Maximum              *float64               `json:"maximum"`
+	MinLength            *int                   `json:"minLength"`
+	MaxLength            *int                   `json:"maxLength"`
+	MinItems             *int                   `json:"minItems"`
+	MaxItems             *int                   `json:"maxItems"`
+	Pattern              string                 `json:"pattern"`
+
+	types   []string
+	pattern *regexp.Regexp
+}
+
+// compileJSONSchema 解析 schema 并预编译 type 和 pattern，包含不支持的关键字（如 $ref、oneOf、format）时返回错误，
+// 避免校验被静默跳过
+func compileJSONSchema(data []byte) (*jsonSchema, error) {
+	var raw any
+	if err := unmarshalJSON(data, &raw); err != nil {
+		return nil, fmt.Errorf("kafka.JSONSchema Unmarshal error: %w", err)
+	}
+	if err := checkJSONSchemaKeywords(raw, "$"); err != nil {
+		return nil, err
+	}
+
+	var s jsonSchema
+	if err := unmarshalJSON(data, &s); err != nil {
+		return nil, fmt.Errorf("kafka.JSONSchema Unmarshal error: %w", err)
+	}
+	if err := s.compile("$"); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// checkJSONSchemaKeywords 递归检查 schema 中的关键字是否都受支持
+func checkJSONSchemaKeywords(raw any, path string) error {
+	schema, ok := raw.(map[string]any)
+	if !ok {
+		return fmt.Errorf("kafka.JSONSchema %s must be an object", path)
+	}
+	for keyword, value := range schema {
+		if !jsonSchemaKeywords[keyword] {
+			return fmt.Errorf("kafka.JSONSchema %s unsupported keyword: %s", path, keyword)
+		}
+		switch keyword {
+		case "properties":
+			properties, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("kafka.JSONSchema %s properties must be an object", path)
+			}
+			for name, property := range properties {
+				if err := checkJSONSchemaKeywords(property, path+"."+name); err != nil {
+					return err
+				}
+			}
+		case "items":
+			if err := checkJSONSchemaKeywords(value, path+"[]"); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (s *jsonSchema) compile(path string) error {
+	switch t := s.Type.(type) {
+	case nil:
+	case string:
+		s.types = []string{t}
+	case []any:
+		for _, v := range t {
+			name, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("kafka.JSONSchema %s invalid type: %v", path, s.Type)
+			}
+			s.types = append(s.types, name)
+		}
+	default:
+		return fmt.Errorf("kafka.JSONSchema %s invalid type: %v", path, s.Type)
+	}
+
+	if len(s.Pattern) > 0 {
+		pattern, err := regexp.Compile(s.Pattern)
+		if err != nil {
+			return fmt.Errorf("kafka.JSONSchema %s invalid pattern: %w", path, err)
+		}
+		s.pattern = pattern
+	}
+
+	for name, property := range s.Properties {
+		if err := property.compile(path + "." + name); err != nil {
+			return err
+		}
+	}
+	if s.Items != nil {
+		return s.Items.compile(path + "[]")
+	}
+	return nil
+}
+
+// validate 校验 value（由 unmarshalJSON 解析），path 用于错误信息
+func (s *jsonSchema) validate(value any, path string) error {
+	if len(s.types) > 0 && !slices.ContainsFunc(s.types, func(t string) bool { return matchJSONType(t, value) }) {
+		return fmt.Errorf("%s type want %v, got %s", path, s.types, jsonTypeOf(value))
+	}
+	if len(s.Enum) > 0 && !slices.ContainsFunc(s.Enum, func(e any) bool { return jsonEqual(e, value) }) {
+		return fmt.Errorf("%s value %v not in enum %v", path, value, s.Enum)
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		for _, name := range s.Required {
+			if _, ok := v[name]; !ok {
+				return fmt.Errorf("%s.%s is required", path, name)
+			}
+		}
+		for name, child := range v {
+			property, ok := s.Properties[name]
+			if !ok {
+				if s.AdditionalProperties != nil && !*s.AdditionalProperties {
+					return fmt.Errorf("%s.%s is not allowed", path, name)
+				}
+				continue
+			}
+			if err := property.validate(child, path+"."+name); err != nil {
+				return err
+			}
+		}
+	case []any:
+		if s.MinItems != nil && len(v) < *s.MinItems {
+			return fmt.Errorf("%s items %d less than %d", path, len(v), *s.MinItems)
+		}
+		if s.MaxItems != nil && len(v) > *s.MaxItems {
+			return fmt.Errorf("%s items %d greater than %d", path, len(v), *s.MaxItems)
+		}
+		if s.Items != nil {
+			for i, item := range v {
+				if err := s.Items.validate(item, fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+		}
+	case string:
+		n := utf8.RuneCountInString(v)
+		if s.MinLength != nil && n < *s.MinLength {
+			return fmt.Errorf("%s length %d less than %d", path, n, *s.MinLength)
+		}
+		if s.MaxLength != nil && n > *s.MaxLength {
+			return fmt.Errorf("%s length %d greater than %d", path, n, *s.MaxLength)
+		}
+		if s.pattern != nil && !s.pattern.MatchString(v) {
+			return fmt.Errorf("%s value %q does not match pattern %s", path, v, s.Pattern)
+		}
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return fmt.Errorf("%s invalid number %s", path, v)
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			return fmt.Errorf("%s value %s less than %v", path, v, *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			return fmt.Errorf("%s value %s greater than %v", path, v, *s.Maximum)
+		}
+	}
+	return nil
+}
+
+// matchJSONType 判断 value 是否为 JSON schema 类型 t
+func matchJSONType(t string, value any) bool {
+	switch t {
+	case "integer":
+		n, ok := value.(json.Number)
+		if !ok {
+			return false
+		}
+		f, err := n.Float64()
+		return err == nil && f == math.Trunc(f)
+	case "number":
+		_, ok := value.(json.Number)
+		return ok
+	}
+	return jsonTypeOf(value) == t
+}
+
+// jsonTypeOf 返回 value 的 JSON 类型名称
+func jsonTypeOf(value any) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case []any:
+		return "array"
+	case map[string]any:
+		return "object"
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// jsonEqual 比较 enum 值，数字按数值比较
+func jsonEqual(a, b any) bool {
+	if na, ok := a.(json.Number); ok {
+		nb, ok := b.(json.Number)
+		if !ok {
+			return false
+		}
+		fa, errA := na.Float64()
+		fb, errB := nb.Float64()
+		return errA == nil && errB == nil && fa == fb
+	}
+	return reflect.DeepEqual(a, b)
+}
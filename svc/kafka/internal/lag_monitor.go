@@ -0,0 +1,332 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/logx"
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+const (
+	// defaultLagInterval 默认采集积压的间隔
+	defaultLagInterval = 30 * time.Second
+	// defaultLagAlarmInterval 默认同一消费组两次报警的最小间隔
+	defaultLagAlarmInterval = 10 * time.Minute
+	// lagRequestTimeout 单次采集的超时时间
+	lagRequestTimeout = 10 * time.Second
+)
+
+// 消费积压指标，go-zero 仅在开启 Prometheus 时才会真正上报
+var (
+	metricLag = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "consumer",
+		Name:      "lag",
+		Help:      "kafka consumer group lag per partition.",
+		Labels:    []string{"topic", "group", "partition"},
+	})
+	metricLagTotal = metric.NewGaugeVec(&metric.GaugeVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "consumer",
+		Name:      "lag_total",
+		Help:      "kafka consumer group total lag.",
+		Labels:    []string{"topic", "group"},
+	})
+)
+
+type (
+	// AlarmSender 报警发送接口，*alarm.Alarm 实现了该接口
+	AlarmSender interface {
+		Send(data any) error
+	}
+
+	// LagAlert 积压报警信息
+	LagAlert struct {
+		Topic      string
+		Group      string
+		Lag        int64         // 总积压
+		Growth     int64         // 相比上次采集的积压增长
+		Interval   time.Duration // 采集间隔
+		Partitions map[int]int64 // 各分区积压
+		Reason     string        // 触发原因
+	}
+
+	LagMonitorOptionFunc func(config *LagMonitorConf)
+
+	// LagMonitorConf 积压监控配置
+	LagMonitorConf struct {
+		Interval      time.Duration      // 采集间隔，默认 30 秒
+		MaxLag        int64              // 总积压超过该值时报警，0 不检查
+		MaxLagGrowth  int64              // 单次采集间隔内积压增长超过该值时报警，0 不检查
+		AlarmInterval time.Duration      // 同一消费组两次报警的最小间隔，默认 10 分钟
+		Alarm         AlarmSender        // 报警发送器，nil 时只上报指标和日志
+		AlarmMessage  func(LagAlert) any // 构造报警消息，格式取决于报警发送器（如 alarm.LarkMessage），默认为文本描述
+	}
+
+	// lagClient 查询分区和 offset 的接口，由 kafka.Client 实现
+	lagClient interface {
+		Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error)
+		ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error)
+		OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error)
+		DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error)
+	}
+
+	// lagMonitor 定期采集消费组各分区积压（高水位 - 已提交 offset）。
+	// 每个实例都上报指标，只有分配到 0 号分区的实例报警，避免同一消费组的每个实例重复报警
+	lagMonitor struct {
+		topic     string
+		group     string
+		clientID  string // 本实例在消费组中的 ClientID，为空时总是报警
+		client    lagClient
+		config    LagMonitorConf
+		logger    logx.Logger
+		mu        sync.Mutex
+		lastLag   int64
+		hasLast   bool
+		lastAlarm time.Time
+		reported  map[int]struct{} // 已上报的分区，分区减少时清零
+	}
+)
+
+// newLagMonitor 创建积压监控，transport 为 nil 时使用默认 Transport
+func newLagMonitor(brokers []string, topic, group, clientID string, transport kafka.RoundTripper, config LagMonitorConf) *lagMonitor {
+	if config.Interval <= 0 {
+		config.Interval = defaultLagInterval
+	}
+	if config.AlarmInterval <= 0 {
+		config.AlarmInterval = defaultLagAlarmInterval
+	}
+	if config.AlarmMessage == nil {
+		config.AlarmMessage = defaultLagAlarmMessage
+	}
+	return &lagMonitor{
+		topic:    topic,
+		group:    group,
+		clientID: clientID,
+		client: &kafka.Client{
+			Addr:      kafka.TCP(brokers...),
+			Timeout:   lagRequestTimeout,
+			Transport: transport,
+		},
+		config:   config,
+		logger:   logx.WithCallerSkip(1).WithFields(logx.Field("component", "kafka.lag"), logx.Field("topic", topic), logx.Field("group", group)),
+		reported: make(map[int]struct{}),
+	}
+}
+
+// start 按间隔采集，done 关闭时退出
+func (m *lagMonitor) start(done <-chan struct{}) {
+	ticker := time.NewTicker(m.config.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			m.check(context.Background())
+		}
+	}
+}
+
+// check 采集一次积压，上报指标并按阈值报警
+func (m *lagMonitor) check(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, lagRequestTimeout)
+	defer cancel()
+
+	lags, err := m.collect(ctx)
+	if err != nil {
+		m.logger.Errorf("collect lag failed, error:%v", err)
+		return
+	}
+
+	var total int64
+	for partition, lag := range lags {
+		total += lag
+		metricLag.Set(float64(lag), m.topic, m.group, strconv.Itoa(partition))
+		m.reported[partition] = struct{}{}
+	}
+	for partition := range m.reported {
+		if _, ok := lags[partition]; !ok {
+			metricLag.Set(0, m.topic, m.group, strconv.Itoa(partition))
+			delete(m.reported, partition)
+		}
+	}
+	metricLagTotal.Set(float64(total), m.topic, m.group)
+
+	m.mu.Lock()
+	growth := total - m.lastLag
+	hasLast := m.hasLast
+	m.lastLag, m.hasLast = total, true
+	m.mu.Unlock()
+
+	var reason string
+	switch {
+	case m.config.MaxLag > 0 && total > m.config.MaxLag:
+		reason = fmt.Sprintf("lag %d exceeds %d", total, m.config.MaxLag)
+	case m.config.MaxLagGrowth > 0 && hasLast && growth > m.config.MaxLagGrowth:
+		reason = fmt.Sprintf("lag grew %d in %s, exceeds %d", growth, m.config.Interval, m.config.MaxLagGrowth)
+	}
+	if len(reason) == 0 {
+		return
+	}
+
+	m.logger.Errorf("consumer lag alert, %s, lag:%d, partitions:%v", reason, total, lags)
+	if !m.isReporter(ctx) {
+		return
+	}
+	m.alarm(LagAlert{
+		Topic:      m.topic,
+		Group:      m.group,
+		Lag:        total,
+		Growth:     growth,
+		Interval:   m.config.Interval,
+		Partitions: lags,
+		Reason:     reason,
+	})
+}
+
+// isReporter 本实例是否分配到 0 号分区，负责报警；查询失败时按报警处理，宁可重复报警也不漏报
+func (m *lagMonitor) isReporter(ctx context.Context) bool {
+	if len(m.clientID) == 0 {
+		return true
+	}
+
+	resp, err := m.client.DescribeGroups(ctx, &kafka.DescribeGroupsRequest{GroupIDs: []string{m.group}})
+	if err != nil {
+		m.logger.Errorf("describe group failed, alarm anyway, error:%v", err)
+		return true
+	}
+	for _, group := range resp.Groups {
+		if group.Error != nil {
+			m.logger.Errorf("describe group failed, alarm anyway, error:%v", group.Error)
+			return true
+		}
+		for _, member := range group.Members {
+			if member.ClientID != m.clientID {
+				continue
+			}
+			for _, topic := range member.MemberAssignments.Topics {
+				if topic.Topic == m.topic && slices.Contains(topic.Partitions, 0) {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// collect 查询各分区积压，尚未提交 offset 的分区按 高水位 - 最早 offset 计算
+func (m *lagMonitor) collect(ctx context.Context) (map[int]int64, error) {
+	meta, err := m.client.Metadata(ctx, &kafka.MetadataRequest{Topics: []string{m.topic}})
+	if err != nil {
+		return nil, fmt.Errorf("kafka.lagMonitor Metadata error: %w", err)
+	}
+	var partitions []int
+	for _, topic := range meta.Topics {
+		if topic.Name != m.topic {
+			continue
+		}
+		if topic.Error != nil {
+			return nil, fmt.Errorf("kafka.lagMonitor Metadata topic error: %w", topic.Error)
+		}
+		for _, p := range topic.Partitions {
+			partitions = append(partitions, p.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		return nil, fmt.Errorf("kafka.lagMonitor topic %s has no partitions", m.topic)
+	}
+
+	requests := make([]kafka.OffsetRequest, 0, len(partitions))
+	for _, partition := range partitions {
+		requests = append(requests, kafka.LastOffsetOf(partition))
+	}
+	offsets, err := m.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{m.topic: requests},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kafka.lagMonitor ListOffsets error: %w", err)
+	}
+	highWatermarks := make(map[int]int64, len(partitions))
+	for _, p := range offsets.Topics[m.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("kafka.lagMonitor ListOffsets partition %d error: %w", p.Partition, p.Error)
+		}
+		highWatermarks[p.Partition] = p.LastOffset
+	}
+
+	committed, err := m.client.OffsetFetch(ctx, &kafka.OffsetFetchRequest{
+		GroupID: m.group,
+		Topics:  map[string][]int{m.topic: partitions},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kafka.lagMonitor OffsetFetch error: %w", err)
+	}
+	if committed.Error != nil {
+		return nil, fmt.Errorf("kafka.lagMonitor OffsetFetch group error: %w", committed.Error)
+	}
+
+	lags := make(map[int]int64, len(partitions))
+	var uncommitted []kafka.OffsetRequest
+	for _, p := range committed.Topics[m.topic] {
+		hw, ok := highWatermarks[p.Partition]
+		if p.Error != nil || !ok {
+			continue
+		}
+		if p.CommittedOffset < 0 {
+			uncommitted = append(uncommitted, kafka.FirstOffsetOf(p.Partition))
+			continue
+		}
+		lags[p.Partition] = max(hw-p.CommittedOffset, 0)
+	}
+	if len(uncommitted) == 0 {
+		return lags, nil
+	}
+
+	// 消费组尚未提交过 offset（新消费组或一直未消费成功），积压为分区内全部保留的消息
+	firsts, err := m.client.ListOffsets(ctx, &kafka.ListOffsetsRequest{
+		Topics: map[string][]kafka.OffsetRequest{m.topic: uncommitted},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("kafka.lagMonitor ListOffsets first offset error: %w", err)
+	}
+	for _, p := range firsts.Topics[m.topic] {
+		if p.Error != nil {
+			return nil, fmt.Errorf("kafka.lagMonitor ListOffsets partition %d error: %w", p.Partition, p.Error)
+		}
+		lags[p.Partition] = max(highWatermarks[p.Partition]-p.FirstOffset, 0)
+	}
+	return lags, nil
+}
+
+// alarm 发送报警，同一消费组在 AlarmInterval 内只发送一次
+func (m *lagMonitor) alarm(alert LagAlert) {
+	if m.config.Alarm == nil {
+		return
+	}
+
+	m.mu.Lock()
+	if !m.lastAlarm.IsZero() && time.Since(m.lastAlarm) < m.config.AlarmInterval {
+		m.mu.Unlock()
+		return
+	}
+	m.lastAlarm = time.Now()
+	m.mu.Unlock()
+
+	if err := m.config.Alarm.Send(m.config.AlarmMessage(alert)); err != nil {
+		m.logger.Errorf("send lag alarm failed, error:%v", err)
+	}
+}
+
+// defaultLagAlarmMessage 默认积压报警消息
+func defaultLagAlarmMessage(alert LagAlert) any {
+	return fmt.Sprintf("kafka consumer lag alert, topic:%s, group:%s, %s, lag:%d, partitions:%v",
+		alert.Topic, alert.Group, alert.Reason, alert.Lag, alert.Partitions)
+}
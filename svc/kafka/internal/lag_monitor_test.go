@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeLagClient 返回预置的最早 offset、高水位、已提交 offset 和消费组成员分配
+type fakeLagClient struct {
+	firstOffsets   map[int]int64
+	highWatermarks map[int]int64
+	committed      map[int]int64
+	assignments    map[string][]int // ClientID → 分配的分区
+}
+
+func (c *fakeLagClient) Metadata(ctx context.Context, req *kafka.MetadataRequest) (*kafka.MetadataResponse, error) {
+	topic := kafka.Topic{Name: req.Topics[0]}
+	for partition := range c.highWatermarks {
+		topic.Partitions = append(topic.Partitions, kafka.Partition{Topic: topic.Name, ID: partition})
+	}
+	return &kafka.MetadataResponse{Topics: []kafka.Topic{topic}}, nil
+}
+
+func (c *fakeLagClient) ListOffsets(ctx context.Context, req *kafka.ListOffsetsRequest) (*kafka.ListOffsetsResponse, error) {
+	resp := &kafka.ListOffsetsResponse{Topics: make(map[string][]kafka.PartitionOffsets)}
+	for topic, requests := range req.Topics {
+		for _, r := range requests {
+			offsets := kafka.PartitionOffsets{Partition: r.Partition, FirstOffset: -1, LastOffset: -1}
+			if r.Timestamp == kafka.FirstOffset {
+				offsets.FirstOffset = c.firstOffsets[r.Partition]
+			} else {
+				offsets.LastOffset = c.highWatermarks[r.Partition]
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], offsets)
+		}
+	}
+	return resp, nil
+}
+
+func (c *fakeLagClient) OffsetFetch(ctx context.Context, req *kafka.OffsetFetchRequest) (*kafka.OffsetFetchResponse, error) {
+	resp := &kafka.OffsetFetchResponse{Topics: make(map[string][]kafka.OffsetFetchPartition)}
+	for topic, partitions := range req.Topics {
+		for _, partition := range partitions {
+			offset, ok := c.committed[partition]
+			if !ok {
+				offset = -1
+			}
+			resp.Topics[topic] = append(resp.Topics[topic], kafka.OffsetFetchPartition{Partition: partition, CommittedOffset: offset})
+		}
+	}
+	return resp, nil
+}
+
+func (c *fakeLagClient) DescribeGroups(ctx context.Context, req *kafka.DescribeGroupsRequest) (*kafka.DescribeGroupsResponse, error) {
+	group := kafka.DescribeGroupsResponseGroup{GroupID: req.GroupIDs[0]}
+	for clientID, partitions := range c.assignments {
+		group.Members = append(group.Members, kafka.DescribeGroupsResponseMember{
+			ClientID: clientID,
+			MemberAssignments: kafka.DescribeGroupsResponseAssignments{
+				Topics: []kafka.GroupMemberTopic{{Topic: "orders", Partitions: partitions}},
+			},
+		})
+	}
+	return &kafka.DescribeGroupsResponse{Groups: []kafka.DescribeGroupsResponseGroup{group}}, nil
+}
+
+type recordAlarm struct {
+	mu     sync.Mutex
+	alerts []LagAlert
+}
+
+func (a *recordAlarm) Send(data any) error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.alerts = append(a.alerts, data.(LagAlert))
+	return nil
+}
+
+func TestLagMonitor(t *testing.T) {
+	client := &fakeLagClient{
+		firstOffsets:   map[int]int64{2: 4},
+		highWatermarks: map[int]int64{0: 100, 1: 50, 2: 10},
+		committed:      map[int]int64{0: 90, 1: 50},
+	}
+	alarm := &recordAlarm{}
+	m := newLagMonitor([]string{"127.0.0.1:1"}, "orders", "orders:svc", "", nil, LagMonitorConf{
+		MaxLag:       100,
+		MaxLagGrowth: 50,
+		Alarm:        alarm,
+		AlarmMessage: func(alert LagAlert) any { return alert },
+	})
+	m.client = client
+
+	// 未提交 offset 的分区按 高水位 - 最早 offset 计算
+	lags, err := m.collect(context.Background())
+	if err != nil {
+		t.Fatalf("collect error: %v", err)
+	}
+	if len(lags) != 3 || lags[0] != 10 || lags[1] != 0 || lags[2] != 6 {
+		t.Fatalf("got %v, want map[0:10 1:0 2:6]", lags)
+	}
+
+	m.check(context.Background())
+	if len(alarm.alerts) != 0 {
+		t.Fatalf("unexpected alerts: %+v", alarm.alerts)
+	}
+
+	// 积压增长超过阈值时报警
+	client.highWatermarks[0] = 170
+	m.check(context.Background())
+	if len(alarm.alerts) != 1 || alarm.alerts[0].Growth != 70 {
+		t.Fatalf("got alerts %+v, want one growth alert", alarm.alerts)
+	}
+
+	// 报警间隔内不重复报警
+	client.highWatermarks[0] = 300
+	m.check(context.Background())
+	if len(alarm.alerts) != 1 {
+		t.Fatalf("alarm not throttled: %+v", alarm.alerts)
+	}
+	m.lastAlarm = time.Now().Add(-time.Hour)
+	m.check(context.Background())
+	if len(alarm.alerts) != 2 || alarm.alerts[1].Lag != 216 {
+		t.Fatalf("got alerts %+v, want lag alert 216", alarm.alerts)
+	}
+}
+
+func TestLagMonitorReporter(t *testing.T) {
+	client := &fakeLagClient{
+		highWatermarks: map[int]int64{0: 100, 1: 100},
+		committed:      map[int]int64{0: 0, 1: 0},
+		assignments:    map[string][]int{"a": {0}, "b": {1}},
+	}
+	alarms := make(map[string]*recordAlarm)
+	for _, clientID := range []string{"a", "b"} {
+		alarms[clientID] = &recordAlarm{}
+		m := newLagMonitor([]string{"127.0.0.1:1"}, "orders", "orders:svc", clientID, nil, LagMonitorConf{
+			MaxLag: 100,
+			Alarm:  alarms[clientID],
+			AlarmMessage: func(alert LagAlert) any {
+				return alert
+			},
+		})
+		m.client = client
+		m.check(context.Background())
+	}
+
+	// 只有分配到 0 号分区的实例报警
+	if len(alarms["a"].alerts) != 1 || len(alarms["b"].alerts) != 0 {
+		t.Fatalf("alerts got a:%d, b:%d, want a:1, b:0", len(alarms["a"].alerts), len(alarms["b"].alerts))
+	}
+
+	// 未配置 AlarmMessage 时发送默认文本
+	m := newLagMonitor([]string{"127.0.0.1:1"}, "orders", "orders:svc", "", nil, LagMonitorConf{Alarm: &recordAlarm{}})
+	if _, ok := m.config.AlarmMessage(LagAlert{Topic: "orders"}).(string); !ok {
+		t.Fatalf("default alarm message is not text")
+	}
+}
@@ -0,0 +1,35 @@
+package internal
+
+import (
+	"github.com/zeromicro/go-zero/core/metric"
+)
+
+const metricNamespace = "kafka"
+
+// Prometheus 指标，go-zero 仅在开启 Prometheus 时才会真正上报
+var (
+	metricBatchSize = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "consumer",
+		Name:      "batch_size",
+		Help:      "kafka consumer batch size.",
+		Labels:    []string{"topic", "group"},
+		Buckets:   []float64{1, 10, 50, 100, 200, 500, 1000, 5000},
+	})
+	metricBatchDuration = metric.NewHistogramVec(&metric.HistogramVecOpts{
+		Namespace: metricNamespace,
+		Subsystem: "consumer",
+		Name:      "batch_duration_ms",
+		Help:      "kafka consumer batch handler duration(ms).",
+		Labels:    []string{"topic", "group", "result"},
+		Buckets:   []float64{5, 10, 25, 50, 100, 250, 500, 1000, 2500, 5000, 10000},
+	})
+)
+
+// handlerResult 返回处理结果标签
+func handlerResult(err error) string {
+	if err != nil {
+		return "failure"
+	}
+	return "success"
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"runtime/debug"
+	"slices"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/stat"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 内置消费中间件名称，默认顺序由外到内为 tracing、logging、metrics、recover，自定义中间件默认位于其后。
+// 中间件只作用于逐条消费，批量消费（BatchConsumeHandler）不经过中间件链。
+const (
+	// MiddlewareTracing 为每条消息创建 consume span，延续上游 trace
+	MiddlewareTracing = "tracing"
+	// MiddlewareLogging 记录收到消息和处理失败日志
+	MiddlewareLogging = "logging"
+	// MiddlewareMetrics 记录流转耗时、业务处理耗时 metrics 和慢日志
+	MiddlewareMetrics = "metrics"
+	// MiddlewareRecover 捕获 panic 并转换为错误
+	MiddlewareRecover = "recover"
+)
+
+type (
+	// ConsumeFunc 单条消息处理函数
+	ConsumeFunc func(ctx context.Context, msg kafka.Message) error
+
+	// ConsumeMiddleware 消费中间件，返回包装 next 的处理函数，可修改 ctx、拦截消息或处理错误
+	ConsumeMiddleware func(next ConsumeFunc) ConsumeFunc
+
+	// NamedConsumeMiddleware 具名中间件，名称用于调整顺序和禁用
+	NamedConsumeMiddleware struct {
+		Name       string
+		Middleware ConsumeMiddleware
+	}
+)
+
+// ConsumeMessage 实现 MessageHandler
+func (f ConsumeFunc) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	return f(ctx, msg)
+}
+
+// buildConsumeChain 按配置组装中间件链，最内层调用 r.handler（运行时读取，以便包装处理器）
+func (r *Reader) buildConsumeChain(config ReaderConf) ConsumeFunc {
+	middlewares := append([]NamedConsumeMiddleware{
+		{Name: MiddlewareTracing, Middleware: r.tracingMiddleware},
+		{Name: MiddlewareLogging, Middleware: r.loggingMiddleware},
+		{Name: MiddlewareMetrics, Middleware: r.metricsMiddleware},
+		{Name: MiddlewareRecover, Middleware: r.recoverMiddleware},
+	}, config.Middlewares...)
+	names := make(map[string]struct{}, len(middlewares))
+	for _, m := range middlewares {
+		if _, ok := names[m.Name]; ok || len(m.Name) == 0 || m.Middleware == nil {
+			log.Fatalf("kafka.reader %s invalid consume middleware: %q", r.group, m.Name)
+		}
+		names[m.Name] = struct{}{}
+	}
+
+	chain := middlewares
+	if len(config.MiddlewareOrder) > 0 {
+		chain = make([]NamedConsumeMiddleware, 0, len(config.MiddlewareOrder))
+		for _, name := range config.MiddlewareOrder {
+			i := slices.IndexFunc(middlewares, func(m NamedConsumeMiddleware) bool { return m.Name == name })
+			if i < 0 {
+				log.Fatalf("kafka.reader %s unknown consume middleware: %s", r.group, name)
+			}
+			chain = append(chain, middlewares[i])
+		}
+	}
+	chain = slices.DeleteFunc(slices.Clone(chain), func(m NamedConsumeMiddleware) bool {
+		return slices.Contains(config.DisabledMiddlewares, m.Name)
+	})
+
+	next := ConsumeFunc(func(ctx context.Context, msg kafka.Message) error {
+		return r.handler.ConsumeMessage(ctx, msg)
+	})
+	for i := len(chain) - 1; i >= 0; i-- {
+		next = chain[i].Middleware(next)
+	}
+	return next
+}
+
+// tracingMiddleware 创建 consume span
+func (r *Reader) tracingMiddleware(next ConsumeFunc) ConsumeFunc {
+	return func(ctx context.Context, msg kafka.Message) error {
+		ctx, span := kafkaTracer.Start(ctx, "consume",
+			trace.WithSpanKind(trace.SpanKindConsumer),
+		)
+		defer span.End()
+
+		return next(ctx, msg)
+	}
+}
+
+// loggingMiddleware 记录收到消息和处理失败日志
+func (r *Reader) loggingMiddleware(next ConsumeFunc) ConsumeFunc {
+	return func(ctx context.Context, msg kafka.Message) error {
+		r.logger.Infof(ctx, "received message partition:%d, offset:%d, key:%s, value:%s", msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
+
+		err := next(ctx, msg)
+		if err != nil && !errors.Is(err, errConsumeStopped) {
+			r.logger.Errorf(ctx, "consume failed, partition:%d, offset:%d, key:%s, value:%s, error:%v", msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err)
+		}
+		return err
+	}
+}
+
+// metricsMiddleware 记录流转耗时和业务处理耗时，失败（含 panic）记为 Drop
+func (r *Reader) metricsMiddleware(next ConsumeFunc) ConsumeFunc {
+	return func(ctx context.Context, msg kafka.Message) (err error) {
+		now := time.Now()
+		r.recordTransit(ctx, msg, now)
+
+		defer func() {
+			r.bizMetrics.Add(stat.Task{
+				Duration: time.Since(now),
+				Drop:     err != nil,
+			})
+		}()
+		return next(ctx, msg)
+	}
+}
+
+// recoverMiddleware 捕获 panic 并转换为错误，交给重试或死信处理
+func (r *Reader) recoverMiddleware(next ConsumeFunc) ConsumeFunc {
+	return func(ctx context.Context, msg kafka.Message) (err error) {
+		defer func() {
+			if p := recover(); p != nil {
+				err = fmt.Errorf("%+v", p)
+				r.logger.Errorf(ctx, "consume panic partition:%d, offset:%d, key:%s, message:%s, panic:%+v \nstack:%s",
+					msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), p, string(debug.Stack()))
+			}
+		}()
+		return next(ctx, msg)
+	}
+}
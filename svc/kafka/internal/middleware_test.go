@@ -0,0 +1,79 @@
+package internal
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestConsumeChain(t *testing.T) {
+	var calls []string
+	record := func(name string) NamedConsumeMiddleware {
+		return NamedConsumeMiddleware{Name: name, Middleware: func(next ConsumeFunc) ConsumeFunc {
+			return func(ctx context.Context, msg kafka.Message) error {
+				calls = append(calls, name)
+				return next(ctx, msg)
+			}
+		}}
+	}
+	handler := ConsumeFunc(func(context.Context, kafka.Message) error {
+		calls = append(calls, "handler")
+		return nil
+	})
+
+	tests := []struct {
+		name   string
+		config ReaderConf
+		want   []string
+	}{
+		{
+			name:   "default order",
+			config: ReaderConf{Middlewares: []NamedConsumeMiddleware{record("auth"), record("timeout")}},
+			want:   []string{"auth", "timeout", "handler"},
+		},
+		{
+			name: "custom order",
+			config: ReaderConf{
+				Middlewares:     []NamedConsumeMiddleware{record("auth"), record("timeout")},
+				MiddlewareOrder: []string{"timeout", MiddlewareRecover, "auth"},
+			},
+			want: []string{"timeout", "auth", "handler"},
+		},
+		{
+			name: "disabled",
+			config: ReaderConf{
+				Middlewares:         []NamedConsumeMiddleware{record("auth"), record("timeout")},
+				DisabledMiddlewares: []string{"auth"},
+			},
+			want: []string{"timeout", "handler"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			calls = nil
+			r := newReader([]string{"127.0.0.1:9092"}, "orders", "orders:svc", handler, tt.config)
+			defer r.reader.Close()
+			if err := r.consume(context.Background(), kafka.Message{Topic: "orders"}); err != nil {
+				t.Fatalf("consume error: %v", err)
+			}
+			if !slices.Equal(calls, tt.want) {
+				t.Fatalf("got %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumeChainRecover(t *testing.T) {
+	handler := ConsumeFunc(func(context.Context, kafka.Message) error {
+		panic("boom")
+	})
+	r := newReader([]string{"127.0.0.1:9092"}, "orders", "orders:svc", handler, ReaderConf{})
+	defer r.reader.Close()
+
+	err := r.consume(context.Background(), kafka.Message{Topic: "orders"})
+	if err == nil || err.Error() != "boom" {
+		t.Fatalf("got %v, want panic converted to error", err)
+	}
+}
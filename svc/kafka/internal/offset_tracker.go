@@ -0,0 +1,75 @@
+package internal
+
+import (
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type (
+	// offsetTracker 按分区记录已分发消息的完成情况，只有分区内按分发顺序连续完成的消息才可提交，
+	// 避免并行处理时较大的 offset 先提交而跳过仍在处理中的消息
+	offsetTracker struct {
+		mu         sync.Mutex
+		partitions map[partitionKey][]*trackedMessage
+	}
+
+	partitionKey struct {
+		topic     string
+		partition int
+	}
+
+	trackedMessage struct {
+		msg  kafka.Message
+		done bool
+	}
+)
+
+func newOffsetTracker() *offsetTracker {
+	return &offsetTracker{
+		partitions: make(map[partitionKey][]*trackedMessage),
+	}
+}
+
+// track 记录已分发的消息，需按拉取顺序调用
+func (t *offsetTracker) track(msg kafka.Message) {
+	key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.partitions[key] = append(t.partitions[key], &trackedMessage{msg: msg})
+}
+
+// complete 标记消息处理完成，返回分区内连续完成的最后一条消息；
+// 队头仍在处理中时返回 false，由队头完成时一并提交
+func (t *offsetTracker) complete(msg kafka.Message) (kafka.Message, bool) {
+	key := partitionKey{topic: msg.Topic, partition: msg.Partition}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pending := t.partitions[key]
+	// 重平衡后可能重复拉取同一 offset，标记第一条未完成的即可
+	for _, tracked := range pending {
+		if tracked.msg.Offset == msg.Offset && !tracked.done {
+			tracked.done = true
+			break
+		}
+	}
+
+	var n int
+	for n < len(pending) && pending[n].done {
+		n++
+	}
+	if n == 0 {
+		return kafka.Message{}, false
+	}
+
+	last := pending[n-1].msg
+	if n == len(pending) {
+		delete(t.partitions, key)
+	} else {
+		t.partitions[key] = pending[n:]
+	}
+	return last, true
+}
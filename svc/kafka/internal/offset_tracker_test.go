@@ -0,0 +1,39 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestOffsetTracker(t *testing.T) {
+	tracker := newOffsetTracker()
+	msgs := []kafka.Message{
+		{Partition: 0, Offset: 10},
+		{Partition: 0, Offset: 11},
+		{Partition: 1, Offset: 5},
+		{Partition: 0, Offset: 13},
+	}
+	for _, msg := range msgs {
+		tracker.track(msg)
+	}
+
+	// 分区 0 队头未完成，不能提交后面的 offset
+	if _, ok := tracker.complete(msgs[1]); ok {
+		t.Fatalf("offset 11 committed before 10")
+	}
+	if _, ok := tracker.complete(msgs[3]); ok {
+		t.Fatalf("offset 13 committed before 10")
+	}
+	// 其他分区不受影响
+	if last, ok := tracker.complete(msgs[2]); !ok || last.Offset != 5 {
+		t.Fatalf("partition 1 got %v %v, want offset 5", last.Offset, ok)
+	}
+	// 队头完成后一次提交到连续完成的最大 offset
+	if last, ok := tracker.complete(msgs[0]); !ok || last.Offset != 13 {
+		t.Fatalf("partition 0 got %v %v, want offset 13", last.Offset, ok)
+	}
+	if len(tracker.partitions) != 0 {
+		t.Fatalf("tracker not drained: %v", tracker.partitions)
+	}
+}
@@ -0,0 +1,460 @@
+package internal
+
+// Outbox 事务消息：业务事务内把消息写入 outbox 表，与业务数据同时提交或回滚；
+// OutboxRelay 轮询待发送的行，发送到 Kafka 后标记为已发送，保证消息不丢（至少一次）。
+//
+// 同一 msg_key（聚合 key）的消息按 id 顺序发送：每轮只认领各 key 最早一条未发送的消息，
+// 发送失败的消息按退避时间重试并阻塞同 key 的后续消息；发送次数用尽后标记为死信（status=2）并报警，
+// 死信不再发送，也不再阻塞同 key 的后续消息，需人工处理后把 status 改回 0 重新发送。
+// 认领在短事务内使用 `SELECT ... FOR UPDATE SKIP LOCKED` 选出消息并写入租约（owner、locked_until），
+// 发送在事务外进行，发送后再按 owner 标记为已发送或失败；租约到期未标记的消息（如进程退出）由其他实例重新认领发送。
+// 多实例可同时运行，需要 MySQL 8.0+。
+//
+// 表结构（也可调用 MigrateOutbox 自动创建）：
+//
+//	CREATE TABLE `kafka_outbox` (
+//	  `id` bigint unsigned NOT NULL AUTO_INCREMENT,
+//	  `topic` varchar(255) NOT NULL,
+//	  `msg_key` varchar(255) NOT NULL DEFAULT '',
+//	  `headers` text NOT NULL,
+//	  `value` mediumtext NOT NULL,
+//	  `status` tinyint NOT NULL DEFAULT 0,
+//	  `attempts` int NOT NULL DEFAULT 0,
+//	  `last_error` varchar(1024) NOT NULL DEFAULT '',
+//	  `next_at` datetime(3) NOT NULL,
+//	  `owner` varchar(64) NOT NULL DEFAULT '',
+//	  `locked_until` datetime(3) NULL,
+//	  `sent_at` datetime(3) NULL,
+//	  `created_at` datetime(3) NOT NULL,
+//	  PRIMARY KEY (`id`),
+//	  KEY `idx_status_next` (`status`, `next_at`),
+//	  KEY `idx_key_status` (`msg_key`, `status`, `id`),
+//	  KEY `idx_sent_at` (`sent_at`)
+//	) ENGINE=InnoDB DEFAULT CHARSET=utf8mb4;
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/logx"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// DefaultOutboxTable 默认 outbox 表名
+	DefaultOutboxTable = "kafka_outbox"
+
+	outboxStatusPending = 0
+	outboxStatusSent    = 1
+	outboxStatusDead    = 2
+
+	// defaultOutboxBatchSize 默认每轮认领的消息数
+	defaultOutboxBatchSize = 100
+	// defaultOutboxMaxAttempts 默认最大发送次数，按退避时间约 1 小时
+	defaultOutboxMaxAttempts = 20
+	// defaultOutboxLeaseTimeout 默认认领租约时长，发送超过该时长会被取消
+	defaultOutboxLeaseTimeout = time.Minute
+	// defaultOutboxPollInterval 默认空闲时的轮询间隔
+	defaultOutboxPollInterval = time.Second
+	// defaultOutboxRetention 默认已发送消息的保留时间
+	defaultOutboxRetention = 7 * 24 * time.Hour
+	// defaultOutboxCleanupInterval 默认清理已发送消息的间隔
+	defaultOutboxCleanupInterval = 10 * time.Minute
+	// outboxCleanupChunk 每次删除的最大行数，避免大事务
+	outboxCleanupChunk = 1000
+	// outboxRetryBackoff 发送失败后的首次退避时间，之后每次翻倍
+	outboxRetryBackoff = time.Second
+	// outboxMaxRetryBackoff 发送失败退避时间上限
+	outboxMaxRetryBackoff = 5 * time.Minute
+	// outboxMaxErrorLength last_error 最大长度
+	outboxMaxErrorLength = 1024
+)
+
+type (
+	// OutboxMessage outbox 表结构
+	OutboxMessage struct {
+		ID          uint64     `gorm:"column:id;primaryKey;autoIncrement"`
+		Topic       string     `gorm:"column:topic;type:varchar(255);not null"`
+		MsgKey      string     `gorm:"column:msg_key;type:varchar(255);not null;default:'';index:idx_key_status,priority:1"`
+		Headers     string     `gorm:"column:headers;type:text;not null"`
+		Value       string     `gorm:"column:value;type:mediumtext;not null"`
+		Status      int        `gorm:"column:status;type:tinyint;not null;default:0;index:idx_status_next,priority:1;index:idx_key_status,priority:2"`
+		Attempts    int        `gorm:"column:attempts;not null;default:0"`
+		LastError   string     `gorm:"column:last_error;type:varchar(1024);not null;default:''"`
+		NextAt      time.Time  `gorm:"column:next_at;not null;index:idx_status_next,priority:2"`
+		Owner       string     `gorm:"column:owner;type:varchar(64);not null;default:''"`
+		LockedUntil *time.Time `gorm:"column:locked_until"`
+		SentAt      *time.Time `gorm:"column:sent_at;index:idx_sent_at"`
+		CreatedAt   time.Time  `gorm:"column:created_at;not null"`
+	}
+
+	OutboxOptionFunc func(config *OutboxConf)
+
+	// OutboxConf outbox 转发配置
+	OutboxConf struct {
+		Table           string                   // 表名，默认 kafka_outbox
+		BatchSize       int                      // 每轮认领的消息数，默认 100
+		MaxAttempts     int                      // 最大发送次数，用尽后标记为死信，默认 20
+		LeaseTimeout    time.Duration            // 认领租约时长，到期未标记的消息由其他实例重新认领，默认 1 分钟
+		PollInterval    time.Duration            // 空闲时的轮询间隔，默认 1 秒
+		Retention       time.Duration            // 已发送消息的保留时间，默认 7 天
+		CleanupInterval time.Duration            // 清理已发送消息的间隔，默认 10 分钟
+		WriterOpts      []WriterOptionFunc       // 发送使用的 Writer 配置（强制同步写入）
+		Router          TopicRouter              // 按 topic 选择集群，nil 时写入 brokers
+		Alarm           AlarmSender              // 死信报警发送器，nil 时只记录日志
+		AlarmMessage    func(*OutboxMessage) any // 构造死信报警消息，默认为文本描述
+	}
+
+	// OutboxRelay 轮询 outbox 表并发送到 Kafka
+	OutboxRelay struct {
+		db       *gorm.DB
+		brokers  []string
+		config   OutboxConf
+		owner    string // 认领租约的持有者标识，每个转发器唯一
+		logger   logx.Logger
+		mu       sync.Mutex
+		writers  map[string]*Writer
+		done     chan struct{}
+		stopOnce sync.Once
+	}
+)
+
+// NewOutboxMessage 创建待发送的 outbox 行，ctx 中的 trace 上下文写入 headers，发送时延续同一条链路
+func NewOutboxMessage(ctx context.Context, topic, key, value string, headers map[string]string) (*OutboxMessage, error) {
+	carrier := propagation.MapCarrier{}
+	for k, v := range headers {
+		carrier[k] = v
+	}
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+
+	b, err := json.Marshal(carrier)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	return &OutboxMessage{
+		Topic:     topic,
+		MsgKey:    key,
+		Headers:   string(b),
+		Value:     value,
+		Status:    outboxStatusPending,
+		NextAt:    now,
+		CreatedAt: now,
+	}, nil
+}
+
+// MigrateOutbox 自动创建/更新 outbox 表
+func MigrateOutbox(ctx context.Context, db *gorm.DB, table string) error {
+	return db.WithContext(ctx).Table(table).AutoMigrate(&OutboxMessage{})
+}
+
+// NewOutboxRelay 创建 outbox 转发器
+func NewOutboxRelay(db *gorm.DB, brokers []string, opts ...OutboxOptionFunc) *OutboxRelay {
+	config := OutboxConf{
+		Table:           DefaultOutboxTable,
+		BatchSize:       defaultOutboxBatchSize,
+		MaxAttempts:     defaultOutboxMaxAttempts,
+		LeaseTimeout:    defaultOutboxLeaseTimeout,
+		PollInterval:    defaultOutboxPollInterval,
+		Retention:       defaultOutboxRetention,
+		CleanupInterval: defaultOutboxCleanupInterval,
+	}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	async := false
+	config.WriterOpts = append(config.WriterOpts, func(config *WriterConf) {
+		config.Async = &async
+	})
+
+	return &OutboxRelay{
+		db:      db,
+		brokers: brokers,
+		config:  config,
+		owner:   uuid.NewString(),
+		logger:  logx.WithCallerSkip(1).WithFields(logx.Field("component", "kafka.outbox"), logx.Field("table", config.Table)),
+		writers: make(map[string]*Writer),
+		done:    make(chan struct{}),
+	}
+}
+
+// Start 启动转发和清理，阻塞直到 Stop
+func (r *OutboxRelay) Start() {
+	poll := time.NewTicker(r.config.PollInterval)
+	defer poll.Stop()
+	cleanup := time.NewTicker(r.config.CleanupInterval)
+	defer cleanup.Stop()
+
+	for {
+		// 认领满批时说明仍有积压，立即继续
+		if n := r.RelayOnce(context.Background()); n >= r.config.BatchSize {
+			select {
+			case <-r.done:
+				r.closeWriters()
+				return
+			default:
+				continue
+			}
+		}
+
+		select {
+		case <-r.done:
+			r.closeWriters()
+			return
+		case <-cleanup.C:
+			r.Cleanup(context.Background())
+		case <-poll.C:
+		}
+	}
+}
+
+// Table 返回 outbox 表名
+func (r *OutboxRelay) Table() string {
+	return r.config.Table
+}
+
+// Stop 停止转发，正在进行的一轮完成后退出
+func (r *OutboxRelay) Stop() {
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+}
+
+// RelayOnce 认领一批待发送消息并发送，返回认领的条数。
+// 认领和标记各自是一条短语句或短事务，发送不占用数据库事务和行锁；发送成功但标记前进程退出时，租约到期后会重复发送（至少一次）
+func (r *OutboxRelay) RelayOnce(ctx context.Context) int {
+	rows, err := r.claim(ctx, time.Now())
+	if err != nil {
+		r.logger.WithContext(ctx).Errorf("outbox claim error: %v", err)
+		return 0
+	}
+	if len(rows) == 0 {
+		return 0
+	}
+
+	// 同一批内 key 互不相同，可并发发送，由 Writer 合并批次；发送不超过租约时长
+	publishCtx, cancel := context.WithTimeout(ctx, r.config.LeaseTimeout)
+	errs := make([]error, len(rows))
+	var wg sync.WaitGroup
+	for i, row := range rows {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = r.publish(publishCtx, row)
+		}()
+	}
+	wg.Wait()
+	cancel()
+
+	now := time.Now()
+	var sent []uint64
+	for i, row := range rows {
+		if errs[i] == nil {
+			sent = append(sent, row.ID)
+			continue
+		}
+		r.logger.WithContext(ctx).Errorf("outbox publish failed, id:%d, topic:%s, key:%s, attempts:%d, error:%v", row.ID, row.Topic, row.MsgKey, row.Attempts+1, errs[i])
+		dead, err := r.markFailed(ctx, row, errs[i], now)
+		if err != nil {
+			r.logger.WithContext(ctx).Errorf("outbox mark failed error, id:%d, error:%v", row.ID, err)
+			continue
+		}
+		if dead {
+			r.alarm(ctx, row)
+		}
+	}
+
+	if len(sent) > 0 {
+		if err := r.markSent(ctx, sent, now); err != nil {
+			r.logger.WithContext(ctx).Errorf("outbox mark sent error, ids:%v, error:%v", sent, err)
+		}
+	}
+
+	return len(rows)
+}
+
+// claim 在短事务内认领一批到期且未被租约锁定的消息，写入本转发器的租约。
+// 只认领各 key 最早一条未发送的消息，保证同 key 按 id 顺序发送；死信不再阻塞同 key 的后续消息
+func (r *OutboxRelay) claim(ctx context.Context, now time.Time) ([]*OutboxMessage, error) {
+	var rows []*OutboxMessage
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		err := tx.Table(r.config.Table).
+			Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? AND next_at <= ? AND (locked_until IS NULL OR locked_until <= ?)", outboxStatusPending, now, now).
+			Where(fmt.Sprintf("(msg_key = '' OR NOT EXISTS (SELECT 1 FROM `%[1]s` AS o WHERE o.msg_key = `%[1]s`.msg_key AND o.status = ? AND o.id < `%[1]s`.id))", r.config.Table), outboxStatusPending).
+			Order("id").
+			Limit(r.config.BatchSize).
+			Find(&rows).Error
+		if err != nil || len(rows) == 0 {
+			return err
+		}
+
+		ids := make([]uint64, 0, len(rows))
+		for _, row := range rows {
+			ids = append(ids, row.ID)
+		}
+		return tx.Table(r.config.Table).Where("id IN ?", ids).Updates(map[string]any{
+			"owner":        r.owner,
+			"locked_until": now.Add(r.config.LeaseTimeout),
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// markSent 把本转发器认领的消息标记为已发送，租约已被其他实例接管的行不更新
+func (r *OutboxRelay) markSent(ctx context.Context, ids []uint64, now time.Time) error {
+	return r.db.WithContext(ctx).Table(r.config.Table).
+		Where("id IN ? AND owner = ?", ids, r.owner).
+		Updates(map[string]any{
+			"status":       outboxStatusSent,
+			"sent_at":      now,
+			"owner":        "",
+			"locked_until": nil,
+		}).Error
+}
+
+// Cleanup 分批删除超过保留时间的已发送消息
+func (r *OutboxRelay) Cleanup(ctx context.Context) {
+	before := time.Now().Add(-r.config.Retention)
+	for {
+		ret := r.db.WithContext(ctx).Table(r.config.Table).
+			Where("status = ? AND sent_at < ?", outboxStatusSent, before).
+			Limit(outboxCleanupChunk).
+			Delete(&OutboxMessage{})
+		if ret.Error != nil {
+			r.logger.WithContext(ctx).Errorf("outbox cleanup error: %v", ret.Error)
+			return
+		}
+		if ret.RowsAffected < outboxCleanupChunk {
+			return
+		}
+	}
+}
+
+// publish 同步发送一行消息，延续写入时的 trace 上下文
+func (r *OutboxRelay) publish(ctx context.Context, row *OutboxMessage) error {
+	carrier := propagation.MapCarrier{}
+	if err := json.Unmarshal([]byte(row.Headers), &carrier); err != nil {
+		return fmt.Errorf("kafka.outbox headers Unmarshal error: %w", err)
+	}
+	ctx = otel.GetTextMapPropagator().Extract(ctx, carrier)
+
+	msg := kafka.Message{
+		Key:   []byte(row.MsgKey),
+		Value: []byte(row.Value),
+	}
+	if len(row.MsgKey) == 0 {
+		msg.Key = []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	m := NewMessage(&msg)
+	for k, v := range carrier {
+		m.SetHeader(k, v)
+	}
+
+	writer, err := r.writer(row.Topic)
+	if err != nil {
+		return fmt.Errorf("kafka.outbox writer error: %w", err)
+	}
+	return writer.WriteMessage(ctx, msg)
+}
+
+// markFailed 记录发送失败、释放租约并按指数退避推迟下次发送，发送次数用尽时标记为死信并返回 true；
+// 租约已被其他实例接管时不更新，返回 false
+func (r *OutboxRelay) markFailed(ctx context.Context, row *OutboxMessage, cause error, now time.Time) (bool, error) {
+	backoff := outboxRetryBackoff
+	for i := 0; i < row.Attempts && backoff < outboxMaxRetryBackoff; i++ {
+		backoff *= 2
+	}
+	lastError := cause.Error()
+	if runes := []rune(lastError); len(runes) > outboxMaxErrorLength {
+		lastError = string(runes[:outboxMaxErrorLength])
+	}
+
+	attempts := row.Attempts + 1
+	updates := map[string]any{
+		"attempts":     attempts,
+		"last_error":   lastError,
+		"next_at":      now.Add(min(backoff, outboxMaxRetryBackoff)),
+		"owner":        "",
+		"locked_until": nil,
+	}
+	dead := r.config.MaxAttempts > 0 && attempts >= r.config.MaxAttempts
+	if dead {
+		updates["status"] = outboxStatusDead
+	}
+
+	ret := r.db.WithContext(ctx).Table(r.config.Table).Where("id = ? AND owner = ?", row.ID, r.owner).Updates(updates)
+	if ret.Error != nil || ret.RowsAffected == 0 {
+		return false, ret.Error
+	}
+
+	row.Attempts = attempts
+	row.LastError = lastError
+	if dead {
+		row.Status = outboxStatusDead
+	}
+	return dead, nil
+}
+
+// alarm 记录并报警发送次数用尽的死信消息
+func (r *OutboxRelay) alarm(ctx context.Context, row *OutboxMessage) {
+	r.logger.WithContext(ctx).Errorf("outbox message dead, id:%d, topic:%s, key:%s, attempts:%d, error:%s", row.ID, row.Topic, row.MsgKey, row.Attempts, row.LastError)
+	if r.config.Alarm == nil {
+		return
+	}
+
+	message := r.config.AlarmMessage
+	if message == nil {
+		message = defaultOutboxAlarmMessage
+	}
+	if err := r.config.Alarm.Send(message(row)); err != nil {
+		r.logger.WithContext(ctx).Errorf("outbox alarm send error: %v", err)
+	}
+}
+
+// defaultOutboxAlarmMessage 默认死信报警消息
+func defaultOutboxAlarmMessage(row *OutboxMessage) any {
+	return fmt.Sprintf("kafka outbox message dead, id:%d, topic:%s, key:%s, attempts:%d, error:%s", row.ID, row.Topic, row.MsgKey, row.Attempts, row.LastError)
+}
+
+// writer 获取 topic 的同步 Writer，按 topic 缓存复用
+func (r *OutboxRelay) writer(topic string) (*Writer, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if writer, ok := r.writers[topic]; ok {
+		return writer, nil
+	}
+	writer, err := newRoutedWriter(r.brokers, topic, r.config.Router, r.config.WriterOpts)
+	if err != nil {
+		return nil, err
+	}
+	r.writers[topic] = writer
+	return writer, nil
+}
+
+// closeWriters 关闭所有 Writer
+func (r *OutboxRelay) closeWriters() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for topic, writer := range r.writers {
+		if err := writer.Close(); err != nil {
+			r.logger.Errorf("outbox writer close error, topic:%s, error:%v", topic, err)
+		}
+	}
+}
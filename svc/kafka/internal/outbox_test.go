@@ -0,0 +1,176 @@
+package internal
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"gorm.io/driver/mysql"
+	"gorm.io/gorm"
+)
+
+type recordSender struct {
+	data []any
+}
+
+func (s *recordSender) Send(data any) error {
+	s.data = append(s.data, data)
+	return nil
+}
+
+// newOutboxMock 创建使用 sqlmock 的 OutboxRelay，topic ok 写入成功，topic bad 一直写入失败
+func newOutboxMock(t *testing.T, opts ...OutboxOptionFunc) (*OutboxRelay, sqlmock.Sqlmock, map[string]*fakeWriter) {
+	sqlDB, mock, err := sqlmock.New(sqlmock.QueryMatcherOption(sqlmock.QueryMatcherRegexp))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = sqlDB.Close()
+	})
+	db, err := gorm.Open(mysql.New(mysql.Config{Conn: sqlDB, SkipInitializeWithVersion: true}), &gorm.Config{SkipDefaultTransaction: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewOutboxRelay(db, []string{"127.0.0.1:1"}, opts...)
+	fakes := map[string]*fakeWriter{"ok": {}, "bad": {fails: -1}}
+	for topic, fake := range fakes {
+		writer := NewWriter([]string{"127.0.0.1:1"}, topic)
+		writer.writer = fake
+		r.writers[topic] = writer
+	}
+	return r, mock, fakes
+}
+
+// outboxClaimQuery 认领语句：到期、租约未锁定，且同 key 没有更早的未发送消息（死信不阻塞）
+const outboxClaimQuery = "SELECT \\* FROM `kafka_outbox` WHERE \\(status = \\? AND next_at <= \\? AND \\(locked_until IS NULL OR locked_until <= \\?\\)\\) " +
+	"AND \\(\\(msg_key = '' OR NOT EXISTS \\(SELECT 1 FROM `kafka_outbox` AS o WHERE o.msg_key = `kafka_outbox`.msg_key AND o.status = \\? AND o.id < `kafka_outbox`.id\\)\\)\\) " +
+	"ORDER BY id LIMIT \\? FOR UPDATE SKIP LOCKED"
+
+var outboxColumns = []string{"id", "topic", "msg_key", "headers", "value", "status", "attempts"}
+
+func TestOutboxRelayOnce(t *testing.T) {
+	sender := &recordSender{}
+	r, mock, fakes := newOutboxMock(t, func(config *OutboxConf) {
+		config.MaxAttempts = 2
+		config.Alarm = sender
+	})
+
+	// 第一轮：认领 k1、k2 各自最早的一条，k1 发送成功，k2 用尽发送次数成为死信
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaimQuery).
+		WithArgs(outboxStatusPending, sqlmock.AnyArg(), sqlmock.AnyArg(), outboxStatusPending, defaultOutboxBatchSize).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).
+			AddRow(1, "ok", "k1", "{}", "v1", outboxStatusPending, 0).
+			AddRow(2, "bad", "k2", "{}", "v2", outboxStatusPending, 1))
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `locked_until`=\\?,`owner`=\\? WHERE id IN \\(\\?,\\?\\)").
+		WithArgs(sqlmock.AnyArg(), r.owner, 1, 2).
+		WillReturnResult(sqlmock.NewResult(0, 2))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `attempts`=\\?,`last_error`=\\?,`locked_until`=\\?,`next_at`=\\?,`owner`=\\?,`status`=\\? WHERE id = \\? AND owner = \\?").
+		WithArgs(2, "broker unavailable", nil, sqlmock.AnyArg(), "", outboxStatusDead, 2, r.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `locked_until`=\\?,`owner`=\\?,`sent_at`=\\?,`status`=\\? WHERE id IN \\(\\?\\) AND owner = \\?").
+		WithArgs(nil, "", sqlmock.AnyArg(), outboxStatusSent, 1, r.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if n := r.RelayOnce(t.Context()); n != 2 {
+		t.Fatalf("RelayOnce claimed %d, want 2", n)
+	}
+	if fakes["ok"].written() != 1 || fakes["bad"].written() != 0 {
+		t.Fatalf("written ok:%d, bad:%d", fakes["ok"].written(), fakes["bad"].written())
+	}
+	if len(sender.data) != 1 {
+		t.Fatalf("alarm got %v, want one dead message", sender.data)
+	}
+
+	// 第二轮：死信不再阻塞，k2 的后续消息被认领发送
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaimQuery).
+		WithArgs(outboxStatusPending, sqlmock.AnyArg(), sqlmock.AnyArg(), outboxStatusPending, defaultOutboxBatchSize).
+		WillReturnRows(sqlmock.NewRows(outboxColumns).AddRow(3, "ok", "k2", "{}", "v3", outboxStatusPending, 0))
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `locked_until`=\\?,`owner`=\\? WHERE id IN \\(\\?\\)").
+		WithArgs(sqlmock.AnyArg(), r.owner, 3).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `locked_until`=\\?,`owner`=\\?,`sent_at`=\\?,`status`=\\? WHERE id IN \\(\\?\\) AND owner = \\?").
+		WithArgs(nil, "", sqlmock.AnyArg(), outboxStatusSent, 3, r.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	if n := r.RelayOnce(t.Context()); n != 1 {
+		t.Fatalf("RelayOnce claimed %d, want 1", n)
+	}
+	if fakes["ok"].written() != 2 {
+		t.Fatalf("written ok:%d, want 2", fakes["ok"].written())
+	}
+
+	// 没有可认领的消息时不发送
+	mock.ExpectBegin()
+	mock.ExpectQuery(outboxClaimQuery).WillReturnRows(sqlmock.NewRows(outboxColumns))
+	mock.ExpectCommit()
+	if n := r.RelayOnce(t.Context()); n != 0 {
+		t.Fatalf("RelayOnce claimed %d, want 0", n)
+	}
+
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestOutboxMarkFailed(t *testing.T) {
+	sender := &recordSender{}
+	r, mock, _ := newOutboxMock(t, func(config *OutboxConf) {
+		config.MaxAttempts = 2
+		config.Alarm = sender
+	})
+	now := time.Now()
+
+	// 未用尽发送次数时释放租约并推迟下次发送
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `attempts`=\\?,`last_error`=\\?,`locked_until`=\\?,`next_at`=\\?,`owner`=\\? WHERE id = \\? AND owner = \\?").
+		WithArgs(1, "broker unavailable", nil, now.Add(outboxRetryBackoff), "", 7, r.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	row := &OutboxMessage{ID: 7, Topic: "orders"}
+	if dead, err := r.markFailed(t.Context(), row, errors.New("broker unavailable"), now); dead || err != nil || row.Attempts != 1 {
+		t.Fatalf("markFailed got dead:%v, error:%v, attempts:%d, want pending", dead, err, row.Attempts)
+	}
+
+	// 租约已被其他实例接管时不更新
+	mock.ExpectExec("UPDATE `kafka_outbox` SET .* WHERE id = \\? AND owner = \\?").
+		WillReturnResult(sqlmock.NewResult(0, 0))
+	if dead, err := r.markFailed(t.Context(), row, errors.New("broker unavailable"), now); dead || err != nil || row.Attempts != 1 {
+		t.Fatalf("markFailed got dead:%v, error:%v, attempts:%d, want unchanged", dead, err, row.Attempts)
+	}
+
+	// 用尽发送次数时标记为死信
+	mock.ExpectExec("UPDATE `kafka_outbox` SET `attempts`=\\?,`last_error`=\\?,`locked_until`=\\?,`next_at`=\\?,`owner`=\\?,`status`=\\? WHERE id = \\? AND owner = \\?").
+		WithArgs(2, "broker unavailable", nil, now.Add(outboxRetryBackoff*2), "", outboxStatusDead, 7, r.owner).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	if dead, err := r.markFailed(t.Context(), row, errors.New("broker unavailable"), now); !dead || err != nil {
+		t.Fatalf("markFailed got dead:%v, error:%v, want dead", dead, err)
+	}
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+
+	r.alarm(t.Context(), row)
+	if len(sender.data) != 1 || sender.data[0] != defaultOutboxAlarmMessage(row) {
+		t.Fatalf("alarm got %v, want default message", sender.data)
+	}
+}
+
+func TestOutboxCleanup(t *testing.T) {
+	r, mock, _ := newOutboxMock(t)
+
+	// 满一批时继续删除，不足一批时结束
+	query := "DELETE FROM `kafka_outbox` WHERE status = \\? AND sent_at < \\? LIMIT \\?"
+	mock.ExpectExec(query).WithArgs(outboxStatusSent, sqlmock.AnyArg(), outboxCleanupChunk).
+		WillReturnResult(sqlmock.NewResult(0, outboxCleanupChunk))
+	mock.ExpectExec(query).WithArgs(outboxStatusSent, sqlmock.AnyArg(), outboxCleanupChunk).
+		WillReturnResult(sqlmock.NewResult(0, 3))
+
+	r.Cleanup(t.Context())
+	if err := mock.ExpectationsWereMet(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -48,6 +48,16 @@ func (m *Message) SetHeader(key, val string) {
 	})
 }
 
+// DelHeader removes all headers with the passed key.
+func (m *Message) DelHeader(key string) {
+	for i := 0; i < len(m.Headers); i++ {
+		if m.Headers[i].Key == key {
+			m.Headers = append(m.Headers[:i], m.Headers[i+1:]...)
+			i--
+		}
+	}
+}
+
 var _ propagation.TextMapCarrier = (*MessageCarrier)(nil)
 
 // MessageCarrier injects and extracts traces from a Message.
@@ -2,21 +2,17 @@ package internal
 
 import (
 	"context"
-	"crypto/tls"
-	"crypto/x509"
 	"errors"
-	"fmt"
+	"hash/fnv"
 	"io"
 	"log"
-	"os"
-	"runtime/debug"
+	"sync"
 	"time"
 
+	"github.com/google/uuid"
 	"github.com/segmentio/kafka-go"
-	"github.com/segmentio/kafka-go/sasl/plain"
 	"github.com/zeromicro/go-zero/core/stat"
 	"github.com/zeromicro/go-zero/core/threading"
-	"go.opentelemetry.io/otel/trace"
 )
 
 const (
@@ -61,9 +57,7 @@ type (
 		Consumers  int // 消费协程数量，默认 8
 
 		// 认证配置
-		Username string // SASL 用户名
-		Password string // SASL 密码
-		CaFile   string // TLS CA 证书文件路径
+		Auth KafkaConf // SASL/TLS 认证配置，Brokers 不使用
 
 		// 基础配置
 		StartOffset int64 // 起始 offset，FirstOffset(-2) 或 LastOffset(-1)，默认 LastOffset(-1)
@@ -91,6 +85,34 @@ type (
 		// 读取配置
 		ReadBackoffMin time.Duration // 读取退避最小时间（kafka-go 默认 100ms）
 		ReadBackoffMax time.Duration // 读取退避最大时间（kafka-go 默认 1 秒）
+
+		// 有序消费配置，开启后只使用一个获取协程以保证分发顺序
+		Ordered      bool // 有序消费：同一分区固定由一个消费协程串行处理，默认 false（所有消费协程共享队列，可乱序）
+		OrderedByKey bool // 有序消费时按消息 key 哈希分发：同一 key 串行、同一分区不同 key 可并行，按分区连续提交 offset
+
+		// 重试配置
+		RetryAttempts   int           // 消费失败后的最大重试次数，用尽后写入死信 topic，默认 0（不重试，仅记录日志）
+		RetryBackoff    time.Duration // 首次重试的退避时间，之后每次翻倍，默认 1 秒
+		RetryMaxBackoff time.Duration // 重试退避时间上限，默认 10 分钟
+
+		// 批量消费配置（处理器实现 BatchConsumeHandler 时生效）
+		ConsumeBatchSize    int           // 每批最大消息数，默认 100
+		ConsumeBatchTimeout time.Duration // 批次从首条消息起的最长等待时间，默认 100 毫秒
+
+		// 去重配置，nil 时不去重（批量消费不生效）
+		Dedup *DedupConf
+
+		// 积压监控配置，nil 时不监控（重试 topic 的 Reader 不监控）
+		LagMonitor *LagMonitorConf
+
+		// 中间件配置（批量消费不生效）
+		Middlewares         []NamedConsumeMiddleware // 自定义中间件，默认按添加顺序位于内置中间件之后（由外到内）
+		MiddlewareOrder     []string                 // 中间件顺序（由外到内），包含内置和自定义中间件名称，未列出的不启用；为空时使用默认顺序
+		DisabledMiddlewares []string                 // 禁用的中间件名称
+
+		// 按分区拉取配置，仅供延迟分级 topic 转发和重试 topic 内部使用
+		partitionFetch bool     // 每个分区独立拉取并串行处理，一个分区等待不阻塞其他分区
+		topics         []string // 按分区拉取时消费的 topic，为空时只消费 Reader 的 topic
 	}
 
 	// ConsumeHandler 消费消息的处理器接口
@@ -98,30 +120,108 @@ type (
 		Consume(ctx context.Context, key, value string) error
 	}
 
+	// MessageHandler 以完整 kafka.Message 消费的处理器接口，可读取 headers、partition、offset 等元信息
+	MessageHandler interface {
+		ConsumeMessage(ctx context.Context, msg kafka.Message) error
+	}
+
+	// consumeAdapter 把 ConsumeHandler 适配为 MessageHandler
+	consumeAdapter struct {
+		handler ConsumeHandler
+	}
+
 	Reader struct {
 		topic            string
 		group            string
-		handler          ConsumeHandler
-		reader           kafkaReader
+		handler          MessageHandler
+		consume          ConsumeFunc   // 中间件链，最内层调用 handler
+		reader           kafkaReader   // 按分区拉取时为 nil
+		cg               consumerGroup // 按分区拉取时的消费组，其余模式为 nil
+		openPartition    func(topic string, partition int, offset int64) (kafkaReader, error)
 		transitMetrics   *stat.Metrics // 流转耗时
 		bizMetrics       *stat.Metrics // 业务处理耗时
 		logger           *eventLogger
-		channel          chan kafka.Message
+		channels         []chan kafka.Message // 共享分发时只有一个，有序消费时每个消费协程一个
 		fetcherRoutines  *threading.RoutineGroup
 		consumerRoutines *threading.RoutineGroup
-		processors       int // 处理协程数量
-		consumers        int // 消费协程数量
+		processors       int                 // 处理协程数量
+		consumers        int                 // 消费协程数量
+		retry            *retryPolicy        // 重试策略，未开启重试时为 nil
+		retryReader      *Reader             // 重试 topic 的 Reader，未开启重试时为 nil
+		orderedByKey     bool                // 按 key 哈希分发
+		tracker          *offsetTracker      // 按 key 分发时跟踪分区内连续完成的 offset，其余模式为 nil
+		batchHandler     BatchConsumeHandler // 批量处理器，非批量消费时为 nil
+		batchSize        int                 // 每批最大消息数
+		batchTimeout     time.Duration       // 批次最长等待时间
+		maxBytes         int                 // 单条消息解压后的最大字节数，与拉取的 MaxBytes 一致
+		lagMonitor       *lagMonitor         // 积压监控，未开启时为 nil
+		gate             pauseGate           // 暂停开关
+		workersMu        sync.Mutex          // 保护 workers
+		workers          []chan struct{}     // 共享分发时每个消费协程的退出信号，启动前为 nil
+		done             chan struct{}
+		stopOnce         sync.Once
 	}
 )
 
+// errConsumeStopped Reader 停止时中断等待中的消息，该消息不提交、不重试，重启后重新消费
+var errConsumeStopped = errors.New("kafka.reader stopped")
+
+// ConsumeMessage 实现 MessageHandler
+func (a consumeAdapter) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	return a.handler.Consume(ctx, string(msg.Key), string(msg.Value))
+}
+
 // NewReader 创建 Reader 实例
+// 处理器同时实现 MessageHandler 时以完整消息消费
 func NewReader(brokers []string, topic, group string, handler ConsumeHandler, opts ...ReaderOptionFunc) *Reader {
+	if messageHandler, ok := handler.(MessageHandler); ok {
+		return NewMessageReader(brokers, topic, group, messageHandler, opts...)
+	}
+	return NewMessageReader(brokers, topic, group, consumeAdapter{handler: handler}, opts...)
+}
+
+// NewMessageReader 创建以完整 kafka.Message 消费的 Reader 实例
+func NewMessageReader(brokers []string, topic, group string, handler MessageHandler, opts ...ReaderOptionFunc) *Reader {
 
 	var config ReaderConf
 	for _, opt := range opts {
 		opt(&config)
 	}
 
+	// 批量处理器按分区攒批，同一分区固定由一个消费协程处理；重试消息逐条以单条批次交给批量处理器。
+	// 批次按分区提交最大 offset，与按 key 分发的连续提交不兼容；去重按单条消息占用，无法用于批次
+	batchHandler, isBatch := asBatchHandler(handler)
+	if isBatch {
+		if config.OrderedByKey {
+			log.Fatalf("kafka.reader %s batch handler does not support OrderedByKey", group)
+		}
+		if config.Dedup != nil {
+			log.Fatalf("kafka.reader %s batch handler does not support Dedup", group)
+		}
+		config.Ordered = true
+		handler = batchMessageHandler{handler: batchHandler}
+	}
+
+	r := newReader(brokers, topic, group, handler, config)
+	r.batchHandler = batchHandler
+
+	// 开启去重时，主 topic 与重试 topic 共用本消费组的去重 key
+	if config.Dedup != nil {
+		handler = newDedupHandler(handler, group, *config.Dedup, r.done)
+		r.handler = handler
+	}
+
+	// 开启重试时，额外消费本消费组的重试 topic
+	if config.RetryAttempts > 0 {
+		r.retry = newRetryPolicy(brokers, topic, group, config)
+		r.retryReader = newRetryReader(brokers, r.retry, handler, config)
+	}
+
+	return r
+}
+
+// newReader 根据配置创建 Reader 实例
+func newReader(brokers []string, topic, group string, handler MessageHandler, config ReaderConf) *Reader {
 	// 构建 kafka.ReaderConfig
 	readerConfig := kafka.ReaderConfig{
 		Brokers:               brokers,
@@ -199,38 +299,37 @@ func NewReader(brokers []string, topic, group string, handler ConsumeHandler, op
 		readerConfig.ReadBackoffMax = config.ReadBackoffMax
 	}
 
-	// 处理 SASL 认证
-	if len(config.Username) > 0 && len(config.Password) > 0 {
-		readerConfig.Dialer = &kafka.Dialer{
-			SASLMechanism: plain.Mechanism{
-				Username: config.Username,
-				Password: config.Password,
-			},
-		}
+	dialer, err := NewDialer(config.Auth)
+	if err != nil {
+		log.Fatalf("kafka.reader %s auth config error: %v", group, err)
 	}
-
-	// 处理 TLS 配置
-	if len(config.CaFile) > 0 {
-		caCert, err := os.ReadFile(config.CaFile)
-		if err != nil {
-			log.Fatalf("kafka.reader %s failed to read CA file: %v", group, err)
+	// 积压监控按 ClientID 在消费组成员中找到本实例，只由分配到 0 号分区的实例报警
+	var clientID string
+	if config.LagMonitor != nil {
+		if dialer == nil {
+			dialer = &kafka.Dialer{Timeout: defaultDialTimeout, DualStack: true}
 		}
-		caCertPool := x509.NewCertPool()
-		ok := caCertPool.AppendCertsFromPEM(caCert)
-		if !ok {
-			log.Fatalf("kafka.reader %s failed to parse CA certificate: %v", group, err)
-		}
-		if readerConfig.Dialer == nil {
-			readerConfig.Dialer = &kafka.Dialer{}
-		}
-		readerConfig.Dialer.TLS = &tls.Config{
-			RootCAs:            caCertPool,
-			InsecureSkipVerify: true,
+		clientID = "kafka-go-" + uuid.NewString()
+		dialer.ClientID = clientID
+	}
+	readerConfig.Dialer = dialer
+
+	var (
+		reader        kafkaReader
+		cg            consumerGroup
+		openPartition func(topic string, partition int, offset int64) (kafkaReader, error)
+	)
+	if config.partitionFetch {
+		topics := config.topics
+		if len(topics) == 0 {
+			topics = []string{topic}
 		}
+		cg = newKafkaGroup(readerConfig, topics)
+		openPartition = newKafkaPartitionReader(readerConfig)
+	} else {
+		reader = kafka.NewReader(readerConfig)
 	}
 
-	reader := kafka.NewReader(readerConfig)
-
 	// 设置默认值
 	if config.Processors <= 0 {
 		config.Processors = defaultProcessors
@@ -238,21 +337,64 @@ func NewReader(brokers []string, topic, group string, handler ConsumeHandler, op
 	if config.Consumers <= 0 {
 		config.Consumers = defaultConsumers
 	}
+	if config.ConsumeBatchSize <= 0 {
+		config.ConsumeBatchSize = defaultConsumeBatchSize
+	}
+	if config.ConsumeBatchTimeout <= 0 {
+		config.ConsumeBatchTimeout = defaultConsumeBatchTimeout
+	}
 
-	return &Reader{
+	channels := make([]chan kafka.Message, 1)
+	switch {
+	case config.partitionFetch:
+		// 按分区拉取时每个分区由各自的协程处理，不经过分发
+		config.OrderedByKey = false
+		channels = nil
+	case config.Ordered || config.OrderedByKey:
+		// 多个获取协程会打乱同一分区的分发顺序
+		config.Processors = 1
+		channels = make([]chan kafka.Message, config.Consumers)
+	}
+	for i := range channels {
+		channels[i] = make(chan kafka.Message)
+	}
+
+	var tracker *offsetTracker
+	if config.OrderedByKey {
+		tracker = newOffsetTracker()
+	}
+
+	r := &Reader{
 		topic:            topic,
 		group:            group,
 		handler:          handler,
 		reader:           reader,
+		cg:               cg,
+		openPartition:    openPartition,
 		transitMetrics:   stat.NewMetrics("kafka.reader.transit." + group),
 		bizMetrics:       stat.NewMetrics("kafka.reader.biz." + group),
 		logger:           newReaderEventLogger(topic, group),
-		channel:          make(chan kafka.Message),
+		channels:         channels,
 		fetcherRoutines:  threading.NewRoutineGroup(),
 		consumerRoutines: threading.NewRoutineGroup(),
 		processors:       config.Processors,
 		consumers:        config.Consumers,
+		orderedByKey:     config.OrderedByKey,
+		tracker:          tracker,
+		batchSize:        config.ConsumeBatchSize,
+		batchTimeout:     config.ConsumeBatchTimeout,
+		maxBytes:         readerConfig.MaxBytes,
+		done:             make(chan struct{}),
+	}
+	r.consume = r.buildConsumeChain(config)
+	if config.LagMonitor != nil {
+		transport, err := NewTransport(config.Auth)
+		if err != nil {
+			log.Fatalf("kafka.reader %s auth config error: %v", group, err)
+		}
+		r.lagMonitor = newLagMonitor(brokers, topic, group, clientID, transport, *config.LagMonitor)
 	}
+	return r
 }
 
 // Name 返回 Reader 读取的 topic 名称
@@ -267,70 +409,100 @@ func (r *Reader) Group() string {
 
 // Start 启动消费者和处理协程
 func (r *Reader) Start() {
-	r.startConsumers()
-	r.startFetchers()
+	retryRoutines := threading.NewRoutineGroup()
+	if r.retryReader != nil {
+		retryRoutines.RunSafe(r.retryReader.Start)
+	}
+	if r.lagMonitor != nil {
+		retryRoutines.RunSafe(func() {
+			r.lagMonitor.start(r.done)
+		})
+	}
+
+	switch {
+	case r.cg != nil:
+		r.consumePartitions()
+	case r.batchHandler != nil:
+		r.startBatchConsumers()
+		r.startFetchers()
+	default:
+		r.startConsumers()
+		r.startFetchers()
+	}
 	r.fetcherRoutines.Wait()
-	close(r.channel)
+	for _, channel := range r.channels {
+		close(channel)
+	}
 	r.consumerRoutines.Wait()
 
+	// 重试策略由主 Reader 持有，重试 Reader 退出后再关闭 Writer
+	retryRoutines.Wait()
+	if r.retryReader != nil {
+		r.retry.close()
+	}
+
 	r.logger.Infof(context.Background(), "consumers closed")
 }
 
 // Stop 停止 Reader 并释放资源
 func (r *Reader) Stop() {
-	if err := r.reader.Close(); err != nil {
+	// 按分区拉取时关闭消费组，等待各分区协程退出
+	var closer io.Closer = r.reader
+	if r.cg != nil {
+		closer = r.cg
+	}
+	if err := closer.Close(); err != nil {
 		r.logger.Errorf(context.Background(), "reader close error: %v", err)
 	}
+	r.stopOnce.Do(func() {
+		close(r.done)
+	})
+	if r.retryReader != nil {
+		r.retryReader.Stop()
+		// 先关闭 Reader 再唤醒等待重试的消息，避免被唤醒的消息提交 offset
+		r.retry.stop()
+	}
 }
 
-// startConsumers 启动消费协程处理消息
+// startConsumers 启动消费协程处理消息，共享分发时可通过 SetConsumers 调整协程数量
 func (r *Reader) startConsumers() {
+	if len(r.channels) == 1 {
+		r.workersMu.Lock()
+		defer r.workersMu.Unlock()
+		r.workers = make([]chan struct{}, 0, r.consumers)
+		for i := 0; i < r.consumers; i++ {
+			r.addWorker()
+		}
+		return
+	}
+
 	for i := 0; i < r.consumers; i++ {
+		channel := r.channels[i%len(r.channels)]
 		r.consumerRoutines.RunSafe(func() {
-			for msg := range r.channel {
-				func() {
-					ctx := contextFromMessage(msg)
-					ctx, span := kafkaTracer.Start(ctx, "consume",
-						trace.WithSpanKind(trace.SpanKindConsumer),
-					)
-					defer span.End()
-
-					r.logger.Infof(ctx, "received message partition:%d, offset:%d, key:%s, value:%s", msg.Partition, msg.Offset, string(msg.Key), string(msg.Value))
-
-					// 消费业务逻辑
-					r.consumeMessage(ctx, msg)
-
-					if err := r.reader.CommitMessages(ctx, msg); err != nil {
-						r.logger.Errorf(ctx, "commit message failed, partition:%d, offset:%d, key:%s, message:%s, error:%v",
-							msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err)
-					}
-				}()
+			for msg := range channel {
+				r.handleMessage(msg)
 			}
 		})
 	}
 }
 
-// consumeMessage 处理单条消息
-func (r *Reader) consumeMessage(ctx context.Context, msg kafka.Message) {
-	now := time.Now()
-	var err error
-
-	// defer recover 处理 panic
-	defer func() {
-		if p := recover(); p != nil {
-			err = fmt.Errorf("%+v", p)
-			r.logger.Errorf(ctx, "consume panic partition:%d, offset:%d, key:%s, message:%s, panic:%+v \nstack:%s",
-				msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), p, string(debug.Stack()))
+// commit 提交已处理的消息，按 key 分发时只提交分区内连续完成的最大 offset
+func (r *Reader) commit(ctx context.Context, msg kafka.Message) {
+	if r.tracker != nil {
+		var ok bool
+		if msg, ok = r.tracker.complete(msg); !ok {
+			return
 		}
-		// 统一记录 metrics（panic/业务错误都视为失败）
-		duration := time.Since(now)
-		r.bizMetrics.Add(stat.Task{
-			Duration: duration,
-			Drop:     err != nil,
-		})
-	}()
+	}
+
+	if err := r.reader.CommitMessages(ctx, msg); err != nil {
+		r.logger.Errorf(ctx, "commit message failed, partition:%d, offset:%d, key:%s, message:%s, error:%v",
+			msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err)
+	}
+}
 
-	// 流转从上游push到下游拿到开始消费耗时metrics
+// recordTransit 记录流转从上游 push 到下游拿到开始消费的耗时 metrics
+func (r *Reader) recordTransit(ctx context.Context, msg kafka.Message, now time.Time) {
 	if duration := calculateDuration(msg, now); duration >= 0 {
 		r.transitMetrics.Add(stat.Task{
 			Duration: duration,
@@ -339,21 +511,13 @@ func (r *Reader) consumeMessage(ctx context.Context, msg kafka.Message) {
 			r.logger.Slowf(ctx, "transit slow partition:%d, offset:%d, key:%s, value:%s, duration:%d", msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), duration)
 		}
 	}
-
-	// 业务逻辑
-	err = r.handler.Consume(ctx, string(msg.Key), string(msg.Value))
-	if err != nil {
-		r.logger.Errorf(ctx, "consume failed, partition:%d, offset:%d, key:%s, value:%s, error:%v", msg.Partition, msg.Offset, string(msg.Key), string(msg.Value), err)
-	}
 }
 
 // startFetchers 启动获取协程从 kafka 获取消息
 func (r *Reader) startFetchers() {
 	for i := 0; i < r.processors; i++ {
 		r.fetcherRoutines.RunSafe(func() {
-			if err := r.fetchLoop(func(msg kafka.Message) {
-				r.channel <- msg
-			}); err != nil {
+			if err := r.fetchLoop(r.dispatch); err != nil {
 				r.logger.Infof(context.Background(), "fetcher closed reason:%v", err)
 				return
 			}
@@ -361,9 +525,25 @@ func (r *Reader) startFetchers() {
 	}
 }
 
+// dispatch 把消息投递给消费协程，有序消费时同一分区（或同一 key）始终落在同一个消费协程
+func (r *Reader) dispatch(msg kafka.Message) {
+	if r.tracker != nil {
+		r.tracker.track(msg)
+	}
+
+	index := msg.Partition
+	if r.orderedByKey && len(msg.Key) > 0 {
+		hash := fnv.New32a()
+		_, _ = hash.Write(msg.Key)
+		index = int(hash.Sum32() % uint32(len(r.channels)))
+	}
+	r.channels[index%len(r.channels)] <- msg
+}
+
 // fetchLoop 持续获取消息并处理
 func (r *Reader) fetchLoop(handle func(msg kafka.Message)) error {
 	for {
+		r.gate.wait(r.done)
 		msg, err := r.reader.FetchMessage(context.Background())
 		// io.EOF means consumer closed
 		// io.ErrClosedPipe means committing messages on the consumer,
@@ -375,6 +555,10 @@ func (r *Reader) fetchLoop(handle func(msg kafka.Message)) error {
 			r.logger.Errorf(context.Background(), "fetch message failed, error:%v", err)
 			continue
 		}
+		// 解压失败时保留原始 value 交给处理器，由重试或死信流程处理
+		if err := decompressMessage(&msg, int64(r.maxBytes)); err != nil {
+			r.logger.Errorf(context.Background(), "decompress message failed, partition:%d, offset:%d, error:%v", msg.Partition, msg.Offset, err)
+		}
 
 		handle(msg)
 	}
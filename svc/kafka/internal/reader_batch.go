@@ -0,0 +1,195 @@
+package internal
+
+import (
+	"context"
+	"fmt"
+	"runtime/debug"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/stat"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	// defaultConsumeBatchSize 默认每批最大消息数
+	defaultConsumeBatchSize = 100
+	// defaultConsumeBatchTimeout 默认批次从首条消息起的最长等待时间
+	defaultConsumeBatchTimeout = 100 * time.Millisecond
+	// defaultBatchRetryBackoff 未配置重试 topic 时批次原地重试的首次退避时间
+	defaultBatchRetryBackoff = time.Second
+	// defaultBatchRetryMaxBackoff 批次原地重试的退避时间上限
+	defaultBatchRetryMaxBackoff = 30 * time.Second
+)
+
+type (
+	// BatchConsumeHandler 批量消费的处理器接口，处理器实现该接口后 Reader 按分区攒批调用 ConsumeBatch，
+	// 同一批消息来自同一分区且按 offset 有序，处理成功后才提交该批最大 offset
+	BatchConsumeHandler interface {
+		ConsumeBatch(ctx context.Context, msgs []kafka.Message) error
+	}
+
+	// batchMessageHandler 把单条消息作为一批交给 BatchConsumeHandler，用于重试 topic
+	batchMessageHandler struct {
+		handler BatchConsumeHandler
+	}
+)
+
+// ConsumeMessage 实现 MessageHandler
+func (h batchMessageHandler) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	return h.handler.ConsumeBatch(ctx, []kafka.Message{msg})
+}
+
+// asBatchHandler 判断处理器是否实现了 BatchConsumeHandler
+func asBatchHandler(handler MessageHandler) (BatchConsumeHandler, bool) {
+	if adapter, ok := handler.(consumeAdapter); ok {
+		batchHandler, ok := adapter.handler.(BatchConsumeHandler)
+		return batchHandler, ok
+	}
+	batchHandler, ok := handler.(BatchConsumeHandler)
+	return batchHandler, ok
+}
+
+// startBatchConsumers 启动批量消费协程，每个协程按分区攒批，达到 batchSize 或 batchTimeout 时处理
+func (r *Reader) startBatchConsumers() {
+	for i := 0; i < r.consumers; i++ {
+		channel := r.channels[i%len(r.channels)]
+		r.consumerRoutines.RunSafe(func() {
+			batches := make(map[int][]kafka.Message)
+			deadlines := make(map[int]time.Time)
+			flush := func(partition int) {
+				msgs := batches[partition]
+				delete(batches, partition)
+				delete(deadlines, partition)
+				r.consumeBatch(msgs)
+			}
+
+			for {
+				var timeout <-chan time.Time
+				if deadline, ok := earliestDeadline(deadlines); ok {
+					timeout = time.After(time.Until(deadline))
+				}
+
+				select {
+				case msg, ok := <-channel:
+					if !ok {
+						// Reader 已关闭，未处理的批次无法再提交，直接丢弃，重启后重新消费
+						for partition, msgs := range batches {
+							r.logger.Infof(context.Background(), "discard pending batch on close, partition:%d, size:%d", partition, len(msgs))
+						}
+						return
+					}
+					if _, ok := batches[msg.Partition]; !ok {
+						deadlines[msg.Partition] = time.Now().Add(r.batchTimeout)
+					}
+					batches[msg.Partition] = append(batches[msg.Partition], msg)
+					if len(batches[msg.Partition]) >= r.batchSize {
+						flush(msg.Partition)
+					}
+				case <-timeout:
+					now := time.Now()
+					for partition, deadline := range deadlines {
+						if !deadline.After(now) {
+							flush(partition)
+						}
+					}
+				}
+			}
+		})
+	}
+}
+
+// earliestDeadline 返回最早到期的批次时间
+func earliestDeadline(deadlines map[int]time.Time) (time.Time, bool) {
+	var (
+		earliest time.Time
+		found    bool
+	)
+	for _, deadline := range deadlines {
+		if !found || deadline.Before(earliest) {
+			earliest, found = deadline, true
+		}
+	}
+	return earliest, found
+}
+
+// consumeBatch 处理一批消息，成功后提交该批最大 offset。
+// 失败时若配置了重试 topic 则逐条转入重试后提交，否则原地退避重试直到成功或 Reader 停止，保证不跳过未成功的消息；
+// 转入重试时写入失败同样原地重试，Reader 停止时整批不提交。
+func (r *Reader) consumeBatch(msgs []kafka.Message) {
+	// 批次 span 关联每条消息的上游 span
+	links := make([]trace.Link, 0, len(msgs))
+	for _, msg := range msgs {
+		if sc := trace.SpanContextFromContext(contextFromMessage(msg)); sc.IsValid() {
+			links = append(links, trace.Link{SpanContext: sc})
+		}
+	}
+	ctx, span := kafkaTracer.Start(context.Background(), "consume_batch",
+		trace.WithSpanKind(trace.SpanKindConsumer),
+		trace.WithLinks(links...),
+	)
+	defer span.End()
+
+	first, last := msgs[0], msgs[len(msgs)-1]
+	r.logger.Infof(ctx, "received batch partition:%d, offset:%d-%d, size:%d", first.Partition, first.Offset, last.Offset, len(msgs))
+
+	now := time.Now()
+	for _, msg := range msgs {
+		r.recordTransit(ctx, msg, now)
+	}
+
+	backoff := defaultBatchRetryBackoff
+	for {
+		err := r.handleBatch(ctx, msgs)
+		if err == nil {
+			break
+		}
+		if r.retry != nil {
+			for _, msg := range msgs {
+				if !r.failUntilDone(ctx, msg, err) {
+					return
+				}
+			}
+			break
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-r.done:
+			return
+		}
+		backoff = min(backoff*2, defaultBatchRetryMaxBackoff)
+	}
+
+	if err := r.reader.CommitMessages(ctx, last); err != nil {
+		r.logger.Errorf(ctx, "commit batch failed, partition:%d, offset:%d-%d, error:%v", first.Partition, first.Offset, last.Offset, err)
+	}
+}
+
+// handleBatch 调用批量处理器并记录批次 metrics
+func (r *Reader) handleBatch(ctx context.Context, msgs []kafka.Message) (err error) {
+	now := time.Now()
+	first, last := msgs[0], msgs[len(msgs)-1]
+
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("%+v", p)
+			r.logger.Errorf(ctx, "consume batch panic partition:%d, offset:%d-%d, panic:%+v \nstack:%s",
+				first.Partition, first.Offset, last.Offset, p, string(debug.Stack()))
+		}
+		duration := time.Since(now)
+		r.bizMetrics.Add(stat.Task{
+			Duration: duration,
+			Drop:     err != nil,
+		})
+		metricBatchSize.Observe(int64(len(msgs)), r.topic, r.group)
+		metricBatchDuration.Observe(duration.Milliseconds(), r.topic, r.group, handlerResult(err))
+	}()
+
+	err = r.batchHandler.ConsumeBatch(ctx, msgs)
+	if err != nil {
+		r.logger.Errorf(ctx, "consume batch failed, partition:%d, offset:%d-%d, size:%d, error:%v",
+			first.Partition, first.Offset, last.Offset, len(msgs), err)
+	}
+	return err
+}
@@ -0,0 +1,142 @@
+package internal
+
+import (
+	"context"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeReader 按顺序返回预置消息，Close 后返回 io.EOF
+type fakeReader struct {
+	msgs    chan kafka.Message
+	closed  chan struct{}
+	once    sync.Once
+	mu      sync.Mutex
+	commits []kafka.Message
+}
+
+func newFakeReader(msgs ...kafka.Message) *fakeReader {
+	f := &fakeReader{
+		msgs:   make(chan kafka.Message, len(msgs)),
+		closed: make(chan struct{}),
+	}
+	for _, msg := range msgs {
+		f.msgs <- msg
+	}
+	return f
+}
+
+func (f *fakeReader) Close() error {
+	f.once.Do(func() {
+		close(f.closed)
+	})
+	return nil
+}
+
+func (f *fakeReader) FetchMessage(ctx context.Context) (kafka.Message, error) {
+	select {
+	case msg := <-f.msgs:
+		return msg, nil
+	case <-f.closed:
+		return kafka.Message{}, io.EOF
+	case <-ctx.Done():
+		return kafka.Message{}, ctx.Err()
+	}
+}
+
+func (f *fakeReader) CommitMessages(ctx context.Context, msgs ...kafka.Message) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.commits = append(f.commits, msgs...)
+	return nil
+}
+
+func (f *fakeReader) committed() []kafka.Message {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return append([]kafka.Message(nil), f.commits...)
+}
+
+type batchRecorder struct {
+	mu      sync.Mutex
+	batches [][]kafka.Message
+}
+
+func (b *batchRecorder) Consume(ctx context.Context, key, value string) error {
+	return nil
+}
+
+func (b *batchRecorder) ConsumeBatch(ctx context.Context, msgs []kafka.Message) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.batches = append(b.batches, msgs)
+	return nil
+}
+
+// newTestReader 创建 Reader 并替换为 fakeReader
+func newTestReader(t *testing.T, fake *fakeReader, handler ConsumeHandler, opts ...ReaderOptionFunc) *Reader {
+	r := NewReader([]string{"127.0.0.1:1"}, "test", "test:reader", handler, opts...)
+	_ = r.reader.Close()
+	r.reader = fake
+	return r
+}
+
+func TestReaderConsumeBatch(t *testing.T) {
+	fake := newFakeReader(
+		kafka.Message{Partition: 0, Offset: 0},
+		kafka.Message{Partition: 1, Offset: 0},
+		kafka.Message{Partition: 0, Offset: 1},
+		kafka.Message{Partition: 0, Offset: 2},
+		kafka.Message{Partition: 1, Offset: 1},
+		kafka.Message{Partition: 0, Offset: 3},
+		kafka.Message{Partition: 0, Offset: 4},
+	)
+	handler := &batchRecorder{}
+	r := newTestReader(t, fake, handler, func(config *ReaderConf) {
+		config.ConsumeBatchSize = 3
+		config.ConsumeBatchTimeout = 50 * time.Millisecond
+		config.Processors = 1
+		config.Consumers = 2
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		r.Start()
+		close(stopped)
+	}()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for len(fake.committed()) < 3 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Stop()
+	<-stopped
+
+	committed := make(map[int]int64)
+	for _, msg := range fake.committed() {
+		committed[msg.Partition] = max(committed[msg.Partition], msg.Offset)
+	}
+	if committed[0] != 4 || committed[1] != 1 {
+		t.Fatalf("committed offsets got %v, want partition 0:4, 1:1", committed)
+	}
+
+	var total int
+	for _, batch := range handler.batches {
+		if len(batch) > 3 {
+			t.Fatalf("batch size got %d, want <= 3", len(batch))
+		}
+		for i, msg := range batch {
+			if msg.Partition != batch[0].Partition || (i > 0 && msg.Offset != batch[i-1].Offset+1) {
+				t.Fatalf("batch not ordered within one partition: %+v", batch)
+			}
+		}
+		total += len(batch)
+	}
+	if total != 7 {
+		t.Fatalf("consumed %d messages, want 7", total)
+	}
+}
@@ -0,0 +1,181 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// 运行时控制：暂停/恢复获取消息、调整消费协程数量，用于下游故障时临时限流。
+// 暂停期间 Reader 保持消费组成员身份（心跳照常），已获取的消息会处理完成，不会触发重平衡。
+
+// pauseGate 暂停开关，暂停时获取协程阻塞在 wait
+type pauseGate struct {
+	mu      sync.Mutex
+	resumed chan struct{} // 暂停时非 nil，恢复时关闭
+}
+
+// pause 暂停，返回状态是否变化
+func (g *pauseGate) pause() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed != nil {
+		return false
+	}
+	g.resumed = make(chan struct{})
+	return true
+}
+
+// resume 恢复，返回状态是否变化
+func (g *pauseGate) resume() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.resumed == nil {
+		return false
+	}
+	close(g.resumed)
+	g.resumed = nil
+	return true
+}
+
+// paused 是否处于暂停状态
+func (g *pauseGate) paused() bool {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	return g.resumed != nil
+}
+
+// wait 暂停时阻塞直到恢复或 done 关闭
+func (g *pauseGate) wait(done <-chan struct{}) {
+	g.mu.Lock()
+	resumed := g.resumed
+	g.mu.Unlock()
+	if resumed == nil {
+		return
+	}
+	select {
+	case <-resumed:
+	case <-done:
+	}
+}
+
+// Pause 暂停获取消息，同时暂停重试 topic 的 Reader
+func (r *Reader) Pause() {
+	if r.gate.pause() {
+		r.logger.Infof(context.Background(), "consumer paused")
+	}
+	if r.retryReader != nil {
+		r.retryReader.Pause()
+	}
+}
+
+// Resume 恢复获取消息
+func (r *Reader) Resume() {
+	if r.gate.resume() {
+		r.logger.Infof(context.Background(), "consumer resumed")
+	}
+	if r.retryReader != nil {
+		r.retryReader.Resume()
+	}
+}
+
+// Paused 是否处于暂停状态
+func (r *Reader) Paused() bool {
+	return r.gate.paused()
+}
+
+// Consumers 返回当前消费协程数量
+func (r *Reader) Consumers() int {
+	r.workersMu.Lock()
+	defer r.workersMu.Unlock()
+	if r.workers != nil {
+		return len(r.workers)
+	}
+	return r.consumers
+}
+
+// Scalable 是否支持运行时调整消费协程数量，有序消费和批量消费按协程固定分发，不支持调整
+func (r *Reader) Scalable() bool {
+	return len(r.channels) == 1 && r.batchHandler == nil
+}
+
+// SetConsumers 运行时调整消费协程数量，减少时协程处理完当前消息后退出
+func (r *Reader) SetConsumers(consumers int) error {
+	if consumers <= 0 {
+		return fmt.Errorf("kafka.reader %s invalid consumers: %d", r.group, consumers)
+	}
+	if !r.Scalable() {
+		return fmt.Errorf("kafka.reader %s ordered or batch consumers cannot be scaled", r.group)
+	}
+
+	r.workersMu.Lock()
+	defer r.workersMu.Unlock()
+	select {
+	case <-r.done:
+		return fmt.Errorf("kafka.reader %s stopped", r.group)
+	default:
+	}
+
+	// 尚未启动时只修改启动数量
+	if r.workers == nil {
+		r.consumers = consumers
+		return nil
+	}
+
+	from := len(r.workers)
+	for len(r.workers) < consumers {
+		r.addWorker()
+	}
+	for len(r.workers) > consumers {
+		last := len(r.workers) - 1
+		close(r.workers[last])
+		r.workers = r.workers[:last]
+	}
+	r.logger.Infof(context.Background(), "consumers scaled from %d to %d", from, consumers)
+	return nil
+}
+
+// addWorker 启动一个共享队列的消费协程，调用方需持有 workersMu
+func (r *Reader) addWorker() {
+	quit := make(chan struct{})
+	r.workers = append(r.workers, quit)
+
+	channel := r.channels[0]
+	r.consumerRoutines.RunSafe(func() {
+		for {
+			select {
+			case msg, ok := <-channel:
+				if !ok {
+					return
+				}
+				r.handleMessage(msg)
+			case <-quit:
+				return
+			}
+		}
+	})
+}
+
+// handleMessage 处理单条消息，处理完成后提交 offset
+func (r *Reader) handleMessage(msg kafka.Message) {
+	ctx := contextFromMessage(msg)
+	if r.process(ctx, msg) {
+		r.commit(ctx, msg)
+	}
+}
+
+// process 经中间件链消费单条消息，失败时转入重试或死信 topic。
+// 返回 false 表示消息被停止中断或转入重试、死信 topic 未成功，不能提交，重启后重新消费
+func (r *Reader) process(ctx context.Context, msg kafka.Message) bool {
+	err := r.consume(ctx, msg)
+	if errors.Is(err, errConsumeStopped) {
+		return false
+	}
+	if err != nil && r.retry != nil {
+		return r.failUntilDone(ctx, msg, err)
+	}
+	return true
+}
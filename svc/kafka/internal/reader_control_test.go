@@ -0,0 +1,90 @@
+package internal
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type countConsumer struct {
+	count atomic.Int32
+}
+
+func (c *countConsumer) Consume(ctx context.Context, key, value string) error {
+	c.count.Add(1)
+	return nil
+}
+
+func TestReaderPauseAndScale(t *testing.T) {
+	fake := &fakeReader{msgs: make(chan kafka.Message, 10), closed: make(chan struct{})}
+	handler := &countConsumer{}
+	r := newTestReader(t, fake, handler, func(config *ReaderConf) {
+		config.Processors = 1
+		config.Consumers = 2
+	})
+
+	stopped := make(chan struct{})
+	go func() {
+		r.Start()
+		close(stopped)
+	}()
+	defer func() {
+		r.Stop()
+		<-stopped
+	}()
+
+	waitFor := func(want int32) {
+		deadline := time.Now().Add(5 * time.Second)
+		for handler.count.Load() < want && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		if got := handler.count.Load(); got != want {
+			t.Fatalf("consumed %d messages, want %d", got, want)
+		}
+	}
+
+	fake.msgs <- kafka.Message{Offset: 0}
+	waitFor(1)
+
+	// 暂停后获取协程最多取出一条已在等待的消息，其余消息保留在队列中
+	r.Pause()
+	if !r.Paused() {
+		t.Fatalf("reader not paused")
+	}
+	fake.msgs <- kafka.Message{Offset: 1}
+	fake.msgs <- kafka.Message{Offset: 2}
+	time.Sleep(100 * time.Millisecond)
+	if got := handler.count.Load(); got > 2 || len(fake.msgs) == 0 {
+		t.Fatalf("consumed %d messages while paused, queued %d", got, len(fake.msgs))
+	}
+
+	r.Resume()
+	waitFor(3)
+
+	if err := r.SetConsumers(5); err != nil || r.Consumers() != 5 {
+		t.Fatalf("scale up got %d, error:%v", r.Consumers(), err)
+	}
+	if err := r.SetConsumers(1); err != nil || r.Consumers() != 1 {
+		t.Fatalf("scale down got %d, error:%v", r.Consumers(), err)
+	}
+	fake.msgs <- kafka.Message{Offset: 3}
+	waitFor(4)
+	if err := r.SetConsumers(0); err == nil {
+		t.Fatalf("scale to 0 should fail")
+	}
+}
+
+func TestReaderOrderedNotScalable(t *testing.T) {
+	r := newTestReader(t, newFakeReader(), &countConsumer{}, func(config *ReaderConf) {
+		config.Ordered = true
+	})
+	if r.Scalable() {
+		t.Fatalf("ordered reader should not be scalable")
+	}
+	if err := r.SetConsumers(2); err == nil {
+		t.Fatalf("scale ordered reader should fail")
+	}
+}
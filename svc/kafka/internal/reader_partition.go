@@ -0,0 +1,166 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// 按分区拉取：通过消费组 generation 获取本实例分配到的分区，每个分区使用独立的 kafka.Reader 拉取并串行处理，
+// 处理成功后按分区提交 offset。一个分区等待队头到期不会阻塞其他分区，用于延迟分级 topic 转发和重试 topic。
+
+const (
+	// defaultGroupRetryBackoff 获取消费组 generation 失败后的重试间隔
+	defaultGroupRetryBackoff = time.Second
+)
+
+type (
+	// consumerGroup 消费组，每次重平衡产生新的 generation
+	consumerGroup interface {
+		Next(ctx context.Context) (generation, error)
+		Close() error
+	}
+
+	// generation 消费组的一次分配，重平衡或关闭时结束，Start 启动的协程需在 ctx 结束后退出
+	generation interface {
+		Partitions() map[string][]kafka.PartitionAssignment
+		Start(fn func(ctx context.Context))
+		CommitOffsets(offsets map[string]map[int]int64) error
+	}
+
+	// kafkaGroup 把 kafka.ConsumerGroup 适配为 consumerGroup
+	kafkaGroup struct {
+		*kafka.ConsumerGroup
+	}
+
+	// kafkaGeneration 把 kafka.Generation 适配为 generation
+	kafkaGeneration struct {
+		*kafka.Generation
+	}
+)
+
+// Next 实现 consumerGroup
+func (g kafkaGroup) Next(ctx context.Context) (generation, error) {
+	gen, err := g.ConsumerGroup.Next(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return kafkaGeneration{Generation: gen}, nil
+}
+
+// Partitions 实现 generation
+func (g kafkaGeneration) Partitions() map[string][]kafka.PartitionAssignment {
+	return g.Assignments
+}
+
+// newKafkaGroup 按 Reader 配置创建消费组
+func newKafkaGroup(config kafka.ReaderConfig, topics []string) consumerGroup {
+	cg, err := kafka.NewConsumerGroup(kafka.ConsumerGroupConfig{
+		ID:                     config.GroupID,
+		Brokers:                config.Brokers,
+		Dialer:                 config.Dialer,
+		Topics:                 topics,
+		GroupBalancers:         config.GroupBalancers,
+		HeartbeatInterval:      config.HeartbeatInterval,
+		PartitionWatchInterval: config.PartitionWatchInterval,
+		WatchPartitionChanges:  config.WatchPartitionChanges,
+		SessionTimeout:         config.SessionTimeout,
+		RebalanceTimeout:       config.RebalanceTimeout,
+		JoinGroupBackoff:       config.JoinGroupBackoff,
+		StartOffset:            config.StartOffset,
+		ErrorLogger:            config.ErrorLogger,
+	})
+	if err != nil {
+		log.Fatalf("kafka.reader %s consumer group error: %v", config.GroupID, err)
+	}
+	return kafkaGroup{ConsumerGroup: cg}
+}
+
+// newKafkaPartitionReader 返回按分区创建 kafka.Reader 的函数，不加入消费组，offset 由 generation 提交
+func newKafkaPartitionReader(config kafka.ReaderConfig) func(topic string, partition int, offset int64) (kafkaReader, error) {
+	config.GroupID = ""
+	config.GroupBalancers = nil
+	config.CommitInterval = 0
+	return func(topic string, partition int, offset int64) (kafkaReader, error) {
+		config := config
+		config.Topic = topic
+		config.Partition = partition
+		reader := kafka.NewReader(config)
+		if err := reader.SetOffset(offset); err != nil {
+			_ = reader.Close()
+			return nil, err
+		}
+		return reader, nil
+	}
+}
+
+// consumePartitions 循环获取消费组 generation，为每个分配的分区启动一个拉取协程，消费组关闭后返回
+func (r *Reader) consumePartitions() {
+	for {
+		gen, err := r.cg.Next(context.Background())
+		if errors.Is(err, kafka.ErrGroupClosed) {
+			return
+		}
+		if err != nil {
+			r.logger.Errorf(context.Background(), "consumer group next generation failed, error:%v", err)
+			select {
+			case <-time.After(defaultGroupRetryBackoff):
+				continue
+			case <-r.done:
+				return
+			}
+		}
+
+		for topic, assignments := range gen.Partitions() {
+			for _, assignment := range assignments {
+				gen.Start(func(ctx context.Context) {
+					r.consumePartition(ctx, gen, topic, assignment.ID, assignment.Offset)
+				})
+			}
+		}
+	}
+}
+
+// consumePartition 拉取单个分区并串行处理，generation 结束（重平衡或 Reader 停止）时返回，未提交的消息由新的分配重新消费
+func (r *Reader) consumePartition(ctx context.Context, gen generation, topic string, partition int, offset int64) {
+	reader, err := r.openPartition(topic, partition, offset)
+	if err != nil {
+		// 返回会结束本次 generation，消费组重新加入后再次分配
+		r.logger.Errorf(ctx, "open partition reader failed, topic:%s, partition:%d, offset:%d, error:%v", topic, partition, offset, err)
+		return
+	}
+	defer reader.Close()
+
+	for {
+		r.gate.wait(ctx.Done())
+		msg, err := reader.FetchMessage(ctx)
+		if ctx.Err() != nil || errors.Is(err, io.EOF) {
+			return
+		}
+		if err != nil {
+			r.logger.Errorf(ctx, "fetch message failed, topic:%s, partition:%d, error:%v", topic, partition, err)
+			continue
+		}
+		if err := decompressMessage(&msg, int64(r.maxBytes)); err != nil {
+			r.logger.Errorf(ctx, "decompress message failed, partition:%d, offset:%d, error:%v", msg.Partition, msg.Offset, err)
+		}
+
+		// 处理器的 ctx 在 generation 结束时取消，等待中的消息及时退出，不阻塞重平衡
+		msgCtx, cancel := context.WithCancel(contextFromMessage(msg))
+		stop := context.AfterFunc(ctx, cancel)
+		ok := r.process(msgCtx, msg)
+		stop()
+		cancel()
+		if !ok {
+			return
+		}
+
+		if err := gen.CommitOffsets(map[string]map[int]int64{topic: {partition: msg.Offset + 1}}); err != nil {
+			r.logger.Errorf(ctx, "commit message failed, topic:%s, partition:%d, offset:%d, error:%v", topic, partition, msg.Offset, err)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+package internal
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// fakeGeneration 记录提交的 offset，close 时取消 ctx 并等待 Start 启动的协程退出
+type fakeGeneration struct {
+	partitions map[string][]kafka.PartitionAssignment
+	ctx        context.Context
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+	mu         sync.Mutex
+	commits    map[string]map[int]int64
+}
+
+func (g *fakeGeneration) Partitions() map[string][]kafka.PartitionAssignment {
+	return g.partitions
+}
+
+func (g *fakeGeneration) Start(fn func(ctx context.Context)) {
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		fn(g.ctx)
+	}()
+}
+
+func (g *fakeGeneration) CommitOffsets(offsets map[string]map[int]int64) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	for topic, partitions := range offsets {
+		if g.commits[topic] == nil {
+			g.commits[topic] = make(map[int]int64)
+		}
+		for partition, offset := range partitions {
+			g.commits[topic][partition] = offset
+		}
+	}
+	return nil
+}
+
+func (g *fakeGeneration) committed(topic string, partition int) (int64, bool) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	offset, ok := g.commits[topic][partition]
+	return offset, ok
+}
+
+// fakeGroup 只产生一次 generation，Close 后结束该 generation
+type fakeGroup struct {
+	gen    *fakeGeneration
+	next   chan *fakeGeneration
+	closed chan struct{}
+	once   sync.Once
+}
+
+func (g *fakeGroup) Next(ctx context.Context) (generation, error) {
+	select {
+	case gen := <-g.next:
+		return gen, nil
+	case <-g.closed:
+		return nil, kafka.ErrGroupClosed
+	}
+}
+
+func (g *fakeGroup) Close() error {
+	g.once.Do(func() {
+		close(g.closed)
+		g.gen.cancel()
+		g.gen.wg.Wait()
+	})
+	return nil
+}
+
+// useFakeGroup 把按分区拉取的 Reader 替换为 fakeGroup，每个分区从预置消息的 fakeReader 拉取
+func useFakeGroup(t *testing.T, r *Reader, msgs map[string]map[int][]kafka.Message) *fakeGroup {
+	_ = r.cg.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	gen := &fakeGeneration{
+		partitions: make(map[string][]kafka.PartitionAssignment),
+		ctx:        ctx,
+		cancel:     cancel,
+		commits:    make(map[string]map[int]int64),
+	}
+	for topic, partitions := range msgs {
+		for partition := range partitions {
+			gen.partitions[topic] = append(gen.partitions[topic], kafka.PartitionAssignment{ID: partition})
+		}
+	}
+	g := &fakeGroup{gen: gen, next: make(chan *fakeGeneration, 1), closed: make(chan struct{})}
+	g.next <- gen
+
+	r.cg = g
+	r.openPartition = func(topic string, partition int, offset int64) (kafkaReader, error) {
+		return newFakeReader(msgs[topic][partition]...), nil
+	}
+	t.Cleanup(func() {
+		_ = g.Close()
+	})
+	return g
+}
+
+// recordHandler 记录处理过的消息 key
+type recordHandler struct {
+	keys chan string
+}
+
+func (h recordHandler) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	h.keys <- string(msg.Key)
+	return nil
+}
+
+func TestDelayForwarderPartitionNotBlocked(t *testing.T) {
+	tier := DelayTier{Topic: "delay-1h", Delay: time.Hour}
+	f := NewDelayForwarder([]string{"127.0.0.1:1"}, tier, []DelayTier{tier}, nil, nil, nil)
+	w := &fakeWriter{}
+	writer := NewWriter([]string{"127.0.0.1:1"}, "orders")
+	writer.writer = w
+	f.writers["orders"] = writer
+
+	// 0 号分区队头一小时后到期，1 号分区的消息已到期
+	waiting := kafka.Message{Topic: tier.Topic, Partition: 0, Key: []byte("waiting"), Time: time.Now()}
+	SetDelayHeaders(&waiting, "orders", time.Now().Add(time.Hour))
+	due := kafka.Message{Topic: tier.Topic, Partition: 1, Key: []byte("due"), Time: time.Now()}
+	SetDelayHeaders(&due, "orders", time.Now())
+	g := useFakeGroup(t, f.reader, map[string]map[int][]kafka.Message{
+		tier.Topic: {0: {waiting}, 1: {due}},
+	})
+
+	go f.Start()
+	defer f.Stop()
+
+	deadline := time.Now().Add(2 * time.Second)
+	for w.written() == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if w.written() != 1 {
+		t.Fatalf("written %d messages, want 1", w.written())
+	}
+
+	// 到期投递保留原始 key，转发时间记录在 header
+	w.mu.Lock()
+	out := w.msgs[0]
+	w.mu.Unlock()
+	m := NewMessage(&out)
+	if string(out.Key) != "due" || len(m.GetHeader(DelayForwardedAtHeader)) == 0 || len(m.GetHeader(DelayTargetHeader)) != 0 {
+		t.Fatalf("forwarded key %s, headers %v", out.Key, out.Headers)
+	}
+
+	deadline = time.Now().Add(time.Second)
+	for time.Now().Before(deadline) {
+		if _, ok := g.gen.committed(tier.Topic, 1); ok {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if offset, ok := g.gen.committed(tier.Topic, 1); !ok || offset != 1 {
+		t.Fatalf("partition 1 committed %d, want 1", offset)
+	}
+
+	// 停止后等待中的消息不提交
+	f.Stop()
+	if offset, ok := g.gen.committed(tier.Topic, 0); ok {
+		t.Fatalf("partition 0 committed %d, want none", offset)
+	}
+}
+
+func TestRetryLongBackoffNotBlocking(t *testing.T) {
+	policy := newRetryPolicy([]string{"127.0.0.1:1"}, "test", "test:reader", ReaderConf{
+		RetryAttempts:   5,
+		RetryBackoff:    time.Second,
+		RetryMaxBackoff: 10 * time.Second,
+	})
+	defer policy.close()
+	shortTopic, longTopic := policy.retryTopicOf(1), policy.retryTopicOf(5)
+	if shortTopic == longTopic {
+		t.Fatalf("retry topics of attempt 1 and 5 are both %s", shortTopic)
+	}
+
+	handler := recordHandler{keys: make(chan string, 2)}
+	r := newRetryReader([]string{"127.0.0.1:1"}, policy, handler, ReaderConf{})
+
+	// 长退避的消息位于各自的重试 topic，不会挡住短退避的消息
+	long := kafka.Message{Topic: longTopic, Key: []byte("long")}
+	NewMessage(&long).SetHeader(RetryDueHeader, strconv.FormatInt(time.Now().Add(10*time.Second).UnixMilli(), 10))
+	short := kafka.Message{Topic: shortTopic, Key: []byte("short")}
+	NewMessage(&short).SetHeader(RetryDueHeader, strconv.FormatInt(time.Now().UnixMilli(), 10))
+	g := useFakeGroup(t, r, map[string]map[int][]kafka.Message{
+		longTopic:  {0: {long}},
+		shortTopic: {0: {short}},
+	})
+
+	go r.Start()
+	defer r.Stop()
+
+	select {
+	case key := <-handler.keys:
+		if key != "short" {
+			t.Fatalf("consumed %s first, want short", key)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("short backoff message blocked")
+	}
+
+	r.Stop()
+	if offset, ok := g.gen.committed(longTopic, 0); ok {
+		t.Fatalf("long backoff topic committed %d, want none", offset)
+	}
+}
@@ -0,0 +1,349 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zhuud/go-library/svc/conf"
+)
+
+const (
+	// RetryAttemptHeader 已重试次数
+	RetryAttemptHeader = "x-retry-attempt"
+	// RetryDueHeader 下次重试时间，Unix 毫秒
+	RetryDueHeader = "x-retry-due"
+	// OriginTopicHeader 首次消费失败时的源 topic
+	OriginTopicHeader = "x-origin-topic"
+	// OriginPartitionHeader 首次消费失败时的源 partition
+	OriginPartitionHeader = "x-origin-partition"
+	// OriginOffsetHeader 首次消费失败时的源 offset
+	OriginOffsetHeader = "x-origin-offset"
+	// DeadErrorHeader 进入死信前最后一次消费的错误信息
+	DeadErrorHeader = "x-dlq-error"
+	// DeadFailedAtHeader 进入死信的时间，Unix 毫秒
+	DeadFailedAtHeader = "x-dlq-failed-at"
+	// DeadGroupHeader 消费失败的消费组
+	DeadGroupHeader = "x-dlq-group"
+)
+
+const (
+	// defaultRetryBackoff 默认首次重试退避时间
+	defaultRetryBackoff = time.Second
+	// defaultRetryMaxBackoff 默认重试退避时间上限
+	defaultRetryMaxBackoff = 10 * time.Minute
+	// defaultRetryWriteBackoff 写入重试或死信 topic 失败后原地重试的首次退避时间
+	defaultRetryWriteBackoff = time.Second
+	// defaultRetryWriteMaxBackoff 写入重试或死信 topic 原地重试的退避时间上限
+	defaultRetryWriteMaxBackoff = 30 * time.Second
+	// defaultReplayIdleTimeout 重放死信时超过该时间没有新消息即视为已读完
+	defaultReplayIdleTimeout = 10 * time.Second
+)
+
+type (
+	// retryPolicy 消费组的重试策略：失败消息按退避时长写入对应的重试 topic 并到期后再次消费，重试用尽后写入死信 topic。
+	// 每个退避时长一个重试 topic，同一 topic 内消息的等待时长相同、按到期时间有序，长退避的消息不会挡住短退避的消息
+	retryPolicy struct {
+		group        string
+		retryTopic   string // 重试 topic 前缀
+		deadTopic    string
+		attempts     int
+		backoff      time.Duration
+		maxBackoff   time.Duration
+		retryWriters map[string]*Writer // 按重试 topic 缓存的 Writer
+		deadWriter   *Writer
+		logger       *eventLogger
+		done         chan struct{}
+		stopOnce     sync.Once
+	}
+
+	// retryWaiter 重试 topic 的处理器，等待消息到达重试时间后交给业务处理器
+	retryWaiter struct {
+		policy  *retryPolicy
+		handler MessageHandler
+	}
+)
+
+// RetryTopic 返回消费组的重试 topic 前缀：<topic>.retry.<group>，各退避时长的重试 topic 为 <前缀>.<退避时长>
+func RetryTopic(topic, group string) string {
+	return topic + ".retry." + sanitizeTopicName(group)
+}
+
+// RetryTopics 按重试配置返回消费组的全部重试 topic，每个不同的退避时长一个，可用于预先创建 topic
+func RetryTopics(topic, group string, opts ...ReaderOptionFunc) []string {
+	var config ReaderConf
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return newRetryBackoff(topic, group, config).retryTopics()
+}
+
+// DeadLetterTopic 返回消费组的死信 topic：<topic>.dlq.<group>
+func DeadLetterTopic(topic, group string) string {
+	return topic + ".dlq." + sanitizeTopicName(group)
+}
+
+// sanitizeTopicName 把 topic 不允许的字符（如消费组中的 ':'）替换为 '-'
+func sanitizeTopicName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '.', r == '_', r == '-':
+			return r
+		default:
+			return '-'
+		}
+	}, name)
+}
+
+// newRetryBackoff 创建只包含 topic 和退避配置的重试策略
+func newRetryBackoff(topic, group string, config ReaderConf) *retryPolicy {
+	p := &retryPolicy{
+		group:      group,
+		retryTopic: RetryTopic(topic, group),
+		deadTopic:  DeadLetterTopic(topic, group),
+		attempts:   config.RetryAttempts,
+		backoff:    config.RetryBackoff,
+		maxBackoff: config.RetryMaxBackoff,
+	}
+	if p.backoff <= 0 {
+		p.backoff = defaultRetryBackoff
+	}
+	if p.maxBackoff <= 0 {
+		p.maxBackoff = defaultRetryMaxBackoff
+	}
+	return p
+}
+
+// newRetryPolicy 创建重试策略，重试和死信均使用同步 Writer，确保写入成功后才提交源消息
+func newRetryPolicy(brokers []string, topic, group string, config ReaderConf) *retryPolicy {
+	p := newRetryBackoff(topic, group, config)
+	p.logger = newReaderEventLogger(topic, group)
+	p.done = make(chan struct{})
+	p.retryWriters = make(map[string]*Writer)
+	for _, retryTopic := range p.retryTopics() {
+		p.retryWriters[retryTopic] = newSyncWriter(brokers, retryTopic, config.Auth)
+	}
+	p.deadWriter = newSyncWriter(brokers, p.deadTopic, config.Auth)
+
+	return p
+}
+
+// newRetryReader 创建消费全部重试 topic 的 Reader，按分区独立拉取、串行等待到期，新消费组从最早的消息开始消费
+func newRetryReader(brokers []string, policy *retryPolicy, handler MessageHandler, config ReaderConf) *Reader {
+	config.partitionFetch = true
+	config.topics = policy.retryTopics()
+	config.StartOffset = kafka.FirstOffset
+	// 重试消息按退避时间等待，积压属于正常现象
+	config.LagMonitor = nil
+	r := newReader(brokers, policy.retryTopic, policy.group+".retry", retryWaiter{policy: policy, handler: handler}, config)
+	r.retry = policy
+	return r
+}
+
+// newSyncWriter 创建同步写入的 Writer，与 Reader 使用相同的认证配置，本地环境允许自动创建 topic
+func newSyncWriter(brokers []string, topic string, auth KafkaConf) *Writer {
+	async := false
+	return NewWriter(brokers, topic, func(config *WriterConf) {
+		config.Auth = auth
+		config.Async = &async
+		config.AllowAutoTopicCreation = conf.IsLocal()
+	})
+}
+
+// fail 处理消费失败的消息：未用尽重试次数时写入重试 topic，否则写入死信 topic；写入失败时返回错误，调用方不能提交源消息
+func (p *retryPolicy) fail(ctx context.Context, msg kafka.Message, cause error) error {
+	out := kafka.Message{
+		Key:     msg.Key,
+		Value:   msg.Value,
+		Headers: append([]kafka.Header(nil), msg.Headers...),
+	}
+	m := NewMessage(&out)
+	if len(m.GetHeader(OriginTopicHeader)) == 0 {
+		m.SetHeader(OriginTopicHeader, msg.Topic)
+		m.SetHeader(OriginPartitionHeader, strconv.Itoa(msg.Partition))
+		m.SetHeader(OriginOffsetHeader, strconv.FormatInt(msg.Offset, 10))
+	}
+
+	attempt, _ := strconv.Atoi(m.GetHeader(RetryAttemptHeader))
+	var writer *Writer
+	if attempt >= p.attempts {
+		m.DelHeader(RetryDueHeader)
+		m.SetHeader(DeadErrorHeader, cause.Error())
+		m.SetHeader(DeadFailedAtHeader, strconv.FormatInt(time.Now().UnixMilli(), 10))
+		m.SetHeader(DeadGroupHeader, p.group)
+		writer = p.deadWriter
+	} else {
+		attempt++
+		m.SetHeader(RetryAttemptHeader, strconv.Itoa(attempt))
+		m.SetHeader(RetryDueHeader, strconv.FormatInt(time.Now().Add(p.backoffOf(attempt)).UnixMilli(), 10))
+		writer = p.retryWriters[p.retryTopicOf(attempt)]
+	}
+
+	if err := writer.WriteMessage(ctx, out); err != nil {
+		return fmt.Errorf("kafka.retryPolicy.fail write topic:%s, attempt:%d, error: %w", writer.Name(), attempt, err)
+	}
+	return nil
+}
+
+// failUntilDone 把失败消息转入重试或死信 topic，写入失败时原地退避重试，直到写入成功、Reader 停止或 ctx 结束（按分区拉取时重平衡）；
+// 返回 false 表示消息未写入，此时不能提交源消息
+func (r *Reader) failUntilDone(ctx context.Context, msg kafka.Message, cause error) bool {
+	backoff := defaultRetryWriteBackoff
+	for {
+		err := r.retry.fail(ctx, msg, cause)
+		if err == nil {
+			return true
+		}
+		r.logger.Errorf(ctx, "retry message failed, partition:%d, offset:%d, key:%s, retry after:%v, error:%v",
+			msg.Partition, msg.Offset, string(msg.Key), backoff, err)
+
+		select {
+		case <-time.After(backoff):
+		case <-r.done:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+		backoff = min(backoff*2, defaultRetryWriteMaxBackoff)
+	}
+}
+
+// backoffOf 返回第 attempt 次重试的退避时间，指数增长并受上限约束
+func (p *retryPolicy) backoffOf(attempt int) time.Duration {
+	backoff := p.backoff
+	for i := 1; i < attempt && backoff < p.maxBackoff; i++ {
+		backoff *= 2
+	}
+	return min(backoff, p.maxBackoff)
+}
+
+// retryTopicOf 返回第 attempt 次重试写入的 topic：<前缀>.<退避时长>
+func (p *retryPolicy) retryTopicOf(attempt int) string {
+	return p.retryTopic + "." + formatBackoff(p.backoffOf(attempt))
+}
+
+// retryTopics 返回全部重试 topic，按退避时长升序去重
+func (p *retryPolicy) retryTopics() []string {
+	var topics []string
+	for attempt := 1; attempt <= p.attempts; attempt++ {
+		if topic := p.retryTopicOf(attempt); !slices.Contains(topics, topic) {
+			topics = append(topics, topic)
+		}
+	}
+	return topics
+}
+
+// formatBackoff 把退避时长格式化为 topic 后缀，整秒时用秒（如 5s），否则用毫秒（如 500ms）
+func formatBackoff(d time.Duration) string {
+	if d%time.Second == 0 {
+		return strconv.FormatInt(int64(d/time.Second), 10) + "s"
+	}
+	return strconv.FormatInt(d.Milliseconds(), 10) + "ms"
+}
+
+// stop 唤醒所有等待重试的消息
+func (p *retryPolicy) stop() {
+	p.stopOnce.Do(func() {
+		close(p.done)
+	})
+}
+
+// close 关闭重试和死信 Writer
+func (p *retryPolicy) close() {
+	writers := append(slices.Collect(maps.Values(p.retryWriters)), p.deadWriter)
+	for _, writer := range writers {
+		if err := writer.Close(); err != nil {
+			p.logger.Errorf(context.Background(), "retry writer close error, topic:%s, error:%v", writer.Name(), err)
+		}
+	}
+}
+
+// ConsumeMessage 实现 MessageHandler，等待到达重试时间后再交给业务处理器。
+// 等待中分区被重新分配或 Reader 停止时返回 errConsumeStopped；业务处理不随重平衡取消，处理完成后才释放分区
+func (w retryWaiter) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	dueMs, _ := strconv.ParseInt(NewMessage(&msg).GetHeader(RetryDueHeader), 10, 64)
+	if wait := time.Until(time.UnixMilli(dueMs)); wait > 0 {
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-w.policy.done:
+			timer.Stop()
+			return errConsumeStopped
+		case <-ctx.Done():
+			timer.Stop()
+			return errConsumeStopped
+		}
+	}
+	return w.handler.ConsumeMessage(context.WithoutCancel(ctx), msg)
+}
+
+// ReplayDeadLetter 把消费组死信 topic 中的消息重新投递回源 topic，返回重放条数。
+// 使用独立消费组 <dlq topic>:replay 记录进度，可重复执行；limit <= 0 表示不限条数，一段时间内没有新消息即结束。
+func ReplayDeadLetter(ctx context.Context, brokers []string, topic, group string, limit int, opts ...ReaderOptionFunc) (int, error) {
+	var config ReaderConf
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	deadTopic := DeadLetterTopic(topic, group)
+	replayGroup := deadTopic + ":replay"
+	dialer, err := NewDialer(config.Auth)
+	if err != nil {
+		return 0, fmt.Errorf("kafka.ReplayDeadLetter auth config error: %w", err)
+	}
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:     brokers,
+		GroupID:     replayGroup,
+		Topic:       deadTopic,
+		StartOffset: kafka.FirstOffset,
+		MaxWait:     defaultMaxWait,
+		Dialer:      dialer,
+		ErrorLogger: newReaderErrorLogger(deadTopic, replayGroup),
+	})
+	defer reader.Close()
+
+	writer := newSyncWriter(brokers, topic, config.Auth)
+	defer writer.Close()
+
+	var replayed int
+	for limit <= 0 || replayed < limit {
+		fetchCtx, cancel := context.WithTimeout(ctx, defaultReplayIdleTimeout)
+		msg, err := reader.FetchMessage(fetchCtx)
+		cancel()
+		if err != nil {
+			if errors.Is(err, context.DeadlineExceeded) && ctx.Err() == nil {
+				break
+			}
+			return replayed, fmt.Errorf("kafka.ReplayDeadLetter fetch error: %w", err)
+		}
+
+		out := kafka.Message{
+			Key:     msg.Key,
+			Value:   msg.Value,
+			Headers: append([]kafka.Header(nil), msg.Headers...),
+		}
+		// 清除重试和死信元信息，重放后重新计算重试次数
+		m := NewMessage(&out)
+		for _, key := range []string{RetryAttemptHeader, RetryDueHeader, OriginTopicHeader, OriginPartitionHeader, OriginOffsetHeader,
+			DeadErrorHeader, DeadFailedAtHeader, DeadGroupHeader} {
+			m.DelHeader(key)
+		}
+
+		if err := writer.WriteMessage(ctx, out); err != nil {
+			return replayed, fmt.Errorf("kafka.ReplayDeadLetter push error: %w", err)
+		}
+		if err := reader.CommitMessages(ctx, msg); err != nil {
+			return replayed, fmt.Errorf("kafka.ReplayDeadLetter commit error: %w", err)
+		}
+		replayed++
+	}
+
+	return replayed, nil
+}
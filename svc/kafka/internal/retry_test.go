@@ -0,0 +1,80 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+type failingHandler struct{}
+
+func (failingHandler) Consume(ctx context.Context, key, value string) error {
+	return errors.New("consume failed")
+}
+
+func TestRetryTopic(t *testing.T) {
+	if got := RetryTopic("order", "order:billing"); got != "order.retry.order-billing" {
+		t.Errorf("RetryTopic got %s", got)
+	}
+	if got := DeadLetterTopic("order", "order:billing"); got != "order.dlq.order-billing" {
+		t.Errorf("DeadLetterTopic got %s", got)
+	}
+}
+
+func TestRetryTopics(t *testing.T) {
+	got := RetryTopics("order", "order:billing", func(config *ReaderConf) {
+		config.RetryAttempts = 5
+		config.RetryBackoff = 500 * time.Millisecond
+		config.RetryMaxBackoff = 2 * time.Second
+	})
+	want := []string{"order.retry.order-billing.500ms", "order.retry.order-billing.1s", "order.retry.order-billing.2s"}
+	if !slices.Equal(got, want) {
+		t.Errorf("RetryTopics got %v, want %v", got, want)
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	p := &retryPolicy{backoff: time.Second, maxBackoff: 5 * time.Second}
+	want := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second, 5 * time.Second}
+	for i, w := range want {
+		if got := p.backoffOf(i + 1); got != w {
+			t.Errorf("backoffOf(%d) got %v, want %v", i+1, got, w)
+		}
+	}
+}
+
+func TestRetryWriteFailureNotCommitted(t *testing.T) {
+	setup := func(t *testing.T, w *fakeWriter) (*Reader, *fakeReader) {
+		fake := newFakeReader(kafka.Message{Topic: "test", Partition: 0, Offset: 7})
+		r := newTestReader(t, fake, failingHandler{})
+		r.retry = newRetryPolicy([]string{"127.0.0.1:1"}, "test", "test:reader", ReaderConf{RetryAttempts: 1})
+		r.retry.retryWriters[r.retry.retryTopicOf(1)].writer = w
+		return r, fake
+	}
+
+	// 写入重试 topic 失败时原地重试，成功后才提交
+	w := &fakeWriter{fails: 1}
+	r, fake := setup(t, w)
+	go r.Start()
+	deadline := time.Now().Add(3 * time.Second)
+	for len(fake.committed()) == 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	r.Stop()
+	if w.written() != 1 || len(fake.committed()) != 1 {
+		t.Fatalf("written %d, committed %d, want 1 and 1", w.written(), len(fake.committed()))
+	}
+
+	// 一直写入失败时 Reader 停止后放弃提交
+	w = &fakeWriter{fails: -1}
+	r, fake = setup(t, w)
+	time.AfterFunc(time.Millisecond*100, r.Stop)
+	r.Start()
+	if len(fake.committed()) != 0 {
+		t.Fatalf("committed %v, want none", fake.committed())
+	}
+}
@@ -2,6 +2,8 @@ package internal
 
 import (
 	"context"
+	"fmt"
+	"log"
 	"strconv"
 	"time"
 
@@ -50,6 +52,9 @@ type (
 		// 基础配置
 		AllowAutoTopicCreation bool // 是否允许自动创建 topic，默认 false
 
+		// 认证配置
+		Auth KafkaConf // SASL/TLS 认证配置，Brokers 不使用
+
 		// 性能配置
 		BatchSize    int                // 批量大小限制（消息数量），默认 100（kafka-go 默认 100）
 		BatchBytes   int64              // 批量大小限制（字节数），默认 1M（kafka-go 默认 1M）
@@ -75,6 +80,9 @@ type (
 
 		// 断路器配置
 		Breaker breaker.Breaker // 断路器（nil 时不启用熔断）
+
+		// 拦截器配置
+		Interceptors []ProduceInterceptor // 生产拦截器，写入前按顺序执行，最后总是按 BatchBytes 检查消息大小
 	}
 
 	Writer struct {
@@ -84,6 +92,8 @@ type (
 		logger  *eventLogger
 		breaker breaker.Breaker // 断路器（可选），如果为 nil 则不启用熔断
 		async   bool
+
+		interceptors []ProduceInterceptor
 	}
 )
 
@@ -148,6 +158,8 @@ func NewWriter(addrs []string, topic string, opts ...WriterOptionFunc) *Writer {
 	if config.Balancer != nil {
 		writer.Balancer = config.Balancer
 	}
+	// 支持通过 PartitionHeader 指定分区，写入前由 choosePartition 转交平衡器
+	writer.Balancer = partitionBalancer{balancer: writer.Balancer}
 	if config.Compression != 0 {
 		writer.Compression = config.Compression
 	}
@@ -160,6 +172,15 @@ func NewWriter(addrs []string, topic string, opts ...WriterOptionFunc) *Writer {
 		writer.WriteBackoffMax = config.WriteBackoffMax
 	}
 
+	// 认证配置
+	transport, err := NewTransport(config.Auth)
+	if err != nil {
+		log.Fatalf("kafka.writer %s auth config error: %v", topic, err)
+	}
+	if transport != nil {
+		writer.Transport = transport
+	}
+
 	// 回调配置
 	// 只有当 completion 被显式设置时才覆盖默认值
 	if config.Completion != nil {
@@ -173,6 +194,8 @@ func NewWriter(addrs []string, topic string, opts ...WriterOptionFunc) *Writer {
 		logger:  eventLogger,
 		breaker: config.Breaker, // 使用配置中的 breaker，如果为 nil 则不启用熔断
 		async:   writer.Async,
+		// 超过 BatchBytes 的消息 kafka-go 无法发送，异步模式下只能在回调中发现，写入前直接拒绝
+		interceptors: append(append([]ProduceInterceptor(nil), config.Interceptors...), SizeLimitInterceptor(writer.BatchBytes)),
 	}
 }
 
@@ -196,17 +219,33 @@ func (w *Writer) Push(ctx context.Context, v string) error {
 
 // PushWithKey 发送带 key 的消息到 Kafka topic
 func (w *Writer) PushWithKey(ctx context.Context, key, v string) error {
+	return w.WriteMessage(ctx, kafka.Message{
+		Key:   []byte(key),
+		Value: []byte(v),
+	})
+}
+
+// WriteMessage 发送完整的 kafka.Message 到 Kafka topic，可携带自定义 headers
+func (w *Writer) WriteMessage(ctx context.Context, msg kafka.Message) error {
 	ctx, span := kafkaTracer.Start(ctx, "push",
 		trace.WithSpanKind(trace.SpanKindProducer),
 	)
 	defer span.End()
 
-	msg := kafka.Message{
-		Key:   []byte(key),
-		Value: []byte(v),
-	}
 	injectContextToMessage(ctx, &msg)
+	choosePartition(&msg)
+
+	for _, intercept := range w.interceptors {
+		if err := intercept(ctx, &msg); err != nil {
+			w.logger.Errorf(ctx, "push message rejected, key:%s, error:%v", string(msg.Key), err)
+			return err
+		}
+	}
 
+	key, v := string(msg.Key), string(msg.Value)
+	if encoding := NewMessage(&msg).GetHeader(ContentEncodingHeader); len(encoding) > 0 {
+		v = fmt.Sprintf("<%s %d bytes>", encoding, len(msg.Value))
+	}
 	w.logger.Infof(ctx, "push message key:%s, value:%s", key, v)
 
 	// 如果配置了断路器，使用断路器包装请求执行
@@ -228,3 +267,18 @@ func (w *Writer) PushWithKey(ctx context.Context, key, v string) error {
 
 	return nil
 }
+
+// newRoutedWriter 创建写入 topic 的 Writer，router 不为 nil 时按 topic 选择集群的 brokers 和认证配置，opts 中的认证配置优先
+func newRoutedWriter(brokers []string, topic string, router TopicRouter, opts []WriterOptionFunc) (*Writer, error) {
+	if router == nil {
+		return NewWriter(brokers, topic, opts...), nil
+	}
+	cluster, err := router(topic)
+	if err != nil {
+		return nil, err
+	}
+	withAuth := func(config *WriterConf) {
+		config.Auth = cluster
+	}
+	return NewWriter(cluster.Brokers, topic, append([]WriterOptionFunc{withAuth}, opts...)...), nil
+}
@@ -42,8 +42,11 @@ func newDefaultCompletionCallback(eventLogger *eventLogger, metrics *stat.Metric
 	}
 }
 
-// calculateDuration 优先从 key 解析纳秒时间戳；失败时回退从 value 的 timestamp（Unix秒）解析。
+// calculateDuration 延迟转发的消息从转发时间计算；其余优先从 key 解析纳秒时间戳，失败时回退从 value 的 timestamp（Unix秒）解析。
 func calculateDuration(msg kafka.Message, now time.Time) time.Duration {
+	if ts, err := strconv.ParseInt(NewMessage(&msg).GetHeader(DelayForwardedAtHeader), 10, 64); err == nil {
+		return validateDuration(now.Sub(time.Unix(0, ts)))
+	}
 	if ts, err := strconv.ParseInt(string(msg.Key), 10, 64); err == nil {
 		if duration := validateDuration(now.Sub(time.Unix(0, ts))); duration >= 0 {
 			return duration
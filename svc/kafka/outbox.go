@@ -0,0 +1,197 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/zeromicro/go-zero/core/proc"
+	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zhuud/go-library/svc/conf"
+	"github.com/zhuud/go-library/svc/gorm"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 事务消息（outbox）：在 gorm.BaseDao.Transaction 内调用 PushInTx，消息与业务数据写入同一事务，
+// 提交后由 OutboxSetUp 启动的转发器发送到 Kafka；同一 key 的消息按写入顺序发送。
+// 只写入、由其他服务转发时，用 SetOutboxTable 指定 PushInTx 写入的表名。
+
+type (
+	// OutboxOptionFunc 是 internal.OutboxOptionFunc 的类型别名
+	OutboxOptionFunc = internal.OutboxOptionFunc
+	// OutboxMessage 是 internal.OutboxMessage 的类型别名
+	OutboxMessage = internal.OutboxMessage
+)
+
+var (
+	outboxTableMu sync.RWMutex
+	outboxTable   = internal.DefaultOutboxTable
+
+	outboxOnce sync.Once
+)
+
+// PushInTx 在 ctx 携带的 gorm 事务内写入 outbox 表，事务提交后由转发器发送到 Kafka。
+// 支持 WithKey、WithHeaders、WithPartition；同一 key 的消息按写入顺序发送，未指定 key 时不保证顺序。
+// ctx 中没有进行中的事务时返回错误，不会退化为直接推送。
+func PushInTx(ctx context.Context, topic string, data any, opts ...PushOption) error {
+	if len(topic) == 0 {
+		return fmt.Errorf("kafka.PushInTx topic not set")
+	}
+	tx, ok := gorm.TxFromContext(ctx)
+	if !ok {
+		return fmt.Errorf("kafka.PushInTx transaction not found in context")
+	}
+
+	var config pushConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	produceJson, err := marshalProduceMessage(ctx, topic, data, caller(2))
+	if err != nil {
+		return err
+	}
+
+	msg, err := internal.NewOutboxMessage(ctx, topic, config.key, produceJson, config.messageHeaders())
+	if err != nil {
+		return fmt.Errorf("kafka.PushInTx headers error: %w", err)
+	}
+	if err := tx.Table(getOutboxTable()).Create(msg).Error; err != nil {
+		return fmt.Errorf("kafka.PushInTx insert error: %w", err)
+	}
+	return nil
+}
+
+// OutboxSetUp 启动 outbox 转发器（后台运行 + 自动注册关闭钩子），dbName 需与业务写入使用的库一致。
+// 幂等安全，多次调用只有首次生效；可在多个实例中同时启动，认领时互相跳过已锁定或租约未到期的行。
+func OutboxSetUp(dbName string, opts ...OutboxOptionFunc) {
+	outboxOnce.Do(func() {
+		// 按消息的 topic 选择集群
+		opts = append(opts, func(config *internal.OutboxConf) {
+			config.Router = internal.RouteTopic
+		})
+		if conf.IsLocal() {
+			opts = append(opts, func(config *internal.OutboxConf) {
+				config.WriterOpts = append(config.WriterOpts, WithAllowAutoTopicCreation())
+			})
+		}
+		relay := internal.NewOutboxRelay(gorm.GetDB(dbName), nil, opts...)
+		SetOutboxTable(relay.Table())
+
+		threading.GoSafe(relay.Start)
+		proc.AddWrapUpListener(func() {
+			relay.Stop()
+			log.Printf("kafka.outbox relay closed, db:%s, table:%s", dbName, relay.Table())
+		})
+		log.Printf("Starting Kafka Outbox Relay, DB: %s, Table: %s ...", dbName, relay.Table())
+	})
+}
+
+// MigrateOutbox 在 dbName 库中自动创建/更新 outbox 表，table 为空时使用当前配置的表名
+func MigrateOutbox(ctx context.Context, dbName string, table string) error {
+	if len(table) == 0 {
+		table = getOutboxTable()
+	}
+	if err := internal.MigrateOutbox(ctx, gorm.GetDB(dbName), table); err != nil {
+		return fmt.Errorf("kafka.MigrateOutbox error: %w", err)
+	}
+	return nil
+}
+
+// WithOutboxTable 配置 outbox 表名，默认 kafka_outbox；PushInTx 写入同一张表
+func WithOutboxTable(table string) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if len(table) > 0 {
+			config.Table = table
+		}
+	}
+}
+
+// WithOutboxBatchSize 配置每轮认领的消息数
+func WithOutboxBatchSize(size int) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if size > 0 {
+			config.BatchSize = size
+		}
+	}
+}
+
+// WithOutboxMaxAttempts 配置最大发送次数，默认 20，用尽后标记为死信（status=2）并报警，不再阻塞同 key 的后续消息
+func WithOutboxMaxAttempts(attempts int) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if attempts > 0 {
+			config.MaxAttempts = attempts
+		}
+	}
+}
+
+// WithOutboxLeaseTimeout 配置认领租约时长，默认 1 分钟，需大于单批发送耗时；发送超过租约时长会被取消，到期未标记的消息由其他实例重新认领
+func WithOutboxLeaseTimeout(timeout time.Duration) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if timeout > 0 {
+			config.LeaseTimeout = timeout
+		}
+	}
+}
+
+// WithOutboxAlarm 配置死信报警发送器（如 *alarm.Alarm）和报警消息构造函数，message 为 nil 时发送文本描述，
+// 消息格式需与发送器匹配（如 alarm.LarkMessage）
+func WithOutboxAlarm(sender AlarmSender, message func(*OutboxMessage) any) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		config.Alarm = sender
+		config.AlarmMessage = message
+	}
+}
+
+// WithOutboxPollInterval 配置空闲时的轮询间隔
+func WithOutboxPollInterval(interval time.Duration) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if interval > 0 {
+			config.PollInterval = interval
+		}
+	}
+}
+
+// WithOutboxRetention 配置已发送消息的保留时间，到期后清理
+func WithOutboxRetention(retention time.Duration) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if retention > 0 {
+			config.Retention = retention
+		}
+	}
+}
+
+// WithOutboxCleanupInterval 配置清理已发送消息的间隔
+func WithOutboxCleanupInterval(interval time.Duration) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		if interval > 0 {
+			config.CleanupInterval = interval
+		}
+	}
+}
+
+// WithOutboxWriterOptions 配置转发使用的 Writer（始终同步写入）
+func WithOutboxWriterOptions(opts ...WriterOptionFunc) OutboxOptionFunc {
+	return func(config *internal.OutboxConf) {
+		config.WriterOpts = append(config.WriterOpts, opts...)
+	}
+}
+
+// SetOutboxTable 设置 PushInTx 写入的表名，默认 kafka_outbox；OutboxSetUp 会设置为转发器的表名
+func SetOutboxTable(table string) {
+	if len(table) == 0 {
+		return
+	}
+	outboxTableMu.Lock()
+	defer outboxTableMu.Unlock()
+	outboxTable = table
+}
+
+// getOutboxTable 返回 PushInTx 写入的表名
+func getOutboxTable() string {
+	outboxTableMu.RLock()
+	defer outboxTableMu.RUnlock()
+	return outboxTable
+}
@@ -6,6 +6,8 @@ import (
 	"fmt"
 	"io"
 	"runtime"
+	"slices"
+	"strconv"
 	"sync"
 	"time"
 
@@ -30,6 +32,16 @@ type (
 	}
 	// WriterOptionFunc 是 internal.WriterOptionFunc 的类型别名，方便外部包使用
 	WriterOptionFunc = internal.WriterOptionFunc
+
+	// PushOption PushWithOptions 选项
+	PushOption func(config *pushConfig)
+
+	pushConfig struct {
+		key        string
+		headers    map[string]string
+		partition  *int
+		writerOpts []WriterOptionFunc
+	}
 )
 
 var (
@@ -45,14 +57,103 @@ func Push(ctx context.Context, topic string, data any, opts ...WriterOptionFunc)
 		return err
 	}
 
-	fileName := ""
-	if _, file, line, ok := runtime.Caller(1); ok {
-		fileName = fmt.Sprintf("%s:%d", file, line)
+	produceJson, err := marshalProduceMessage(ctx, topic, data, caller(2))
+	if err != nil {
+		return err
 	}
 
+	return producer.Push(ctx, produceJson)
+}
+
+// PushWithOptions 推送消息到 Kafka，可指定 key、headers 和分区。
+// 指定 key 后流转耗时 metrics 回退使用信封中的 timestamp 计算；按 key 分区需配合 WithBalancer(&kafka.Hash{}) 等平衡器
+func PushWithOptions(ctx context.Context, topic string, data any, opts ...PushOption) error {
+	var config pushConfig
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	producer, err := NewProducer(topic, config.writerOpts...)
+	if err != nil {
+		return err
+	}
+
+	produceJson, err := marshalProduceMessage(ctx, topic, data, caller(2))
+	if err != nil {
+		return err
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(config.key),
+		Value: []byte(produceJson),
+	}
+	if len(config.key) == 0 {
+		msg.Key = []byte(strconv.FormatInt(time.Now().UnixNano(), 10))
+	}
+	m := internal.NewMessage(&msg)
+	for k, v := range config.messageHeaders() {
+		m.SetHeader(k, v)
+	}
+
+	return producer.WriteMessage(ctx, msg)
+}
+
+// messageHeaders 返回消息的 headers，指定分区时附加 PartitionHeader，由 Writer 写入前移除
+func (c pushConfig) messageHeaders() map[string]string {
+	if c.partition == nil {
+		return c.headers
+	}
+	headers := make(map[string]string, len(c.headers)+1)
+	for k, v := range c.headers {
+		headers[k] = v
+	}
+	headers[internal.PartitionHeader] = strconv.Itoa(*c.partition)
+	return headers
+}
+
+// WithKey 指定消息 key，默认使用当前纳秒时间戳
+func WithKey(key string) PushOption {
+	return func(config *pushConfig) {
+		config.key = key
+	}
+}
+
+// WithHeaders 指定消息 headers
+func WithHeaders(headers map[string]string) PushOption {
+	return func(config *pushConfig) {
+		if config.headers == nil {
+			config.headers = make(map[string]string, len(headers))
+		}
+		for k, v := range headers {
+			config.headers[k] = v
+		}
+	}
+}
+
+// WithMessageId 指定消息唯一 id，写入 MessageIdHeader，消费端开启 WithDedup 时据此去重
+func WithMessageId(id string) PushOption {
+	return WithHeaders(map[string]string{MessageIdHeader: id})
+}
+
+// WithPartition 指定写入分区，分区不存在时回退使用 topic 配置的平衡器
+func WithPartition(partition int) PushOption {
+	return func(config *pushConfig) {
+		config.partition = &partition
+	}
+}
+
+// WithWriterOptions 指定 topic 首次初始化 producer 时使用的配置
+func WithWriterOptions(opts ...WriterOptionFunc) PushOption {
+	return func(config *pushConfig) {
+		config.writerOpts = append(config.writerOpts, opts...)
+	}
+}
+
+// marshalProduceMessage 把业务数据包装为统一的消息信封并序列化
+func marshalProduceMessage(ctx context.Context, topic string, data any, from string) (string, error) {
 	produce := &produceMessage{
 		Topic:     topic,
-		From:      fileName,
+		From:      from,
 		Timestamp: time.Now().Unix(),
 		Data:      data,
 		LogId:     utils.TraceIDFromContext(ctx),
@@ -60,10 +161,17 @@ func Push(ctx context.Context, topic string, data any, opts ...WriterOptionFunc)
 	produceJson, err := json.Marshal(produce)
 	if err != nil {
 		logx.WithContext(ctx).Errorf("kafka.producer.Push.Marshal data: %v, error: %v", produce, err)
-		return err
+		return "", err
 	}
+	return string(produceJson), nil
+}
 
-	return producer.Push(ctx, string(produceJson))
+// caller 返回调用栈上第 skip 层的 file:line，skip 含义同 runtime.Caller
+func caller(skip int) string {
+	if _, file, line, ok := runtime.Caller(skip); ok {
+		return fmt.Sprintf("%s:%d", file, line)
+	}
+	return ""
 }
 
 // newProducer 创建 Producer 实例
@@ -74,23 +182,23 @@ func NewProducer(topic string, opts ...WriterOptionFunc) (*internal.Writer, erro
 			_ = producerManager.Close()
 		})
 	})
-	servers, err := internal.GetServers()
-		if err != nil {
-			return nil, err
-		}
-	if len(servers) == 0 {
-		return nil, fmt.Errorf("kafka.producer servers not set")
-	}
 	if len(topic) == 0 {
 		return nil, fmt.Errorf("kafka.producer topic not set")
 	}
 
-	resource, err := producerManager.GetResource(topic, func() (io.Closer, error) {
+	// 按集群 + topic 缓存，topic 切换集群后使用新的 Writer
+	clusterName := internal.TopicCluster(topic)
+	resource, err := producerManager.GetResource(clusterName+"/"+topic, func() (io.Closer, error) {
+		cluster, err := internal.GetCluster(clusterName)
+		if err != nil {
+			return nil, err
+		}
+		opts = slices.Concat([]WriterOptionFunc{WithAuth(cluster)}, getTopicWriterOptions(topic), opts)
 		if conf.IsLocal() {
 			opts = append(opts, WithAllowAutoTopicCreation())
 		}
 
-		producer := internal.NewWriter(servers, topic, opts...)
+		producer := internal.NewWriter(cluster.Brokers, topic, opts...)
 		return producer, nil
 	})
 	if err != nil {
@@ -100,6 +208,14 @@ func NewProducer(topic string, opts ...WriterOptionFunc) (*internal.Writer, erro
 	return resource.(*internal.Writer), nil
 }
 
+// WithAuth 配置 SASL/TLS 认证信息，默认使用 topic 所在集群的配置，auth.Brokers 不使用
+func WithAuth(auth KafkaConf) WriterOptionFunc {
+	return func(config *internal.WriterConf) {
+		config.Auth = auth
+		config.Auth.Brokers = nil
+	}
+}
+
 // WithAllowAutoTopicCreation 允许自动创建 topic
 func WithAllowAutoTopicCreation() WriterOptionFunc {
 	return func(config *internal.WriterConf) {
@@ -22,11 +22,27 @@ var (
 	WithDelayMaxRetryAttempts = delay.WithMaxRetryAttempts
 	WithDelayRetryDelay       = delay.WithRetryDelay
 	WithDelayReservedTimeout  = delay.WithReservedTimeout
+	WithDelayPriority         = delay.WithPriority
+	WithDelayTopicLimit       = delay.WithKeyLimit        // 按 topic 限制转发的并发和速率
+	WithDelayDefaultLimit     = delay.WithDefaultKeyLimit // 未单独配置的 topic 的默认限制
+)
+
+// 延迟消息优先级
+const (
+	DelayPriorityNormal = delay.PriorityNormal
+	DelayPriorityHigh   = delay.PriorityHigh
+	DelayPriorityLow    = delay.PriorityLow
 )
 
 // DelayOptionFunc 是 delay.OptionFunc 的类型别名，方便外部包使用
 type DelayOptionFunc = delay.OptionFunc
 
+// DelayPushOption 是 delay.PushOption 的类型别名
+type DelayPushOption = delay.PushOption
+
+// DelayKeyLimit 是 delay.KeyLimit 的类型别名，延迟队列中 Key 即目标 topic
+type DelayKeyLimit = delay.KeyLimit
+
 const delayPrefix = "kafka"
 
 var (
@@ -53,11 +69,11 @@ func DelaySetUp(redis *redis.Redis, opts ...DelayOptionFunc) {
 	})
 }
 
-// PushDelay 推送延迟消息到全局 Kafka 延迟队列。
+// PushDelay 推送延迟消息到全局 Kafka 延迟队列，可通过 WithDelayPriority 指定优先级。
 // 必须先调用 DelaySetUp 完成初始化。
-func PushDelay(ctx context.Context, topic string, data any, delayDuration time.Duration) error {
+func PushDelay(ctx context.Context, topic string, data any, delayDuration time.Duration, opts ...DelayPushOption) error {
 	if delayInstance == nil {
 		return fmt.Errorf("kafka.delayer not set up, call DelaySetUp first")
 	}
-	return delayInstance.Push(ctx, topic, data, delayDuration)
+	return delayInstance.Push(ctx, topic, data, delayDuration, opts...)
 }
\ No newline at end of file
@@ -0,0 +1,109 @@
+package kafka
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zeromicro/go-zero/core/service"
+	"github.com/zeromicro/go-zero/core/threading"
+	"github.com/zhuud/go-library/svc/conf"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 纯 Kafka 延迟消息：不依赖 Redis，消息先写入延迟分级 topic（delay-5s、delay-1m、delay-10m、delay-1h），
+// 由转发消费者按分区等待到期后投递到目标 topic（保留原始 key，转发时间记录在 header），延迟超过单级时长时逐级转入下一跳。
+
+// DelayTier 是 internal.DelayTier 的类型别名
+type DelayTier = internal.DelayTier
+
+var (
+	delayTiersMu sync.RWMutex
+	delayTiers   = internal.SortDelayTiers(internal.DefaultDelayTiers)
+
+	delayTopicOnce sync.Once
+)
+
+// SetDelayTiers 自定义延迟分级 topic，需在推送和 DelayTopicSetUp 之前调用，生产方与转发方须保持一致
+func SetDelayTiers(tiers ...DelayTier) {
+	if len(tiers) == 0 {
+		return
+	}
+	delayTiersMu.Lock()
+	defer delayTiersMu.Unlock()
+	delayTiers = internal.SortDelayTiers(tiers)
+}
+
+// getDelayTiers 返回当前延迟分级 topic，按延迟升序排列
+func getDelayTiers() []DelayTier {
+	delayTiersMu.RLock()
+	defer delayTiersMu.RUnlock()
+	return delayTiers
+}
+
+// DelayTopicSetUp 为每个延迟分级 topic 启动转发消费者（后台运行 + 自动注册关闭钩子）。
+// 幂等安全，多次调用只有首次生效。每个分配到的分区由独立的协程拉取和转发，一个分区等待到期不影响其他分区。
+func DelayTopicSetUp(opts ...ReaderOptionFunc) {
+	delayTopicOnce.Do(func() {
+		var writerOpts []WriterOptionFunc
+		if conf.IsLocal() {
+			writerOpts = append(writerOpts, WithAllowAutoTopicCreation())
+		}
+
+		// 分级 topic 从所在集群消费，转发时按目标 topic 选择集群
+		tiers := getDelayTiers()
+		serviceGroup := service.NewServiceGroup()
+		for _, tier := range tiers {
+			cluster, err := internal.RouteTopic(tier.Topic)
+			if err != nil {
+				log.Fatalf("kafka.delayTopic %s cluster error: %v", tier.Topic, err)
+			}
+			readerOpts := slices.Concat([]ReaderOptionFunc{WithReaderAuth(cluster)}, opts)
+			serviceGroup.Add(internal.NewDelayForwarder(cluster.Brokers, tier, tiers, readerOpts, writerOpts, internal.RouteTopic))
+		}
+
+		log.Printf("Starting Delay Topic Forwarder, Tiers: %v ...", tiers)
+		// ServiceGroup.Start 会注册关闭钩子，进程退出时停止转发
+		threading.GoSafe(serviceGroup.Start)
+	})
+}
+
+// PushDelayTopic 通过延迟分级 topic 推送延迟消息，到期后投递到 topic。
+// 无需 Redis，转发由 DelayTopicSetUp 启动的消费者完成（可部署在任意服务中）。
+func PushDelayTopic(ctx context.Context, topic string, data any, delayDuration time.Duration) error {
+	if len(topic) == 0 {
+		return fmt.Errorf("kafka.PushDelayTopic topic not set")
+	}
+
+	produceJson, err := marshalProduceMessage(ctx, topic, data, caller(2))
+	if err != nil {
+		return err
+	}
+
+	// 无需延迟时直接投递
+	if delayDuration <= 0 {
+		producer, err := NewProducer(topic)
+		if err != nil {
+			return err
+		}
+		return producer.Push(ctx, produceJson)
+	}
+
+	tier := internal.SelectDelayTier(getDelayTiers(), delayDuration)
+	producer, err := NewProducer(tier.Topic)
+	if err != nil {
+		return err
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(strconv.FormatInt(time.Now().UnixNano(), 10)),
+		Value: []byte(produceJson),
+	}
+	internal.SetDelayHeaders(&msg, topic, time.Now().Add(delayDuration))
+	return producer.WriteMessage(ctx, msg)
+}
@@ -0,0 +1,69 @@
+package kafka
+
+import (
+	"log"
+	"sync"
+
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// ContentEncodingHeader 消息 value 的压缩算法，由 WithCompressValue 设置，Consume 自动解压
+const ContentEncodingHeader = internal.ContentEncodingHeader
+
+// ProduceInterceptor 是 internal.ProduceInterceptor 的类型别名，写入前按顺序执行，可修改消息或返回错误拒绝发送
+type ProduceInterceptor = internal.ProduceInterceptor
+
+var (
+	// ErrMessageTooLarge 消息超过大小限制
+	ErrMessageTooLarge = internal.ErrMessageTooLarge
+	// ErrSchemaValidation 消息不符合 JSON schema
+	ErrSchemaValidation = internal.ErrSchemaValidation
+)
+
+var topicWriterOptions sync.Map // map[string][]WriterOptionFunc
+
+// SetTopicWriterOptions 配置 topic 的 Writer 选项（如拦截器），在该 topic 首次推送前调用，
+// 先于推送时传入的选项应用，无需在每处 Push 传入
+func SetTopicWriterOptions(topic string, opts ...WriterOptionFunc) {
+	topicWriterOptions.Store(topic, opts)
+}
+
+// getTopicWriterOptions 返回 SetTopicWriterOptions 配置的选项
+func getTopicWriterOptions(topic string) []WriterOptionFunc {
+	if opts, ok := topicWriterOptions.Load(topic); ok {
+		return opts.([]WriterOptionFunc)
+	}
+	return nil
+}
+
+// WithInterceptors 添加生产拦截器，按添加顺序执行
+func WithInterceptors(interceptors ...ProduceInterceptor) WriterOptionFunc {
+	return func(config *internal.WriterConf) {
+		config.Interceptors = append(config.Interceptors, interceptors...)
+	}
+}
+
+// WithMaxMessageBytes 拒绝超过 limit 字节的消息；未配置时按 BatchBytes 检查
+func WithMaxMessageBytes(limit int64) WriterOptionFunc {
+	return WithInterceptors(internal.SizeLimitInterceptor(limit))
+}
+
+// WithJSONSchema 按 JSON schema 校验消息负载（Push 的 data），不符合时拒绝发送；schema 无效或包含不支持的关键字时启动失败
+func WithJSONSchema(schema string) WriterOptionFunc {
+	interceptor, err := internal.JSONSchemaInterceptor([]byte(schema))
+	if err != nil {
+		log.Fatalf("kafka.WithJSONSchema invalid schema error: %v", err)
+	}
+	return WithInterceptors(interceptor)
+}
+
+// WithRedactFields 把负载（Push 的 data）中的指定字段替换为 ***，路径使用 . 分隔，如 user.phone
+func WithRedactFields(fields ...string) WriterOptionFunc {
+	return WithInterceptors(internal.RedactInterceptor(fields...))
+}
+
+// WithCompressValue 使用 gzip 压缩超过 threshold 字节的 value，需放在其他拦截器之后；
+// Consume 自动解压，其他语言的消费者需按 ContentEncodingHeader 自行解压
+func WithCompressValue(threshold int) WriterOptionFunc {
+	return WithInterceptors(internal.CompressInterceptor(threshold))
+}
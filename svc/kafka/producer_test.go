@@ -0,0 +1,53 @@
+package kafka
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zhuud/go-library/svc/conf"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+func TestPushWithOptionsPartition(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(file, []byte("Kafka:\n  Brokers: [127.0.0.1:1]\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := conf.SetUp(conf.WithFilePath(file)); err != nil {
+		t.Fatal(err)
+	}
+
+	// 拦截器在写入前拿到最终消息，返回错误以免真正发送
+	errCaptured := errors.New("captured")
+	var captured kafka.Message
+	capture := func(ctx context.Context, msg *kafka.Message) error {
+		captured = *msg
+		return errCaptured
+	}
+
+	err := PushWithOptions(context.Background(), "push-with-options", map[string]any{"id": 1},
+		WithKey("order-1"),
+		WithHeaders(map[string]string{"biz": "order"}),
+		WithPartition(2),
+		WithWriterOptions(WithInterceptors(capture)),
+	)
+	if !errors.Is(err, errCaptured) {
+		t.Fatalf("PushWithOptions got %v, want captured", err)
+	}
+
+	m := internal.NewMessage(&captured)
+	if string(captured.Key) != "order-1" || m.GetHeader("biz") != "order" {
+		t.Fatalf("captured key %s, headers %v", captured.Key, captured.Headers)
+	}
+	if len(m.GetHeader(internal.PartitionHeader)) > 0 {
+		t.Fatalf("partition header should be stripped before write, headers %v", captured.Headers)
+	}
+	if fmt.Sprint(captured.WriterData) != "2" {
+		t.Fatalf("partition choice %v, want 2", captured.WriterData)
+	}
+}
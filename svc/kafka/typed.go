@@ -0,0 +1,218 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"reflect"
+	"strconv"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zhuud/go-library/svc/codec"
+	"github.com/zhuud/go-library/svc/kafka/internal"
+)
+
+// 强类型消息的 header
+const (
+	// CodecHeader 负载编解码器名称，未设置时按 JSON 解码
+	CodecHeader = internal.CodecHeader
+	// SchemaVersionHeader 负载 schema 版本，消费端据此兼容旧格式
+	SchemaVersionHeader = "x-schema-version"
+)
+
+type (
+	// TypedMessage 解码后的强类型消息，包含信封元信息
+	TypedMessage[T any] struct {
+		Topic     string
+		Partition int
+		Offset    int64
+		Key       string
+		// From 推送方调用位置 file:line
+		From string
+		// Timestamp 推送时间，Unix 秒
+		Timestamp int64
+		// TraceId 推送时的 trace id
+		TraceId string
+		// Version 推送时的负载 schema 版本
+		Version int
+		Headers map[string]string
+		Data    T
+	}
+
+	// TypedConsumeHandler 强类型消息的处理器接口
+	TypedConsumeHandler[T any] interface {
+		Name() string
+		Topics() []string
+		Consume(ctx context.Context, msg *TypedMessage[T]) error
+	}
+
+	// TypedOption 强类型推送选项
+	TypedOption func(config *typedConfig)
+
+	typedConfig struct {
+		codec   codec.Codec
+		version int
+	}
+
+	// typedConsumeHandler 把 TypedConsumeHandler 适配为 ConsumeHandler，以完整消息消费以便读取 headers
+	typedConsumeHandler[T any] struct {
+		handler TypedConsumeHandler[T]
+	}
+
+	// typedEnvelope 与 produceMessage 对应的解码结构，Data 延迟到按编解码器解码
+	typedEnvelope struct {
+		From      string          `json:"from"`
+		Timestamp int64           `json:"timestamp"`
+		Data      json.RawMessage `json:"data"`
+		LogId     string          `json:"logid"`
+	}
+)
+
+// WithCodec 配置负载编解码器，默认 codec.JSON；消费端按 CodecHeader 解码
+func WithCodec(c codec.Codec) TypedOption {
+	return func(config *typedConfig) {
+		if c != nil {
+			config.codec = c
+		}
+	}
+}
+
+// WithSchemaVersion 配置写入 SchemaVersionHeader 的负载 schema 版本
+func WithSchemaVersion(version int) TypedOption {
+	return func(config *typedConfig) {
+		config.version = version
+	}
+}
+
+// PushTyped 按编解码器编码 data 后推送到 Kafka，信封格式与 Push 一致，JSON 负载可被普通消费者读取
+func PushTyped[T any](ctx context.Context, topic string, data T, opts ...TypedOption) error {
+	producer, err := NewProducer(topic)
+	if err != nil {
+		return err
+	}
+
+	msg, err := encodeTyped(ctx, topic, data, caller(2), opts...)
+	if err != nil {
+		return err
+	}
+	return producer.WriteMessage(ctx, msg)
+}
+
+// TypedConsume 启动强类型消息消费者服务，消费方式与 Consume 一致
+func TypedConsume[T any](handler TypedConsumeHandler[T], opts ...ReaderOptionFunc) {
+	if handler == nil {
+		log.Fatalf("kafka.TypedConsume handler not set")
+	}
+	Consume(&typedConsumeHandler[T]{handler: handler}, opts...)
+}
+
+// Name 实现 ConsumeHandler
+func (h *typedConsumeHandler[T]) Name() string {
+	return h.handler.Name()
+}
+
+// Topics 实现 ConsumeHandler
+func (h *typedConsumeHandler[T]) Topics() []string {
+	return h.handler.Topics()
+}
+
+// Consume 实现 ConsumeHandler，无 headers 时按 JSON 解码
+func (h *typedConsumeHandler[T]) Consume(ctx context.Context, key, value string) error {
+	return h.ConsumeMessage(ctx, kafka.Message{Key: []byte(key), Value: []byte(value)})
+}
+
+// ConsumeMessage 实现 internal.MessageHandler
+func (h *typedConsumeHandler[T]) ConsumeMessage(ctx context.Context, msg kafka.Message) error {
+	typed, err := decodeTyped[T](msg)
+	if err != nil {
+		return err
+	}
+	return h.handler.Consume(ctx, typed)
+}
+
+// encodeTyped 编码负载并包装为消息信封，JSON 负载直接内联，其他编解码器以 base64 字符串保存
+func encodeTyped[T any](ctx context.Context, topic string, data T, from string, opts ...TypedOption) (kafka.Message, error) {
+	config := typedConfig{codec: codec.JSON}
+	for _, opt := range opts {
+		opt(&config)
+	}
+
+	b, err := config.codec.Marshal(data)
+	if err != nil {
+		return kafka.Message{}, fmt.Errorf("kafka.PushTyped %s Marshal error: %w", config.codec.Name(), err)
+	}
+	var payload any = b
+	if config.codec.Name() == codec.NameJSON {
+		payload = json.RawMessage(b)
+	}
+
+	produceJson, err := marshalProduceMessage(ctx, topic, payload, from)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	msg := kafka.Message{
+		Key:   []byte(strconv.FormatInt(time.Now().UnixNano(), 10)),
+		Value: []byte(produceJson),
+	}
+	m := internal.NewMessage(&msg)
+	m.SetHeader(CodecHeader, config.codec.Name())
+	m.SetHeader(SchemaVersionHeader, strconv.Itoa(config.version))
+	return msg, nil
+}
+
+// decodeTyped 解析消息信封并按 CodecHeader 解码 Data，未设置编解码器的普通消息按 JSON 解码，兼容 Push 写入的数据
+func decodeTyped[T any](msg kafka.Message) (*TypedMessage[T], error) {
+	var envelope typedEnvelope
+	if err := json.Unmarshal(msg.Value, &envelope); err != nil {
+		return nil, fmt.Errorf("kafka.TypedConsume decode envelope error: %w", err)
+	}
+
+	headers := make(map[string]string, len(msg.Headers))
+	for _, h := range msg.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	version, _ := strconv.Atoi(headers[SchemaVersionHeader])
+
+	name := headers[CodecHeader]
+	if len(name) == 0 {
+		name = codec.NameJSON
+	}
+	c, ok := codec.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("kafka.TypedConsume decode unknown codec: %s", name)
+	}
+
+	b := []byte(envelope.Data)
+	if name != codec.NameJSON {
+		if err := json.Unmarshal(envelope.Data, &b); err != nil {
+			return nil, fmt.Errorf("kafka.TypedConsume decode %s payload error: %w", name, err)
+		}
+	}
+
+	// T 为指针类型（如 protobuf 消息）时分配新对象直接解码
+	var data T
+	target := any(&data)
+	if rt := reflect.TypeFor[T](); rt.Kind() == reflect.Pointer {
+		data = reflect.New(rt.Elem()).Interface().(T)
+		target = data
+	}
+	if err := c.Unmarshal(b, target); err != nil {
+		return nil, fmt.Errorf("kafka.TypedConsume decode %s Unmarshal error: %w", name, err)
+	}
+
+	return &TypedMessage[T]{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Key:       string(msg.Key),
+		From:      envelope.From,
+		Timestamp: envelope.Timestamp,
+		TraceId:   envelope.LogId,
+		Version:   version,
+		Headers:   headers,
+		Data:      data,
+	}, nil
+}
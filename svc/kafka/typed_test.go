@@ -0,0 +1,48 @@
+package kafka
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/zhuud/go-library/svc/codec"
+)
+
+type typedOrder struct {
+	ID     int64  `json:"id" msgpack:"id"`
+	Status string `json:"status" msgpack:"status"`
+}
+
+func TestTypedRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	order := typedOrder{ID: 9007199254740993, Status: "paid"}
+
+	for _, c := range []codec.Codec{codec.JSON, codec.Msgpack} {
+		msg, err := encodeTyped(ctx, "order", order, "test", WithCodec(c), WithSchemaVersion(2))
+		if err != nil {
+			t.Fatalf("%s encode error: %v", c.Name(), err)
+		}
+		typed, err := decodeTyped[typedOrder](msg)
+		if err != nil {
+			t.Fatalf("%s decode error: %v", c.Name(), err)
+		}
+		if typed.Data != order || typed.Version != 2 || typed.From != "test" || typed.Timestamp == 0 {
+			t.Fatalf("%s decode got %+v", c.Name(), typed)
+		}
+	}
+}
+
+func TestTypedDecodeUntyped(t *testing.T) {
+	value, err := json.Marshal(produceMessage{Topic: "order", From: "test", Timestamp: 1, Data: map[string]any{"id": 1, "status": "paid"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	typed, err := decodeTyped[*typedOrder](kafka.Message{Value: value})
+	if err != nil {
+		t.Fatalf("decode error: %v", err)
+	}
+	if typed.Data.ID != 1 || typed.Data.Status != "paid" || typed.Version != 0 {
+		t.Fatalf("decode got %+v", typed.Data)
+	}
+}
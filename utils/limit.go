@@ -2,6 +2,7 @@ package utils
 
 import (
 	"context"
+	"errors"
 	"fmt"
 
 	"github.com/zeromicro/go-zero/core/stores/redis"
@@ -62,7 +63,7 @@ func CheckFreq(ctx context.Context, r *redis.Redis, optionList []FrequencyOption
 			return true, err
 		}
 		if !ok {
-			return false, fmt.Errorf(option.Msg)
+			return false, errors.New(option.Msg)
 		}
 	}
 	return true, nil